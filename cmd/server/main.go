@@ -1,19 +1,36 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"rtorrent-go/internal/bandwidth"
+	"rtorrent-go/internal/blocklist"
 	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/fetch"
+	"rtorrent-go/internal/geoip"
+	"rtorrent-go/internal/jobs"
+	"rtorrent-go/internal/magnet"
+	"rtorrent-go/internal/metainfo"
+	"rtorrent-go/internal/pathutil"
+	"rtorrent-go/internal/resolver"
 	"rtorrent-go/internal/rtorrent"
+	"rtorrent-go/internal/scheduler"
 	"rtorrent-go/views/components"
 	"sort"
+	"strconv"
 	"strings"
-
-	"io"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -35,6 +52,9 @@ func main() {
 	}
 	log.Printf("  Server: %s:%d", cfg.Server.Host, cfg.Server.Port)
 
+	// Background operations like moves, polled by the UI
+	jobManager := jobs.NewManager()
+
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
@@ -42,7 +62,7 @@ func main() {
 	// Check if rTorrent is configured
 	var client rtorrent.Client
 	if config.IsRTorrentConfigured() {
-		client = rtorrent.NewClient(cfg.RTorrent.Socket)
+		client = rtorrent.NewClient(cfg.RTorrent.Socket, clientOptions(cfg)...)
 		// Test connection
 		if err := client.TestConnection(); err != nil {
 			log.Printf("⚠ Warning: Cannot connect to rTorrent: %v", err)
@@ -51,6 +71,26 @@ func main() {
 		}
 	}
 
+	// Speed profiles. The scheduler also re-applies turtle mode or the
+	// current slot whenever rTorrent restarts and loses its runtime limits.
+	bandwidthCtl := bandwidth.NewController(client, cfg)
+	speedScheduler := scheduler.New(bandwidthCtl)
+	go speedScheduler.Run(context.Background())
+	magnetResolver := resolver.New(client)
+	go magnetResolver.Run(context.Background())
+	// IP blocklists, kept loaded across rTorrent restarts
+	blocklists := blocklist.New(client, cfg)
+	go blocklists.Run(context.Background())
+	// Downloads http(s) .torrent links with the cookies configured per site
+	torrentFetcher := fetch.New(cfg.Fetch)
+	// Peer countries, only when a database is configured
+	var geoDB *geoip.DB
+	if cfg.GeoIP.Database != "" {
+		if geoDB, err = geoip.Open(cfg.GeoIP.Database); err != nil {
+			log.Printf("⚠ Warning: GeoIP disabled: %v", err)
+		}
+	}
+
 	// Middleware to check if setup is required
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -105,32 +145,41 @@ func main() {
 			socket = "tcp://" + host + ":" + port
 		}
 
-		// Update config
-		cfg.RTorrent.Socket = socket
+		// New settings, only stored once the connection works
+		updated := &config.Config{RTorrent: cfg.RTorrent, Downloads: cfg.Downloads}
+		updated.RTorrent.Socket = socket
 
 		// Update download paths if provided
 		if defaultPath := r.FormValue("default_path"); defaultPath != "" {
-			cfg.Downloads.DefaultPath = defaultPath
+			updated.Downloads.DefaultPath = defaultPath
 		}
 		if tempPath := r.FormValue("temp_path"); tempPath != "" {
-			cfg.Downloads.TempPath = tempPath
+			updated.Downloads.TempPath = tempPath
 		}
 
 		// Test connection
-		testClient := rtorrent.NewClient(socket)
+		testClient := rtorrent.NewClient(socket, clientOptions(updated)...)
 		if err := testClient.TestConnection(); err != nil {
 			components.SetupPage(fmt.Sprintf("Cannot connect to rTorrent: %v", err)).Render(r.Context(), w)
 			return
 		}
 
 		// Save config
-		if err := config.SaveConfig(); err != nil {
+		err := config.Update(func() {
+			cfg.RTorrent = updated.RTorrent
+			cfg.Downloads = updated.Downloads
+		})
+		if err != nil {
 			components.SetupPage(fmt.Sprintf("Failed to save config: %v", err)).Render(r.Context(), w)
 			return
 		}
 
 		// Update global client
 		client = testClient
+		bandwidthCtl.SetClient(client)
+		speedScheduler.Reload()
+		magnetResolver.SetClient(client)
+		blocklists.SetClient(client)
 
 		// Redirect to dashboard
 		w.Header().Set("HX-Redirect", "/")
@@ -159,15 +208,26 @@ func main() {
 		deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
 
 		if deleteFiles {
-			// In jesec/rtorrent, d.erase also deletes data if configured,
-			// but usually we might need to call something else or rely on rTorrent side.
-			// For now, we use d.erase.
-			if err := client.DeleteTorrent(r.Context(), hash); err != nil {
-				log.Printf("Error deleting torrent with data: %v", err)
+			if err := client.DeleteTorrentWithData(r.Context(), hash); err != nil {
+				var dataErr *rtorrent.DataRemovalError
+				if errors.As(err, &dataErr) {
+					// The torrent is gone but some files stayed behind
+					log.Printf("Error deleting data of %s: %v", hash, err)
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"error":  err.Error(),
+						"failed": dataErr.Failed,
+					})
+					return
+				}
+				writeClientError(w, err)
+				return
 			}
 		} else {
 			if err := client.DeleteTorrent(r.Context(), hash); err != nil {
-				log.Printf("Error deleting torrent: %v", err)
+				writeClientError(w, err)
+				return
 			}
 		}
 
@@ -180,25 +240,46 @@ func main() {
 
 	r.Post("/torrent/{hash}/start", func(w http.ResponseWriter, r *http.Request) {
 		hash := chi.URLParam(r, "hash")
-		client.StartTorrent(r.Context(), hash)
+		if err := client.StartTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
 		renderDashboardContainer(w, r, client, "all")
 	})
 
 	r.Post("/torrent/{hash}/pause", func(w http.ResponseWriter, r *http.Request) {
 		hash := chi.URLParam(r, "hash")
-		client.PauseTorrent(r.Context(), hash)
+		if err := client.PauseTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
 		renderDashboardContainer(w, r, client, "all")
 	})
 
 	r.Post("/torrent/{hash}/stop", func(w http.ResponseWriter, r *http.Request) {
 		hash := chi.URLParam(r, "hash")
-		client.StopTorrent(r.Context(), hash)
+		if err := client.StopTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
 		renderDashboardContainer(w, r, client, "all")
 	})
 
 	r.Post("/torrent/{hash}/recheck", func(w http.ResponseWriter, r *http.Request) {
 		hash := chi.URLParam(r, "hash")
-		client.RecheckTorrent(r.Context(), hash)
+		if err := client.RecheckTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		renderDashboardContainer(w, r, client, "all")
+	})
+
+	r.Post("/torrent/{hash}/reannounce", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		if err := client.ReannounceTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
 		renderDashboardContainer(w, r, client, "all")
 	})
 
@@ -207,8 +288,13 @@ func main() {
 		var body struct {
 			Priority int `json:"priority"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
-			client.SetPriority(r.Context(), hash, body.Priority)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := client.SetPriority(r.Context(), hash, body.Priority); err != nil {
+			writeClientError(w, err)
+			return
 		}
 		renderDashboardContainer(w, r, client, "all")
 	})
@@ -218,12 +304,167 @@ func main() {
 		var body struct {
 			Label string `json:"label"`
 		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
-			client.SetLabel(r.Context(), hash, body.Label)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := client.SetLabel(r.Context(), hash, body.Label); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		if err := bandwidthCtl.AssignLabelGroups(r.Context()); err != nil {
+			log.Printf("Failed to assign label throttle groups: %v", err)
+		}
+		renderDashboardContainer(w, r, client, "all")
+	})
+
+	r.Post("/torrent/{hash}/move", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		var body struct {
+			Path      string `json:"path"`
+			MoveFiles bool   `json:"move_files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !filepath.IsAbs(body.Path) || !pathutil.Under(body.Path, cfg.Downloads.AllowedRoots()) {
+			http.Error(w, "Destination is outside the download roots", http.StatusForbidden)
+			return
+		}
+
+		// Large or cross-device moves can take a while, so run them as a
+		// job and let the UI poll its progress.
+		moveClient := client
+		job := jobManager.Start("move", fmt.Sprintf("Move %s to %s", hash, body.Path), func(ctx context.Context, progress jobs.Progress) (string, error) {
+			ctx = rtorrent.WithMoveProgress(ctx, rtorrent.MoveProgressFunc(progress))
+			return body.Path, moveClient.MoveStorage(ctx, hash, body.Path, body.MoveFiles)
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+
+	r.Post("/torrent/{hash}/throttle", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		var body struct {
+			Group string `json:"group"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := bandwidthCtl.AssignGroup(r.Context(), hash, body.Group); err != nil {
+			if errors.Is(err, bandwidth.ErrUnknownGroup) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeClientError(w, err)
+			return
 		}
 		renderDashboardContainer(w, r, client, "all")
 	})
 
+	r.Get("/api/torrent/{hash}/piece-modes", func(w http.ResponseWriter, r *http.Request) {
+		modes, err := client.GetPieceModes(r.Context(), chi.URLParam(r, "hash"))
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(modes)
+	})
+
+	r.Get("/api/torrent/{hash}/pieces", func(w http.ResponseWriter, r *http.Request) {
+		bitfield, err := client.GetBitfield(r.Context(), chi.URLParam(r, "hash"))
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"piece_count": bitfield.PieceCount,
+			"completed":   bitfield.Completed(),
+			"buckets":     bitfield.Buckets(pieceBuckets(r)),
+		})
+	})
+
+	r.Get("/torrent/{hash}/piece-map", func(w http.ResponseWriter, r *http.Request) {
+		bitfield, err := client.GetBitfield(r.Context(), chi.URLParam(r, "hash"))
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		components.PieceMap(bitfield.PieceCount, bitfield.Completed(), bitfield.Buckets(pieceBuckets(r))).Render(r.Context(), w)
+	})
+
+	r.Get("/torrent/{hash}/piece-modes", func(w http.ResponseWriter, r *http.Request) {
+		renderPieceModes(w, r, client, chi.URLParam(r, "hash"))
+	})
+
+	r.Post("/torrent/{hash}/piece-modes", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		enabled := r.FormValue("enabled") == "true"
+
+		var err error
+		switch r.FormValue("mode") {
+		case "first_last":
+			err = client.SetFirstLastPiece(r.Context(), hash, enabled)
+		case "sequential":
+			err = client.SetSequential(r.Context(), hash, enabled)
+		default:
+			http.Error(w, "Unknown piece mode", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		renderPieceModes(w, r, client, hash)
+	})
+
+	r.Post("/torrent/{hash}/trackers/merge", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		missing, err := missingTrackers(r.Context(), client, hash, nonEmpty(strings.Split(r.FormValue("trackers"), "\n")))
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		for _, url := range missing {
+			if err := client.AddTracker(r.Context(), hash, url); err != nil {
+				writeClientError(w, err)
+				return
+			}
+		}
+		log.Printf("Merged %d trackers into %s", len(missing), hash)
+		components.TrackersMerged(len(missing)).Render(r.Context(), w)
+	})
+
+	r.Post("/torrent/preview", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("torrent_file")
+		if err != nil {
+			http.Error(w, "No torrent file uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Failed to read torrent file", http.StatusBadRequest)
+			return
+		}
+		meta, err := metainfo.Parse(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		components.TorrentPreview(meta).Render(r.Context(), w)
+	})
+
 	r.Post("/torrent/add", func(w http.ResponseWriter, r *http.Request) {
 		if err := r.ParseMultipartForm(32 << 20); err != nil {
 			http.Error(w, "Failed to parse form", http.StatusBadRequest)
@@ -231,34 +472,42 @@ func main() {
 		}
 
 		sourceType := r.FormValue("source_type")
-		downloadPath := r.FormValue("download_path")
-		autoStart := r.FormValue("auto_start") == "on" || r.FormValue("auto_start") == "true"
-
-		var err error
-		if sourceType == "url" {
-			url := r.FormValue("torrent_url")
-			if url != "" {
-				err = client.AddTorrentByUrl(r.Context(), url, autoStart, downloadPath)
-			}
-		} else {
-			file, _, fErr := r.FormFile("torrent_file")
-			if fErr == nil {
-				defer file.Close()
-				data, readErr := io.ReadAll(file)
-				if readErr == nil {
-					err = client.AddTorrentByData(r.Context(), data, autoStart, downloadPath)
-				} else {
-					err = readErr
-				}
-			}
+		opts := addOptionsFromForm(r)
+		if opts.ThrottleGroup != "" && !bandwidthCtl.HasGroup(opts.ThrottleGroup) {
+			http.Error(w, fmt.Sprintf("%v %q", bandwidth.ErrUnknownGroup, opts.ThrottleGroup), http.StatusBadRequest)
+			return
 		}
 
+		items, err := collectAddItems(r, sourceType)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if len(items) == 1 {
+			// The preview only exists for a single file
+			opts.FilePriorities = skippedFiles(r)
+		}
 
-		renderDashboardContainer(w, r, client, "all")
+		results := addTorrents(r.Context(), client, magnetResolver, torrentFetcher, items, opts)
+		if len(results) == 1 {
+			switch res := results[0]; res.Status {
+			case components.AddStatusAdded:
+				renderDashboardContainer(w, r, client, "all")
+				return
+			case components.AddStatusDuplicate:
+				w.Header().Set("HX-Retarget", "#add-result")
+				w.Header().Set("HX-Reswap", "innerHTML")
+				components.DuplicateNotice(rtorrent.Torrent{Hash: res.Hash, Name: res.Name}, res.Trackers).Render(r.Context(), w)
+				return
+			}
+		}
+
+		// Show what happened to every item in the modal and let the list
+		// pick up whatever was added
+		w.Header().Set("HX-Retarget", "#add-result")
+		w.Header().Set("HX-Reswap", "innerHTML")
+		w.Header().Set("HX-Trigger", "refresh-list")
+		components.AddResults(results).Render(r.Context(), w)
 	})
 
 	r.Get("/torrent/{hash}/details", func(w http.ResponseWriter, r *http.Request) {
@@ -266,7 +515,7 @@ func main() {
 
 		torrent, err := client.GetTorrentDetails(r.Context(), hash)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeClientError(w, err)
 			return
 		}
 
@@ -279,6 +528,136 @@ func main() {
 		components.DetailContent(*torrent, files).Render(r.Context(), w)
 	})
 
+	r.Post("/torrent/{hash}/files/priority", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+
+		priority, err := strconv.Atoi(r.FormValue("priority"))
+		if err != nil || priority < 0 || priority > 2 {
+			http.Error(w, "Invalid file priority", http.StatusBadRequest)
+			return
+		}
+
+		var indices []int
+		for _, part := range strings.Split(r.FormValue("indices"), ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || index < 0 {
+				http.Error(w, "Invalid file index", http.StatusBadRequest)
+				return
+			}
+			indices = append(indices, index)
+		}
+
+		if err := client.SetFilePriority(r.Context(), hash, indices, priority); err != nil {
+			writeClientError(w, err)
+			return
+		}
+
+		files, err := client.GetTorrentFiles(r.Context(), hash)
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+
+		components.FileTree(hash, files).Render(r.Context(), w)
+	})
+
+	r.Get("/torrent/{hash}/peers", func(w http.ResponseWriter, r *http.Request) {
+		renderPeerList(w, r, client, geoDB, chi.URLParam(r, "hash"))
+	})
+
+	r.Post("/torrent/{hash}/peers", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		address := strings.TrimSpace(r.FormValue("address"))
+		if err := client.AddPeer(r.Context(), hash, address); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		log.Printf("Peer added to %s: %s", hash, address)
+		renderPeerList(w, r, client, geoDB, hash)
+	})
+
+	r.Post("/torrent/{hash}/peers/{id}/{action:disconnect|ban|snub|unsnub}", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		id := chi.URLParam(r, "id")
+		action := chi.URLParam(r, "action")
+
+		// Look the peer up first, both to log its address and because
+		// rTorrent's error for a peer that just left is unhelpful
+		peers, err := client.GetPeers(r.Context(), hash)
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		var peer *rtorrent.Peer
+		for i := range peers {
+			if strings.EqualFold(peers[i].ID, id) {
+				peer = &peers[i]
+				break
+			}
+		}
+		if peer == nil {
+			http.Error(w, "Peer is no longer connected", http.StatusNotFound)
+			return
+		}
+
+		switch action {
+		case "disconnect":
+			err = client.DisconnectPeer(r.Context(), hash, peer.ID)
+		case "ban":
+			err = client.BanPeer(r.Context(), hash, peer.ID)
+		case "snub", "unsnub":
+			err = client.SetPeerSnubbed(r.Context(), hash, peer.ID, action == "snub")
+		}
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		log.Printf("Peer %s of %s: %s", net.JoinHostPort(peer.Address, strconv.Itoa(peer.Port)), hash, action)
+		renderPeerList(w, r, client, geoDB, hash)
+	})
+
+	r.Get("/torrent/{hash}/trackers", func(w http.ResponseWriter, r *http.Request) {
+		renderTrackerList(w, r, client, chi.URLParam(r, "hash"))
+	})
+
+	r.Post("/torrent/{hash}/trackers", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		url := strings.TrimSpace(r.FormValue("url"))
+		if url == "" {
+			http.Error(w, "Tracker URL is required", http.StatusBadRequest)
+			return
+		}
+		if err := client.AddTracker(r.Context(), hash, url); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		renderTrackerList(w, r, client, hash)
+	})
+
+	r.Post("/torrent/{hash}/trackers/reannounce", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		if err := client.ReannounceTorrent(r.Context(), hash); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		renderTrackerList(w, r, client, hash)
+	})
+
+	r.Post("/torrent/{hash}/trackers/{index}/{action:enable|disable}", func(w http.ResponseWriter, r *http.Request) {
+		hash := chi.URLParam(r, "hash")
+		index, err := strconv.Atoi(chi.URLParam(r, "index"))
+		if err != nil || index < 0 {
+			http.Error(w, "Invalid tracker index", http.StatusBadRequest)
+			return
+		}
+		enabled := chi.URLParam(r, "action") == "enable"
+		if err := client.SetTrackerEnabled(r.Context(), hash, index, enabled); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		renderTrackerList(w, r, client, hash)
+	})
+
 	r.Get("/settings", func(w http.ResponseWriter, r *http.Request) {
 		components.SettingsPage().Render(r.Context(), w)
 	})
@@ -289,7 +668,11 @@ func main() {
 
 	// JSON endpoint for dynamic badge counts (used by Alpine.js polling)
 	r.Get("/api/counts", func(w http.ResponseWriter, r *http.Request) {
-		torrents, _ := client.GetTorrents(r.Context())
+		torrents, err := client.GetTorrents(r.Context())
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
 		counts := map[string]int{
 			"all":         len(torrents),
 			"downloading": 0,
@@ -300,7 +683,7 @@ func main() {
 
 		for _, t := range torrents {
 			switch t.State {
-			case "downloading":
+			case "downloading", "metadata":
 				counts["downloading"]++
 			case "seeding":
 				counts["seeding"]++
@@ -319,8 +702,350 @@ func main() {
 		})
 	})
 
+	r.Get("/api/throttle", func(w http.ResponseWriter, r *http.Request) {
+		throttle, err := client.GetGlobalThrottle(r.Context())
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(throttle)
+	})
+
+	r.Post("/api/throttle", func(w http.ResponseWriter, r *http.Request) {
+		var body rtorrent.Throttle
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if body.DownloadRate < 0 || body.UploadRate < 0 {
+			http.Error(w, "Rates must not be negative", http.StatusBadRequest)
+			return
+		}
+		if err := client.SetGlobalThrottle(r.Context(), body); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		log.Printf("Global throttle set to down=%d up=%d B/s", body.DownloadRate, body.UploadRate)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+
+	r.Get("/api/turtle", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bandwidthCtl.Turtle())
+	})
+
+	r.Post("/api/turtle", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := bandwidthCtl.SetTurtle(r.Context(), body.Enabled); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bandwidthCtl.Turtle())
+	})
+
+	r.Post("/api/turtle/profile", func(w http.ResponseWriter, r *http.Request) {
+		var body bandwidth.Profile
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if body.DownloadRate < 0 || body.UploadRate < 0 || body.MaxPeers < 0 {
+			http.Error(w, "Values must not be negative", http.StatusBadRequest)
+			return
+		}
+		if err := bandwidthCtl.SetTurtleProfile(r.Context(), body); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bandwidthCtl.Turtle())
+	})
+
+	r.Get("/api/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocklistResponse(blocklists, blocklists.Status(r.Context())))
+	})
+
+	r.Post("/api/blocklist", func(w http.ResponseWriter, r *http.Request) {
+		// The filter path is left out: it is written on every load, so it
+		// is only set in the config file
+		var body struct {
+			Enabled bool     `json:"enabled"`
+			Sources []string `json:"sources"`
+			// ReloadHours is the reload interval, 0 for none
+			ReloadHours int `json:"reload_hours"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if body.ReloadHours < 0 {
+			http.Error(w, "Reload interval must not be negative", http.StatusBadRequest)
+			return
+		}
+		status, err := blocklists.SetSettings(r.Context(), config.BlocklistConfig{
+			Enabled:        body.Enabled,
+			Sources:        body.Sources,
+			ReloadInterval: time.Duration(body.ReloadHours) * time.Hour,
+		})
+		if errors.Is(err, blocklist.ErrSourceNotAllowed) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocklistResponse(blocklists, status))
+	})
+
+	r.Post("/api/blocklist/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := blocklists.Reload(r.Context()); err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocklistResponse(blocklists, blocklists.Status(r.Context())))
+	})
+
+	r.Post("/api/blocklist/import", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(64 << 20); err != nil {
+			http.Error(w, "Failed to parse form", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "No blocklist uploaded", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, "Failed to read blocklist", http.StatusBadRequest)
+			return
+		}
+		status, err := blocklists.Import(r.Context(), header.Filename, data)
+		if err != nil {
+			if errors.Is(err, blocklist.ErrEmpty) || errors.Is(err, blocklist.ErrSourceNotAllowed) {
+				http.Error(w, fmt.Sprintf("%s: %v", header.Filename, err), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, blocklist.ErrExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(blocklistResponse(blocklists, status))
+	})
+
+	r.Get("/api/schedule", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scheduleResponse(bandwidthCtl.Schedule(), speedScheduler.Active()))
+	})
+
+	r.Post("/api/schedule", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Enabled bool              `json:"enabled"`
+			Grid    []string          `json:"grid"`
+			Custom  bandwidth.Profile `json:"custom"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		schedule := config.ScheduleConfig{
+			Enabled: body.Enabled,
+			Grid:    body.Grid,
+			Custom: config.LimitsConfig{
+				DownloadLimit: bandwidth.ToKiB(body.Custom.DownloadRate),
+				UploadLimit:   bandwidth.ToKiB(body.Custom.UploadRate),
+				MaxPeers:      body.Custom.MaxPeers,
+			},
+		}
+		if err := bandwidthCtl.SetSchedule(schedule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		speedScheduler.Reload()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(scheduleResponse(schedule, speedScheduler.Active()))
+	})
+
+	r.Get("/api/throttle-groups", func(w http.ResponseWriter, r *http.Request) {
+		groups, labels := bandwidthCtl.Groups()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups":       groups,
+			"label_groups": labels,
+		})
+	})
+
+	r.Post("/api/throttle-groups", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Groups      []bandwidth.Group      `json:"groups"`
+			LabelGroups []bandwidth.LabelGroup `json:"label_groups"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := bandwidthCtl.SetGroups(r.Context(), body.Groups, body.LabelGroups); err != nil {
+			if errors.Is(err, bandwidth.ErrInvalidGroup) || errors.Is(err, bandwidth.ErrUnknownGroup) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeClientError(w, err)
+			return
+		}
+		groups, labels := bandwidthCtl.Groups()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups":       groups,
+			"label_groups": labels,
+		})
+	})
+
+	r.Get("/api/jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := jobManager.Get(chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+	})
+
+	// Create a .torrent from data inside the download roots; hashing runs
+	// as a background job
+	r.Post("/api/torrent/create", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Path        string   `json:"path"`
+			Trackers    []string `json:"trackers"`
+			PieceLength int64    `json:"piece_length"`
+			Private     bool     `json:"private"`
+			Comment     string   `json:"comment"`
+			WebSeeds    []string `json:"web_seeds"`
+			// Seed loads the new torrent into rTorrent right away
+			Seed bool `json:"seed"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		content := filepath.Clean(body.Path)
+		if !filepath.IsAbs(content) || !pathutil.Inside(content, cfg.Downloads.AllowedRoots()) {
+			http.Error(w, "Content must be inside the download roots", http.StatusForbidden)
+			return
+		}
+		info, err := os.Stat(content)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "Content not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Cannot read content: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		// The .torrent goes next to the content, which keeps it inside the
+		// roots as well
+		torrentPath := content + ".torrent"
+		if _, err := os.Stat(torrentPath); err == nil {
+			http.Error(w, torrentPath+" already exists", http.StatusConflict)
+			return
+		}
+		// rTorrent's base directory is the folder itself for multi-file
+		// torrents and the containing folder for single files
+		savePath := content
+		if !info.IsDir() {
+			savePath = filepath.Dir(content)
+		}
+
+		opts := metainfo.CreateOptions{
+			Path:        content,
+			Trackers:    nonEmpty(body.Trackers),
+			PieceLength: body.PieceLength,
+			Private:     body.Private,
+			Comment:     strings.TrimSpace(body.Comment),
+			WebSeeds:    nonEmpty(body.WebSeeds),
+			CreatedBy:   "VibeTorrent",
+		}
+		if err := opts.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		seedClient := client
+		job := jobManager.Start("create", "Create torrent for "+content, func(ctx context.Context, progress jobs.Progress) (string, error) {
+			data, err := metainfo.Create(ctx, opts, metainfo.ProgressFunc(progress))
+			if err != nil {
+				return "", err
+			}
+			file, err := os.OpenFile(torrentPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err != nil {
+				return "", err
+			}
+			if _, err := file.Write(data); err != nil {
+				file.Close()
+				os.Remove(torrentPath)
+				return "", err
+			}
+			if err := file.Close(); err != nil {
+				return "", err
+			}
+			log.Printf("Created torrent %s", torrentPath)
+			if body.Seed {
+				if err := seedClient.AddTorrentByData(ctx, data, rtorrent.AddOptions{AutoStart: true, DownloadPath: savePath}); err != nil {
+					return torrentPath, fmt.Errorf("torrent written to %s but loading it failed: %w", torrentPath, err)
+				}
+			}
+			return torrentPath, nil
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(job)
+	})
+
+	r.Get("/api/torrent/file", func(w http.ResponseWriter, r *http.Request) {
+		path := filepath.Clean(r.URL.Query().Get("path"))
+		if filepath.Ext(path) != ".torrent" || !filepath.IsAbs(path) || !pathutil.Inside(path, cfg.Downloads.AllowedRoots()) {
+			http.Error(w, "Not a torrent file inside the download roots", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-bittorrent")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		http.ServeFile(w, r, path)
+	})
+
+	// Directory browser for pickers, limited to the download roots
+	r.Get("/api/dirs", func(w http.ResponseWriter, r *http.Request) {
+		listing, err := listDirectories(r.URL.Query().Get("path"), cfg.Downloads.AllowedRoots(), r.URL.Query().Get("files") == "1")
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(listing)
+	})
+
 	r.Get("/list", func(w http.ResponseWriter, r *http.Request) {
-		torrents, _ := client.GetTorrents(r.Context())
+		torrents, err := client.GetTorrents(r.Context())
+		if err != nil {
+			writeClientError(w, err)
+			return
+		}
 		filter := r.URL.Query().Get("filter")
 		if filter == "" {
 			filter = "all"
@@ -357,7 +1082,9 @@ func main() {
 			switch filter {
 			case "all":
 				shouldInclude = true
-			case "downloading", "seeding", "paused":
+			case "downloading":
+				shouldInclude = t.State == "downloading" || t.State == "metadata"
+			case "seeding", "paused":
 				shouldInclude = t.State == filter
 			default:
 				if len(filter) > 6 && filter[:6] == "label:" {
@@ -393,6 +1120,381 @@ func main() {
 	}
 }
 
+// clientOptions builds the rTorrent client options from the loaded config
+func clientOptions(cfg *config.Config) []rtorrent.Option {
+	return []rtorrent.Option{
+		rtorrent.WithMaxResponseSize(cfg.RTorrent.MaxResponseSize),
+		rtorrent.WithTimeout(cfg.RTorrent.Timeout),
+		rtorrent.WithDownloadRoots(cfg.Downloads.AllowedRoots()),
+	}
+}
+
+// addOptionsFromForm reads the options of the add modal's form
+func addOptionsFromForm(r *http.Request) rtorrent.AddOptions {
+	priority, _ := strconv.Atoi(r.FormValue("priority"))
+	if priority < 0 || priority > 3 {
+		priority = 0
+	}
+	return rtorrent.AddOptions{
+		AutoStart:     r.FormValue("auto_start") == "on" || r.FormValue("auto_start") == "true",
+		DownloadPath:  strings.TrimSpace(r.FormValue("download_path")),
+		Label:         strings.TrimSpace(r.FormValue("label")),
+		Priority:      priority,
+		Name:          strings.TrimSpace(r.FormValue("name")),
+		ThrottleGroup: r.FormValue("throttle_group"),
+		SkipHashCheck: r.FormValue("skip_hash_check") == "on",
+	}
+}
+
+// skippedFiles returns the files left unchecked in the add modal's preview
+// as initial priorities of 0, or nil when there was no preview.
+func skippedFiles(r *http.Request) map[int]int {
+	count, err := strconv.Atoi(r.FormValue("file_count"))
+	if err != nil || count <= 0 {
+		return nil
+	}
+	wanted := make(map[int]bool)
+	for _, value := range r.Form["files"] {
+		if index, err := strconv.Atoi(value); err == nil {
+			wanted[index] = true
+		}
+	}
+	var skipped map[int]int
+	for i := 0; i < count; i++ {
+		if !wanted[i] {
+			if skipped == nil {
+				skipped = make(map[int]int)
+			}
+			skipped[i] = 0
+		}
+	}
+	return skipped
+}
+
+// addWorkers bounds how many torrents of one submission are loaded at once
+const addWorkers = 4
+
+// addItem is one torrent of an add submission
+type addItem struct {
+	// source names the item in the results: a file name or the URL
+	source string
+	url    string
+	data   []byte
+}
+
+// collectAddItems reads every uploaded .torrent, or every non-empty line
+// of the URL box
+func collectAddItems(r *http.Request, sourceType string) ([]addItem, error) {
+	var items []addItem
+	if sourceType == "url" {
+		for _, line := range strings.Split(r.FormValue("torrent_url"), "\n") {
+			if url := strings.TrimSpace(line); url != "" {
+				items = append(items, addItem{source: url, url: url})
+			}
+		}
+	} else if r.MultipartForm != nil {
+		for _, header := range r.MultipartForm.File["torrent_file"] {
+			file, err := header.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", header.Filename, err)
+			}
+			data, err := io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", header.Filename, err)
+			}
+			items = append(items, addItem{source: header.Filename, data: data})
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("nothing to add")
+	}
+	return items, nil
+}
+
+// addTorrents fetches and loads the items concurrently and reports on each one in
+// order. Adding a torrent that is already loaded fails silently in
+// rTorrent, so every info-hash we can work out is checked against the
+// loaded torrents and the rest of the batch first.
+func addTorrents(ctx context.Context, client rtorrent.Client, magnets *resolver.Resolver, fetcher *fetch.Fetcher, items []addItem, opts rtorrent.AddOptions) []components.AddResult {
+	results := make([]components.AddResult, len(items))
+	torrents, err := client.GetTorrents(ctx)
+	if err != nil {
+		for i, item := range items {
+			results[i] = components.AddResult{Source: item.source, Status: components.AddStatusFailed, Reason: err.Error()}
+		}
+		return results
+	}
+	loaded := make(map[string]rtorrent.Torrent, len(torrents))
+	for _, t := range torrents {
+		loaded[strings.ToUpper(t.Hash)] = t
+	}
+
+	var mu sync.Mutex
+	batch := make(map[string]bool)
+	// claim reports whether hash may be added, filling in res otherwise
+	claim := func(hash, name string, trackers []string, res *components.AddResult) bool {
+		res.Hash, res.Name = hash, name
+		if t, ok := loaded[hash]; ok {
+			res.Status, res.Name, res.Hash = components.AddStatusDuplicate, t.Name, t.Hash
+			missing, err := missingTrackers(ctx, client, t.Hash, trackers)
+			if err != nil {
+				log.Printf("Failed to compare trackers of %s: %v", hash, err)
+			}
+			res.Trackers = missing
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if batch[hash] {
+			res.Status, res.Reason = components.AddStatusDuplicate, "listed twice in this submission"
+			return false
+		}
+		batch[hash] = true
+		return true
+	}
+
+	add := func(item addItem) components.AddResult {
+		res := components.AddResult{Source: item.source, Name: item.source}
+		var err error
+		if fetch.IsFetchable(item.url) {
+			// Fetched here rather than by rTorrent, which would need the
+			// site's cookies and fails without telling anyone
+			if item.data, err = fetcher.Fetch(ctx, item.url); err != nil {
+				log.Printf("Failed to fetch %s: %v", item.url, err)
+				res.Status, res.Reason = components.AddStatusFailed, err.Error()
+				return res
+			}
+		}
+		switch {
+		case item.data != nil:
+			meta, parseErr := metainfo.Parse(item.data)
+			if parseErr != nil {
+				err = parseErr
+				break
+			}
+			if !claim(meta.InfoHash, meta.Name, meta.Trackers, &res) {
+				return res
+			}
+			err = client.AddTorrentByData(ctx, item.data, opts)
+		case magnet.IsMagnet(item.url):
+			link, parseErr := magnet.Parse(item.url)
+			if parseErr != nil {
+				err = parseErr
+				break
+			}
+			name := link.Name
+			if name == "" {
+				name = link.InfoHash
+			}
+			if !claim(link.InfoHash, name, link.Trackers, &res) {
+				return res
+			}
+			if err = client.AddTorrentByUrl(ctx, item.url, opts); err == nil {
+				// rTorrent swaps in a new torrent once the metadata
+				// arrives, so the options are applied again then
+				magnets.Track(link.InfoHash, opts)
+			}
+		default:
+			err = client.AddTorrentByUrl(ctx, item.url, opts)
+		}
+		if err != nil {
+			log.Printf("Failed to add %s: %v", item.source, err)
+			res.Status, res.Reason = components.AddStatusFailed, err.Error()
+			return res
+		}
+		res.Status = components.AddStatusAdded
+		return res
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < addWorkers && w < len(items); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = add(items[i])
+			}
+		}()
+	}
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+// Bucket counts for piece maps. Whatever the torrent's size, the response
+// stays at most maxPieceBuckets numbers.
+const (
+	defaultPieceBuckets = 150
+	maxPieceBuckets     = 2000
+)
+
+// pieceBuckets reads the requested bucket count from ?buckets=
+func pieceBuckets(r *http.Request) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("buckets"))
+	if err != nil || n <= 0 {
+		return defaultPieceBuckets
+	}
+	return min(n, maxPieceBuckets)
+}
+
+// renderPieceModes renders the drawer's piece-priority toggles
+func renderPieceModes(w http.ResponseWriter, r *http.Request, client rtorrent.Client, hash string) {
+	modes, err := client.GetPieceModes(r.Context(), hash)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	components.PieceModesPanel(hash, *modes).Render(r.Context(), w)
+}
+
+// missingTrackers returns the trackers the torrent doesn't announce to yet
+func missingTrackers(ctx context.Context, client rtorrent.Client, hash string, trackers []string) ([]string, error) {
+	existing, err := client.GetTrackers(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(existing))
+	for _, t := range existing {
+		known[t.URL] = true
+	}
+	var missing []string
+	for _, url := range trackers {
+		if url != "" && !known[url] {
+			known[url] = true
+			missing = append(missing, url)
+		}
+	}
+	return missing, nil
+}
+
+// writeClientError maps rTorrent client errors onto HTTP status codes
+func writeClientError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var fault *rtorrent.FaultError
+	switch {
+	case errors.Is(err, rtorrent.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, magnet.ErrInvalid), errors.Is(err, metainfo.ErrInvalid), errors.Is(err, metainfo.ErrInvalidOptions),
+		errors.Is(err, rtorrent.ErrSkipHashCheck), errors.Is(err, rtorrent.ErrInvalidPeer):
+		status = http.StatusBadRequest
+	case errors.Is(err, rtorrent.ErrPathNotAllowed):
+		status = http.StatusForbidden
+	case errors.Is(err, rtorrent.ErrDestinationExists):
+		status = http.StatusConflict
+	case errors.Is(err, rtorrent.ErrUnsupported):
+		status = http.StatusNotImplemented
+	case errors.Is(err, os.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, rtorrent.ErrTimeout):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, rtorrent.ErrDial), errors.Is(err, rtorrent.ErrProtocol), errors.As(err, &fault):
+		status = http.StatusBadGateway
+	}
+	log.Printf("rTorrent error (%d): %v", status, err)
+	http.Error(w, err.Error(), status)
+}
+
+// blocklistResponse is the JSON shape of /api/blocklist: the settings as
+// edited in the UI and the status of the last load
+func blocklistResponse(blocklists *blocklist.Manager, status blocklist.Status) map[string]interface{} {
+	settings := blocklists.Settings()
+	sources := settings.Sources
+	if sources == nil {
+		sources = []string{}
+	}
+	return map[string]interface{}{
+		"enabled":      settings.Enabled,
+		"sources":      sources,
+		"filter_path":  settings.FilterPath,
+		"reload_hours": int(settings.ReloadInterval / time.Hour),
+		"status":       status,
+	}
+}
+
+// scheduleResponse is the JSON shape of /api/schedule. Custom limits are
+// sent in bytes per second like every other rate in the API.
+func scheduleResponse(schedule config.ScheduleConfig, active byte) map[string]interface{} {
+	grid := schedule.Grid
+	if len(grid) == 0 {
+		grid = make([]string, 7)
+		for i := range grid {
+			grid[i] = strings.Repeat(string(config.SlotUnlimited), 24)
+		}
+	}
+	activeSlot := ""
+	if active != 0 {
+		activeSlot = string(active)
+	}
+	return map[string]interface{}{
+		"enabled": schedule.Enabled,
+		"grid":    grid,
+		"custom": bandwidth.Profile{
+			DownloadRate: schedule.Custom.DownloadLimit * 1024,
+			UploadRate:   schedule.Custom.UploadLimit * 1024,
+			MaxPeers:     schedule.Custom.MaxPeers,
+		},
+		"active": activeSlot,
+	}
+}
+
+// nonEmpty trims the strings and drops blank ones
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// directoryListing is the JSON shape returned by /api/dirs
+type directoryListing struct {
+	Path   string   `json:"path"`
+	Parent string   `json:"parent"`
+	Dirs   []string `json:"dirs"`
+	Files  []string `json:"files,omitempty"`
+}
+
+// listDirectories returns the subdirectories of path, or the roots
+// themselves when path is empty. Parent is empty once at a root. With
+// withFiles regular files are listed as well.
+func listDirectories(path string, roots []string, withFiles bool) (*directoryListing, error) {
+	roots = pathutil.CleanRoots(roots)
+	if path == "" {
+		return &directoryListing{Dirs: roots}, nil
+	}
+	path = filepath.Clean(path)
+	if !filepath.IsAbs(path) || !pathutil.Under(path, roots) {
+		return nil, fmt.Errorf("%w: %s", rtorrent.ErrPathNotAllowed, path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	listing := &directoryListing{Path: path, Dirs: []string{}}
+	if pathutil.Inside(path, roots) {
+		listing.Parent = filepath.Dir(path)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if entry.IsDir() {
+			listing.Dirs = append(listing.Dirs, filepath.Join(path, entry.Name()))
+		} else if withFiles && entry.Type().IsRegular() {
+			listing.Files = append(listing.Files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return listing, nil
+}
+
 func sortTorrents(torrents []rtorrent.Torrent, sortBy, order string) {
 	sort.Slice(torrents, func(i, j int) bool {
 		less := false
@@ -448,7 +1550,13 @@ func getSidebarProps(r *http.Request, client rtorrent.Client, filter string) (co
 	labelMap := make(map[string]bool)
 
 	for _, t := range torrents {
-		counts[t.State]++
+		// Magnets fetching metadata count as downloading, like the
+		// downloading filter and /api/counts have them
+		state := t.State
+		if state == "metadata" {
+			state = "downloading"
+		}
+		counts[state]++
 		if t.Label != "" {
 			labelCounts[t.Label]++
 			if !labelMap[t.Label] {
@@ -466,6 +1574,27 @@ func getSidebarProps(r *http.Request, client rtorrent.Client, filter string) (co
 	}, torrents
 }
 
+func renderPeerList(w http.ResponseWriter, r *http.Request, client rtorrent.Client, geoDB *geoip.DB, hash string) {
+	peers, err := client.GetPeers(r.Context(), hash)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	for i := range peers {
+		peers[i].Country = geoDB.Country(peers[i].Address)
+	}
+	components.PeerList(hash, peers).Render(r.Context(), w)
+}
+
+func renderTrackerList(w http.ResponseWriter, r *http.Request, client rtorrent.Client, hash string) {
+	trackers, err := client.GetTrackers(r.Context(), hash)
+	if err != nil {
+		writeClientError(w, err)
+		return
+	}
+	components.TrackerList(hash, trackers).Render(r.Context(), w)
+}
+
 func renderDashboardContainer(w http.ResponseWriter, r *http.Request, client rtorrent.Client, filter string) {
 	sidebar, torrents := getSidebarProps(r, client, filter)
 
@@ -513,7 +1642,9 @@ func renderDashboardContainer(w http.ResponseWriter, r *http.Request, client rto
 		switch filter {
 		case "all":
 			shouldInclude = true
-		case "downloading", "seeding", "paused":
+		case "downloading":
+			shouldInclude = t.State == "downloading" || t.State == "metadata"
+		case "seeding", "paused":
 			shouldInclude = t.State == filter
 		default:
 			if len(filter) > 6 && filter[:6] == "label:" {
@@ -579,7 +1710,9 @@ func renderDashboardMainArea(w http.ResponseWriter, r *http.Request, client rtor
 		switch filter {
 		case "all":
 			shouldInclude = true
-		case "downloading", "seeding", "paused":
+		case "downloading":
+			shouldInclude = t.State == "downloading" || t.State == "metadata"
+		case "seeding", "paused":
 			shouldInclude = t.State == filter
 		default:
 			if len(filter) > 6 && filter[:6] == "label:" {
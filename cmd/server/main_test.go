@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rtorrent-go/internal/bencode"
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/fetch"
+	"rtorrent-go/internal/metainfo"
+	"rtorrent-go/internal/resolver"
+	"rtorrent-go/internal/rtorrent"
+	"rtorrent-go/views/components"
+)
+
+// addedRecorder is implemented by the mock client
+type addedRecorder interface {
+	AddedOptions() []rtorrent.AddOptions
+}
+
+// loadedClient is the mock client with a list of loaded torrents tests
+// choose
+type loadedClient struct {
+	rtorrent.Client
+	torrents []rtorrent.Torrent
+}
+
+func (c *loadedClient) GetTorrents(ctx context.Context) ([]rtorrent.Torrent, error) {
+	return c.torrents, nil
+}
+
+func newLoadedClient(torrents ...rtorrent.Torrent) *loadedClient {
+	return &loadedClient{Client: rtorrent.NewClient("mock"), torrents: torrents}
+}
+
+func (c *loadedClient) added() int {
+	return len(c.Client.(addedRecorder).AddedOptions())
+}
+
+// testTorrent builds .torrent data announcing to trackers, returning it
+// with its info-hash
+func testTorrent(t *testing.T, name string, trackers ...string) ([]byte, string) {
+	t.Helper()
+	torrent := map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         name,
+			"length":       1,
+			"piece length": 16384,
+			"pieces":       strings.Repeat("x", 20),
+		},
+	}
+	if len(trackers) > 0 {
+		torrent["announce"] = trackers[0]
+		var tier []interface{}
+		for _, tr := range trackers {
+			tier = append(tier, tr)
+		}
+		torrent["announce-list"] = []interface{}{tier}
+	}
+	data, err := bencode.Encode(torrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := metainfo.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, meta.InfoHash
+}
+
+func testAdd(client rtorrent.Client, items []addItem) []components.AddResult {
+	return addTorrents(context.Background(), client, resolver.New(client), fetch.New(config.FetchConfig{}), items, rtorrent.AddOptions{})
+}
+
+func TestAddTorrentsDetectsDuplicates(t *testing.T) {
+	// The mock client's trackers include opentrackr, so only the new one
+	// is offered for merging
+	data, hash := testTorrent(t, "loaded", "udp://tracker.opentrackr.org:1337/announce", "http://new.example/announce")
+	fresh, _ := testTorrent(t, "fresh")
+	client := newLoadedClient(rtorrent.Torrent{Hash: hash, Name: "Already Here"})
+
+	results := testAdd(client, []addItem{
+		{source: "loaded.torrent", data: data},
+		// Magnets carry lowercase hashes just as often
+		{source: "magnet", url: "magnet:?xt=urn:btih:" + strings.ToLower(hash) + "&tr=http%3A%2F%2Fother.example%2Fannounce"},
+		{source: "fresh.torrent", data: fresh},
+	})
+
+	want := []components.AddResult{
+		{Source: "loaded.torrent", Status: components.AddStatusDuplicate, Name: "Already Here", Hash: hash, Trackers: []string{"http://new.example/announce"}},
+		{Source: "magnet", Status: components.AddStatusDuplicate, Name: "Already Here", Hash: hash, Trackers: []string{"http://other.example/announce"}},
+	}
+	if !reflect.DeepEqual(results[:2], want) {
+		t.Errorf("duplicates = %+v, want %+v", results[:2], want)
+	}
+	if results[2].Status != components.AddStatusAdded {
+		t.Errorf("fresh torrent = %+v, want added", results[2])
+	}
+	if n := client.added(); n != 1 {
+		t.Errorf("%d torrents sent to rTorrent, want only the fresh one", n)
+	}
+}
+
+func TestMissingTrackers(t *testing.T) {
+	client := rtorrent.NewClient("mock")
+	missing, err := missingTrackers(context.Background(), client, "123", []string{
+		"udp://tracker.opentrackr.org:1337/announce",
+		"http://new.example/announce",
+		"",
+		"http://new.example/announce",
+		"udp://second.example:80",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"http://new.example/announce", "udp://second.example:80"}
+	if !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+}
+
+func TestCollectAddItems(t *testing.T) {
+	form := strings.NewReader("torrent_url=" + strings.Join([]string{
+		"magnet%3A%3Fxt%3Durn%3Abtih%3Aabc",
+		"%20%20",
+		"%20https%3A%2F%2Fexample.com%2Fa.torrent%20",
+		"",
+	}, "%0A"))
+	r := httptest.NewRequest(http.MethodPost, "/torrent/add", form)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	items, err := collectAddItems(r, "url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []addItem{
+		{source: "magnet:?xt=urn:btih:abc", url: "magnet:?xt=urn:btih:abc"},
+		{source: "https://example.com/a.torrent", url: "https://example.com/a.torrent"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("URL items = %+v, want %+v", items, want)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, name := range []string{"one.torrent", "two.torrent"} {
+		part, err := mw.CreateFormFile("torrent_file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("data of " + name))
+	}
+	mw.Close()
+	r = httptest.NewRequest(http.MethodPost, "/torrent/add", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	items, err = collectAddItems(r, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []addItem{
+		{source: "one.torrent", data: []byte("data of one.torrent")},
+		{source: "two.torrent", data: []byte("data of two.torrent")},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("file items = %+v, want %+v", items, want)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/torrent/add", strings.NewReader("torrent_url=%0A"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := collectAddItems(r, "url"); err == nil {
+		t.Error("empty submission accepted")
+	}
+}
+
+// countingClient is the mock client recording how many adds run at once
+type countingClient struct {
+	*loadedClient
+
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (c *countingClient) AddTorrentByData(ctx context.Context, data []byte, opts rtorrent.AddOptions) error {
+	c.mu.Lock()
+	c.running++
+	c.peak = max(c.peak, c.running)
+	c.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	if bytes.Contains(data, []byte("rejected")) {
+		return errors.New("rejected by rTorrent")
+	}
+	return c.loadedClient.AddTorrentByData(ctx, data, opts)
+}
+
+func TestAddTorrentsBulk(t *testing.T) {
+	client := &countingClient{loadedClient: newLoadedClient()}
+
+	var items []addItem
+	for i := 0; i < 12; i++ {
+		data, _ := testTorrent(t, strings.Repeat("n", i+1))
+		items = append(items, addItem{source: "t.torrent", data: data})
+	}
+	twice, _ := testTorrent(t, "twice")
+	rejected, _ := testTorrent(t, "rejected")
+	items = append(items,
+		addItem{source: "twice-1.torrent", data: twice},
+		addItem{source: "twice-2.torrent", data: twice},
+		addItem{source: "broken.torrent", data: []byte("not bencode")},
+		addItem{source: "rejected.torrent", data: rejected},
+		addItem{source: "magnet:?xt=urn:btih:nope", url: "magnet:?xt=urn:btih:nope"},
+	)
+
+	results := testAdd(client, items)
+	if len(results) != len(items) {
+		t.Fatalf("got %d results for %d items", len(results), len(items))
+	}
+	for i, res := range results {
+		if res.Source != items[i].source {
+			t.Errorf("result %d is for %s, want %s", i, res.Source, items[i].source)
+		}
+	}
+	for _, res := range results[:12] {
+		if res.Status != components.AddStatusAdded {
+			t.Errorf("%s: %+v, want added", res.Source, res)
+		}
+	}
+
+	// Either copy of the same torrent may win the race
+	statuses := []string{results[12].Status, results[13].Status}
+	if !reflect.DeepEqual(statuses, []string{components.AddStatusAdded, components.AddStatusDuplicate}) &&
+		!reflect.DeepEqual(statuses, []string{components.AddStatusDuplicate, components.AddStatusAdded}) {
+		t.Errorf("same torrent twice: %+v and %+v", results[12], results[13])
+	}
+	for _, res := range results[14:] {
+		if res.Status != components.AddStatusFailed || res.Reason == "" {
+			t.Errorf("%s: %+v, want failed with a reason", res.Source, res)
+		}
+	}
+
+	if client.peak < 2 || client.peak > addWorkers {
+		t.Errorf("%d adds ran at once, want 2 to %d", client.peak, addWorkers)
+	}
+	if n := client.added(); n != 13 {
+		t.Errorf("%d torrents added, want 13", n)
+	}
+}
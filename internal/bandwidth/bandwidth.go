@@ -0,0 +1,295 @@
+// Package bandwidth applies speed profiles like turtle mode to rTorrent and
+// keeps their state in the config so it survives restarts.
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+// Profile is a set of global limits. Rates are in bytes per second with 0
+// meaning unlimited; a MaxPeers of 0 leaves the peer limit unchanged.
+type Profile struct {
+	DownloadRate int64 `json:"download_rate"`
+	UploadRate   int64 `json:"upload_rate"`
+	MaxPeers     int64 `json:"max_peers"`
+}
+
+// TurtleStatus is what the UI needs to render the turtle toggle
+type TurtleStatus struct {
+	Enabled bool    `json:"enabled"`
+	Profile Profile `json:"profile"`
+}
+
+// Controller switches rTorrent between speed profiles
+type Controller struct {
+	mu     sync.Mutex
+	client rtorrent.Client
+	cfg    *config.Config
+	// labels is the label of every torrent as of the last label group
+	// assignment, to spot new torrents and label changes
+	labels map[string]string
+	// assignMu serializes label group passes, which run without mu
+	assignMu sync.Mutex
+	// groupsMu serializes pushing groups to rTorrent, which runs without
+	// mu
+	groupsMu sync.Mutex
+}
+
+func NewController(client rtorrent.Client, cfg *config.Config) *Controller {
+	return &Controller{client: client, cfg: cfg}
+}
+
+// SetClient swaps the rTorrent client, e.g. after setup connected to a
+// different instance.
+func (c *Controller) SetClient(client rtorrent.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.client = client
+	c.labels = nil
+}
+
+// Turtle returns whether turtle mode is on and its configured limits
+func (c *Controller) Turtle() TurtleStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return TurtleStatus{
+		Enabled: c.cfg.Bandwidth.Turtle.Enabled,
+		Profile: limitsProfile(c.cfg.Bandwidth.Turtle.LimitsConfig),
+	}
+}
+
+// SetTurtle switches turtle mode on or off. Switching on remembers the
+// current limits, switching off puts them back.
+func (c *Controller) SetTurtle(ctx context.Context, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setTurtle(ctx, enabled)
+}
+
+// setTurtle does the work of SetTurtle. Callers hold c.mu.
+func (c *Controller) setTurtle(ctx context.Context, enabled bool) error {
+	turtle := &c.cfg.Bandwidth.Turtle
+	if turtle.Enabled == enabled {
+		return nil
+	}
+
+	previous := turtle.Previous
+	if enabled {
+		current, err := c.current(ctx)
+		if err != nil {
+			return err
+		}
+		if err := c.apply(ctx, limitsProfile(turtle.LimitsConfig)); err != nil {
+			return err
+		}
+		previous = config.SpeedState(current)
+	} else {
+		if err := c.apply(ctx, Profile(turtle.Previous)); err != nil {
+			return err
+		}
+	}
+
+	if enabled {
+		log.Printf("Turtle mode enabled")
+	} else {
+		log.Printf("Turtle mode disabled")
+	}
+	return c.update(func() {
+		turtle.Enabled = enabled
+		turtle.Previous = previous
+	})
+}
+
+// SetTurtleProfile changes the turtle limits, applying them right away if
+// turtle mode is currently on.
+func (c *Controller) SetTurtleProfile(ctx context.Context, p Profile) error {
+	if p.DownloadRate < 0 || p.UploadRate < 0 || p.MaxPeers < 0 {
+		return fmt.Errorf("invalid turtle profile: values must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	turtle := &c.cfg.Bandwidth.Turtle
+	limits := profileLimits(p)
+	if turtle.Enabled {
+		if err := c.apply(ctx, limitsProfile(limits)); err != nil {
+			return err
+		}
+	}
+	return c.update(func() { turtle.LimitsConfig = limits })
+}
+
+// Restore re-applies turtle mode after connecting to rTorrent, which
+// forgets runtime throttle changes when it restarts.
+func (c *Controller) Restore(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Bandwidth.Turtle.Enabled {
+		return nil
+	}
+	return c.apply(ctx, limitsProfile(c.cfg.Bandwidth.Turtle.LimitsConfig))
+}
+
+// Client returns the rTorrent client profiles are applied to, nil while
+// the server is waiting for setup.
+func (c *Controller) Client() rtorrent.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.client
+}
+
+// Schedule returns a copy of the weekly schedule
+func (c *Controller) Schedule() config.ScheduleConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	schedule := c.cfg.Bandwidth.Schedule
+	schedule.Grid = append([]string(nil), schedule.Grid...)
+	return schedule
+}
+
+// SetSchedule validates and saves a new weekly schedule. Applying it is up
+// to the scheduler.
+func (c *Controller) SetSchedule(schedule config.ScheduleConfig) error {
+	if err := config.ValidateGrid(schedule.Grid); err != nil {
+		return err
+	}
+	custom := schedule.Custom
+	if custom.DownloadLimit < 0 || custom.UploadLimit < 0 || custom.MaxPeers < 0 {
+		return fmt.Errorf("invalid custom limits: values must not be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.update(func() { c.cfg.Bandwidth.Schedule = schedule })
+}
+
+// ApplySlot switches rTorrent to a scheduled profile. Turtle slots go
+// through turtle mode so the header reflects them; leaving one restores
+// the previous limits before the new slot's caps are applied. The limits
+// in effect before the first slot are saved and put back on unlimited
+// slots, max peers included. Limits are always re-sent since rTorrent may
+// have restarted in the meantime.
+func (c *Controller) ApplySlot(ctx context.Context, slot byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bw := &c.cfg.Bandwidth
+	turtle := &bw.Turtle
+	if bw.Unscheduled == nil {
+		// Turtle mode switched on by hand puts its previous limits back
+		// when a slot turns it off, so those are the ones to keep
+		base := turtle.Previous
+		if !turtle.Enabled {
+			current, err := c.current(ctx)
+			if err != nil {
+				return err
+			}
+			base = config.SpeedState(current)
+		}
+		if err := c.update(func() { bw.Unscheduled = &base }); err != nil {
+			return err
+		}
+	}
+
+	if slot == config.SlotTurtle {
+		if turtle.Enabled {
+			return c.apply(ctx, limitsProfile(turtle.LimitsConfig))
+		}
+		return c.setTurtle(ctx, true)
+	}
+
+	if turtle.Enabled {
+		if err := c.setTurtle(ctx, false); err != nil {
+			return err
+		}
+	}
+	if slot == config.SlotCustom {
+		return c.apply(ctx, limitsProfile(bw.Schedule.Custom))
+	}
+	return c.apply(ctx, Profile(*bw.Unscheduled))
+}
+
+// EndSchedule lifts the limits of the last scheduled slot after the
+// schedule was switched off, turning turtle mode off if it was on and
+// putting back the limits from before the schedule's first slot.
+func (c *Controller) EndSchedule(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bw := &c.cfg.Bandwidth
+	if bw.Turtle.Enabled {
+		if err := c.setTurtle(ctx, false); err != nil {
+			return err
+		}
+	}
+	var base Profile
+	if bw.Unscheduled != nil {
+		base = Profile(*bw.Unscheduled)
+	}
+	if err := c.apply(ctx, base); err != nil {
+		return err
+	}
+	return c.update(func() { bw.Unscheduled = nil })
+}
+
+// current reads the limits rTorrent is using right now
+func (c *Controller) current(ctx context.Context) (Profile, error) {
+	throttle, err := c.client.GetGlobalThrottle(ctx)
+	if err != nil {
+		return Profile{}, err
+	}
+	peers, err := c.client.GetMaxPeers(ctx)
+	if err != nil {
+		return Profile{}, err
+	}
+	return Profile{
+		DownloadRate: throttle.DownloadRate,
+		UploadRate:   throttle.UploadRate,
+		MaxPeers:     peers,
+	}, nil
+}
+
+func (c *Controller) apply(ctx context.Context, p Profile) error {
+	if err := c.client.SetGlobalThrottle(ctx, rtorrent.Throttle{
+		DownloadRate: p.DownloadRate,
+		UploadRate:   p.UploadRate,
+	}); err != nil {
+		return err
+	}
+	if p.MaxPeers > 0 {
+		return c.client.SetMaxPeers(ctx, p.MaxPeers)
+	}
+	return nil
+}
+
+// update makes a change to the config and saves it. Callers hold c.mu;
+// readers only need c.mu since every change also happens under it.
+func (c *Controller) update(change func()) error {
+	if err := config.Update(change); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+	return nil
+}
+
+func limitsProfile(l config.LimitsConfig) Profile {
+	return Profile{
+		DownloadRate: l.DownloadLimit * 1024,
+		UploadRate:   l.UploadLimit * 1024,
+		MaxPeers:     l.MaxPeers,
+	}
+}
+
+func profileLimits(p Profile) config.LimitsConfig {
+	return config.LimitsConfig{
+		DownloadLimit: ToKiB(p.DownloadRate),
+		UploadLimit:   ToKiB(p.UploadRate),
+		MaxPeers:      p.MaxPeers,
+	}
+}
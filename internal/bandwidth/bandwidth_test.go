@@ -0,0 +1,101 @@
+package bandwidth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+// loadTestConfig loads a default config from a temp dir, so controllers
+// built on it can save
+func loadTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestSetTurtleProfileBelowOneKiB(t *testing.T) {
+	ctx := context.Background()
+	client := rtorrent.NewClient("mock")
+	ctl := NewController(client, loadTestConfig(t))
+
+	if err := ctl.SetTurtleProfile(ctx, Profile{DownloadRate: 512, UploadRate: 512}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.SetTurtle(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	throttle, err := client.GetGlobalThrottle(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 0 would be unlimited
+	if throttle.DownloadRate != 1024 || throttle.UploadRate != 1024 {
+		t.Errorf("global throttle = %+v, want 1 KiB/s both ways", throttle)
+	}
+	if p := ctl.Turtle().Profile; p.DownloadRate != 1024 || p.UploadRate != 1024 {
+		t.Errorf("turtle profile = %+v, want 1 KiB/s both ways", p)
+	}
+}
+
+func TestSetTurtleRestoresLimits(t *testing.T) {
+	ctx := context.Background()
+	client := rtorrent.NewClient("mock")
+	ctl := NewController(client, loadTestConfig(t))
+
+	manual := rtorrent.Throttle{DownloadRate: 800 << 10, UploadRate: 200 << 10}
+	if err := client.SetGlobalThrottle(ctx, manual); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.SetTurtleProfile(ctx, Profile{DownloadRate: 50 << 10, UploadRate: 10 << 10, MaxPeers: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.SetTurtle(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	throttle, _ := client.GetGlobalThrottle(ctx)
+	peers, _ := client.GetMaxPeers(ctx)
+	if *throttle != (rtorrent.Throttle{DownloadRate: 50 << 10, UploadRate: 10 << 10}) || peers != 20 {
+		t.Fatalf("turtle on: throttle %+v, max peers %d", throttle, peers)
+	}
+
+	// Changing the profile while on applies it right away
+	if err := ctl.SetTurtleProfile(ctx, Profile{DownloadRate: 30 << 10, UploadRate: 10 << 10, MaxPeers: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if throttle, _ := client.GetGlobalThrottle(ctx); throttle.DownloadRate != 30<<10 {
+		t.Errorf("after profile change: download %d, want %d", throttle.DownloadRate, 30<<10)
+	}
+
+	if err := ctl.SetTurtle(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	throttle, _ = client.GetGlobalThrottle(ctx)
+	peers, _ = client.GetMaxPeers(ctx)
+	if *throttle != manual || peers != 100 {
+		t.Errorf("turtle off: throttle %+v, max peers %d, want %+v and 100", throttle, peers, manual)
+	}
+
+	// The state survives a reload of the config
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turtle := cfg.Bandwidth.Turtle; turtle.Enabled || turtle.DownloadLimit != 30 || turtle.MaxPeers != 20 {
+		t.Errorf("saved turtle = %+v", turtle)
+	}
+}
+
+func TestSetTurtleProfileNegative(t *testing.T) {
+	ctl := NewController(rtorrent.NewClient("mock"), loadTestConfig(t))
+	if err := ctl.SetTurtleProfile(context.Background(), Profile{UploadRate: -1}); err == nil {
+		t.Error("negative rate accepted")
+	}
+}
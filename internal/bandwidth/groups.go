@@ -0,0 +1,286 @@
+package bandwidth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+var (
+	// ErrUnknownGroup is returned when assigning a torrent to a throttle
+	// group that isn't configured.
+	ErrUnknownGroup = errors.New("unknown throttle group")
+	// ErrInvalidGroup is returned by SetGroups for bad group definitions
+	ErrInvalidGroup = errors.New("invalid throttle groups")
+)
+
+var groupNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Group is a named throttle as the API sees it, rates in bytes per second
+type Group struct {
+	Name         string `json:"name"`
+	DownloadRate int64  `json:"download_rate"`
+	UploadRate   int64  `json:"upload_rate"`
+}
+
+// LabelGroup puts torrents with Label into Group
+type LabelGroup struct {
+	Label string `json:"label"`
+	Group string `json:"group"`
+}
+
+// Groups returns the configured throttle groups and label mappings
+func (c *Controller) Groups() ([]Group, []LabelGroup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	groups := make([]Group, 0, len(c.cfg.Bandwidth.Groups))
+	for _, g := range c.cfg.Bandwidth.Groups {
+		groups = append(groups, Group{
+			Name:         g.Name,
+			DownloadRate: g.DownloadLimit * 1024,
+			UploadRate:   g.UploadLimit * 1024,
+		})
+	}
+	labels := make([]LabelGroup, 0, len(c.cfg.Bandwidth.LabelGroups))
+	for _, m := range c.cfg.Bandwidth.LabelGroups {
+		labels = append(labels, LabelGroup(m))
+	}
+	return groups, labels
+}
+
+// SetGroups replaces the throttle groups and label mappings. Groups are
+// pushed to rTorrent right away; groups that were removed are lifted and
+// their torrents go back under the global throttle.
+func (c *Controller) SetGroups(ctx context.Context, groups []Group, labels []LabelGroup) error {
+	names := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		if !groupNamePattern.MatchString(g.Name) || g.Name == "NULL" {
+			return fmt.Errorf("%w: group name %q must be up to 32 letters, digits, - or _", ErrInvalidGroup, g.Name)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("%w: duplicate group name %q", ErrInvalidGroup, g.Name)
+		}
+		if g.DownloadRate < 0 || g.UploadRate < 0 {
+			return fmt.Errorf("%w: rates of %q must not be negative", ErrInvalidGroup, g.Name)
+		}
+		names[g.Name] = true
+	}
+	seen := make(map[string]bool, len(labels))
+	for _, m := range labels {
+		if m.Label == "" {
+			return fmt.Errorf("%w: label mapping needs a label", ErrInvalidGroup)
+		}
+		if !names[m.Group] {
+			return fmt.Errorf("%w %q for label %q", ErrUnknownGroup, m.Group, m.Label)
+		}
+		if seen[m.Label] {
+			return fmt.Errorf("%w: label %q is mapped twice", ErrInvalidGroup, m.Label)
+		}
+		seen[m.Label] = true
+	}
+
+	// Group changes run their RPCs without c.mu; keep two of them from
+	// interleaving so rTorrent ends up with the last one saved
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	c.mu.Lock()
+	client := c.client
+	var removed []string
+	for _, g := range c.cfg.Bandwidth.Groups {
+		if !names[g.Name] {
+			removed = append(removed, g.Name)
+		}
+	}
+
+	configured := make([]config.ThrottleGroupConfig, 0, len(groups))
+	for _, g := range groups {
+		configured = append(configured, config.ThrottleGroupConfig{
+			Name:          g.Name,
+			DownloadLimit: ToKiB(g.DownloadRate),
+			UploadLimit:   ToKiB(g.UploadRate),
+		})
+	}
+	mapped := make([]config.LabelGroupConfig, 0, len(labels))
+	for _, m := range labels {
+		mapped = append(mapped, config.LabelGroupConfig(m))
+	}
+	err := c.update(func() {
+		c.cfg.Bandwidth.Groups = configured
+		c.cfg.Bandwidth.LabelGroups = mapped
+	})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return nil
+	}
+	if err := applyGroups(ctx, client, configured); err != nil {
+		return err
+	}
+	if err := liftGroups(ctx, client, removed); err != nil {
+		return err
+	}
+
+	// The mapping itself changed, so every labelled torrent follows it
+	return c.assignLabelGroups(ctx, true)
+}
+
+// AssignGroup puts a torrent into a configured group, or takes it out of
+// any group when name is empty.
+func (c *Controller) AssignGroup(ctx context.Context, hash, name string) error {
+	c.mu.Lock()
+	client := c.client
+	known := name == "" || c.hasGroup(name)
+	c.mu.Unlock()
+
+	if !known {
+		return fmt.Errorf("%w %q", ErrUnknownGroup, name)
+	}
+	return client.SetTorrentThrottle(ctx, hash, name)
+}
+
+// ApplyGroups pushes every configured group to rTorrent, which forgets
+// named throttles when it restarts.
+func (c *Controller) ApplyGroups(ctx context.Context) error {
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	c.mu.Lock()
+	client := c.client
+	groups := slices.Clone(c.cfg.Bandwidth.Groups)
+	c.mu.Unlock()
+	return applyGroups(ctx, client, groups)
+}
+
+// AssignLabelGroups moves torrents whose label is mapped to a group into
+// that group when they first show up or their label changes. A group
+// picked by hand afterwards sticks until the label changes again.
+func (c *Controller) AssignLabelGroups(ctx context.Context) error {
+	return c.assignLabelGroups(ctx, false)
+}
+
+func applyGroups(ctx context.Context, client rtorrent.Client, groups []config.ThrottleGroupConfig) error {
+	for _, g := range groups {
+		if err := client.SetThrottleGroup(ctx, g.Name, g.DownloadLimit*1024, g.UploadLimit*1024); err != nil {
+			return fmt.Errorf("throttle group %s: %w", g.Name, err)
+		}
+	}
+	return nil
+}
+
+// liftGroups makes removed groups unlimited and moves their torrents back
+// under the global throttle, since rTorrent can't delete a throttle.
+func liftGroups(ctx context.Context, client rtorrent.Client, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	removed := make(map[string]bool, len(names))
+	for _, name := range names {
+		removed[name] = true
+		if err := client.SetThrottleGroup(ctx, name, 0, 0); err != nil {
+			return fmt.Errorf("throttle group %s: %w", name, err)
+		}
+	}
+
+	torrents, err := client.GetTorrents(ctx)
+	if err != nil {
+		return err
+	}
+	for _, t := range torrents {
+		if removed[t.ThrottleName] {
+			if err := client.SetTorrentThrottle(ctx, t.Hash, ""); err != nil {
+				return fmt.Errorf("torrent %s: %w", t.Hash, err)
+			}
+		}
+	}
+	return nil
+}
+
+// assignLabelGroups moves labelled torrents into their group. Unless all
+// is set, only torrents not seen before that aren't in a group yet and
+// torrents whose label changed since the last call are moved: changing a
+// torrent's throttle restarts it, and rTorrent may not keep the name of a
+// group it doesn't know. Torrents already in their group are never
+// touched.
+//
+// c.mu is only held to read the mapping and store the labels: the pass
+// runs every scheduler tick and each move restarts a torrent, which would
+// block every other bandwidth call on a slow rTorrent.
+func (c *Controller) assignLabelGroups(ctx context.Context, all bool) error {
+	c.assignMu.Lock()
+	defer c.assignMu.Unlock()
+
+	c.mu.Lock()
+	client := c.client
+	mapping := config.BandwidthConfig{LabelGroups: slices.Clone(c.cfg.Bandwidth.LabelGroups)}
+	last := c.labels
+	c.mu.Unlock()
+	if client == nil || len(mapping.LabelGroups) == 0 {
+		return nil
+	}
+
+	torrents, err := client.GetTorrents(ctx)
+	if err != nil {
+		return err
+	}
+	labels := make(map[string]string, len(torrents))
+	for _, t := range torrents {
+		labels[t.Hash] = t.Label
+		group, ok := mapping.GroupForLabel(t.Label)
+		if !ok || t.ThrottleName == group {
+			continue
+		}
+		if !all {
+			label, seen := last[t.Hash]
+			if seen && label == t.Label || !seen && t.ThrottleName != "" {
+				continue
+			}
+		}
+		log.Printf("Moving torrent %s with label %q into throttle group %s", t.Hash, t.Label, group)
+		if err := client.SetTorrentThrottle(ctx, t.Hash, group); err != nil {
+			// Keep the old labels so the torrents still pending are
+			// retried next time
+			return fmt.Errorf("torrent %s: %w", t.Hash, err)
+		}
+	}
+
+	c.mu.Lock()
+	// SetClient resets the labels for a new instance; don't bring back
+	// the ones seen on the old one
+	if c.client == client {
+		c.labels = labels
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// ToKiB converts a rate in bytes per second to the KiB/s the config keeps,
+// rounding up so a limit under 1 KiB/s doesn't become 0, unlimited
+func ToKiB(rate int64) int64 {
+	return (rate + 1023) / 1024
+}
+
+// HasGroup reports whether a throttle group is configured
+func (c *Controller) HasGroup(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hasGroup(name)
+}
+
+func (c *Controller) hasGroup(name string) bool {
+	for _, g := range c.cfg.Bandwidth.Groups {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package bandwidth
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+func newTestController() (*Controller, rtorrent.Client) {
+	client := rtorrent.NewClient("mock")
+	cfg := &config.Config{}
+	cfg.Bandwidth.Groups = []config.ThrottleGroupConfig{{Name: "slow", DownloadLimit: 100}, {Name: "fast"}}
+	cfg.Bandwidth.LabelGroups = []config.LabelGroupConfig{{Label: "OS", Group: "slow"}}
+	return NewController(client, cfg), client
+}
+
+func throttleNames(t *testing.T, client rtorrent.Client) map[string]string {
+	t.Helper()
+	torrents, err := client.GetTorrents(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]string)
+	for _, torrent := range torrents {
+		names[torrent.Hash] = torrent.ThrottleName
+	}
+	return names
+}
+
+func TestAssignLabelGroupsKeepsManualGroup(t *testing.T) {
+	ctx := context.Background()
+	ctl, client := newTestController()
+
+	if err := ctl.AssignLabelGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	names := throttleNames(t, client)
+	if names["456"] != "slow" || names["789"] != "slow" || names["123"] != "" {
+		t.Fatalf("after first assignment: %v", names)
+	}
+
+	if err := ctl.AssignGroup(ctx, "456", "fast"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.AssignGroup(ctx, "789", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.AssignLabelGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	names = throttleNames(t, client)
+	if names["456"] != "fast" || names["789"] != "" {
+		t.Errorf("manual groups were overridden: %v", names)
+	}
+}
+
+func TestAssignLabelGroupsLeavesGroupedNewTorrents(t *testing.T) {
+	ctx := context.Background()
+	ctl, client := newTestController()
+	// Grouped before the controller first saw it, e.g. at add time
+	if err := client.SetTorrentThrottle(ctx, "456", "fast"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctl.AssignLabelGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	names := throttleNames(t, client)
+	if names["456"] != "fast" || names["789"] != "slow" {
+		t.Errorf("throttle names = %v", names)
+	}
+}
+
+func TestToKiB(t *testing.T) {
+	tests := map[int64]int64{0: 0, 1: 1, 1023: 1, 1024: 1, 1025: 2, 10240: 10}
+	for rate, want := range tests {
+		if got := ToKiB(rate); got != want {
+			t.Errorf("ToKiB(%d) = %d, want %d", rate, got, want)
+		}
+	}
+}
+
+// slowClient counts throttle moves and can hold GetTorrents until
+// released, like an rTorrent busy with something else
+type slowClient struct {
+	rtorrent.Client
+	// entered and release are nil unless GetTorrents should block
+	entered chan struct{}
+	release chan struct{}
+	once    sync.Once
+	moves   atomic.Int32
+}
+
+func (c *slowClient) GetTorrents(ctx context.Context) ([]rtorrent.Torrent, error) {
+	if c.entered != nil {
+		c.once.Do(func() { close(c.entered) })
+		<-c.release
+	}
+	return c.Client.GetTorrents(ctx)
+}
+
+func (c *slowClient) SetTorrentThrottle(ctx context.Context, hash, name string) error {
+	c.moves.Add(1)
+	return c.Client.SetTorrentThrottle(ctx, hash, name)
+}
+
+func TestAssignLabelGroupsSkipsGroupedTorrents(t *testing.T) {
+	ctx := context.Background()
+	ctl, mock := newTestController()
+	client := &slowClient{Client: mock}
+	ctl.SetClient(client)
+
+	if err := ctl.AssignLabelGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.moves.Load(); got != 2 {
+		t.Fatalf("first pass moved %d torrents, want 2", got)
+	}
+
+	// Later ticks, even a full pass, leave torrents already in their
+	// group alone since moving restarts them
+	client.moves.Store(0)
+	if err := ctl.AssignLabelGroups(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctl.assignLabelGroups(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.moves.Load(); got != 0 {
+		t.Errorf("later passes moved %d torrents, want 0", got)
+	}
+}
+
+func TestAssignLabelGroupsDoesNotHoldLock(t *testing.T) {
+	ctl, mock := newTestController()
+	client := &slowClient{Client: mock, entered: make(chan struct{}), release: make(chan struct{})}
+	ctl.SetClient(client)
+
+	done := make(chan error, 1)
+	go func() { done <- ctl.AssignLabelGroups(context.Background()) }()
+	<-client.entered
+
+	turtle := make(chan struct{})
+	go func() {
+		ctl.Turtle()
+		close(turtle)
+	}()
+	select {
+	case <-turtle:
+	case <-time.After(time.Second):
+		t.Error("Turtle blocked while a label group pass waited on rTorrent")
+	}
+	close(client.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if got := client.moves.Load(); got != 2 {
+		t.Errorf("moved %d torrents, want 2", got)
+	}
+}
+
+func TestSetGroupsDoesNotHoldLock(t *testing.T) {
+	ctx := context.Background()
+	mock := rtorrent.NewClient("mock")
+	cfg := loadTestConfig(t)
+	cfg.Bandwidth.Groups = []config.ThrottleGroupConfig{{Name: "slow", DownloadLimit: 100}, {Name: "fast"}}
+	ctl := NewController(mock, cfg)
+	if err := mock.SetTorrentThrottle(ctx, "456", "fast"); err != nil {
+		t.Fatal(err)
+	}
+	client := &slowClient{Client: mock, entered: make(chan struct{}), release: make(chan struct{})}
+	ctl.SetClient(client)
+
+	// Dropping fast lifts it, which lists the torrents in it
+	done := make(chan error, 1)
+	go func() {
+		done <- ctl.SetGroups(ctx, []Group{{Name: "slow", DownloadRate: 50 << 10}}, nil)
+	}()
+	<-client.entered
+
+	has := make(chan bool)
+	go func() { has <- ctl.HasGroup("slow") }()
+	select {
+	case ok := <-has:
+		if !ok {
+			t.Error("slow group missing while the change is pushed")
+		}
+	case <-time.After(time.Second):
+		t.Error("HasGroup blocked while SetGroups waited on rTorrent")
+	}
+	close(client.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if names := throttleNames(t, client); names["456"] != "" {
+		t.Errorf("torrent still in the removed group: %v", names)
+	}
+}
@@ -0,0 +1,286 @@
+// Package bencode encodes and decodes the bencoding used by .torrent files.
+//
+// Decoded values are int64, string, []interface{} and
+// map[string]interface{}. Encode accepts the same types plus every other
+// integer type, bool, []byte, []string and Raw.
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is returned for malformed input
+var ErrInvalid = errors.New("invalid bencode")
+
+// maxDepth bounds nesting so hostile input can't exhaust the stack
+const maxDepth = 128
+
+// Raw is a value that is already bencoded. Split returns dictionary
+// values as Raw so callers can hash them exactly as they appeared.
+type Raw []byte
+
+// Decode parses a single bencoded value that must span all of data
+func Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, d.errorf("trailing data")
+	}
+	return v, nil
+}
+
+// Split parses a bencoded dictionary without decoding its values, returning
+// each one in its original encoding.
+func Split(data []byte) (map[string]Raw, error) {
+	d := decoder{data: data}
+	if d.pos >= len(data) || data[d.pos] != 'd' {
+		return nil, d.errorf("expected a dictionary")
+	}
+	d.pos++
+
+	dict := make(map[string]Raw)
+	for {
+		if d.pos >= len(data) {
+			return nil, d.errorf("unterminated dictionary")
+		}
+		if data[d.pos] == 'e' {
+			d.pos++
+			break
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		start := d.pos
+		if _, err := d.value(1); err != nil {
+			return nil, err
+		}
+		dict[key] = Raw(data[start:d.pos])
+	}
+	if d.pos != len(data) {
+		return nil, d.errorf("trailing data")
+	}
+	return dict, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at offset %d: %s", ErrInvalid, d.pos, fmt.Sprintf(format, args...))
+}
+
+func (d *decoder) value(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, d.errorf("nested too deeply")
+	}
+	if d.pos >= len(d.data) {
+		return nil, d.errorf("unexpected end of data")
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		return d.int()
+	case c == 'l':
+		d.pos++
+		list := []interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, d.errorf("unterminated list")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return list, nil
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case c == 'd':
+		d.pos++
+		dict := map[string]interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, d.errorf("unterminated dictionary")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return dict, nil
+			}
+			key, err := d.string()
+			if err != nil {
+				return nil, err
+			}
+			v, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = v
+		}
+	case c >= '0' && c <= '9':
+		return d.string()
+	default:
+		return nil, d.errorf("unexpected byte %q", c)
+	}
+}
+
+func (d *decoder) int() (int64, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end < 0 {
+		return 0, d.errorf("unterminated integer")
+	}
+	digits := string(d.data[d.pos+1 : d.pos+end])
+	// Leading zeros, negative zero and a plus sign aren't allowed
+	if !isDigits(strings.TrimPrefix(digits, "-")) || digits == "-0" ||
+		(len(digits) > 1 && digits[0] == '0') ||
+		(len(digits) > 2 && digits[0] == '-' && digits[1] == '0') {
+		return 0, d.errorf("bad integer %q", digits)
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, d.errorf("bad integer %q", digits)
+	}
+	d.pos += end + 1
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", d.errorf("bad string length")
+	}
+	digits := string(d.data[d.pos : d.pos+colon])
+	n, err := strconv.Atoi(digits)
+	if err != nil || !isDigits(digits) || (len(digits) > 1 && digits[0] == '0') {
+		return "", d.errorf("bad string length %q", digits)
+	}
+	start := d.pos + colon + 1
+	if n > len(d.data)-start {
+		return "", d.errorf("string runs past end of data")
+	}
+	d.pos = start + n
+	return string(d.data[start:d.pos]), nil
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode bencodes v. Dictionary keys are written in sorted order as the
+// spec requires.
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		encodeInt(buf, int64(v))
+	case int64:
+		encodeInt(buf, v)
+	case int32:
+		encodeInt(buf, int64(v))
+	case int16:
+		encodeInt(buf, int64(v))
+	case int8:
+		encodeInt(buf, int64(v))
+	case uint:
+		encodeUint(buf, uint64(v))
+	case uint64:
+		encodeUint(buf, v)
+	case uint32:
+		encodeInt(buf, int64(v))
+	case uint16:
+		encodeInt(buf, int64(v))
+	case uint8:
+		encodeInt(buf, int64(v))
+	case bool:
+		// Flags like "private" are conventionally 0 or 1
+		if v {
+			encodeInt(buf, 1)
+		} else {
+			encodeInt(buf, 0)
+		}
+	case string:
+		encodeString(buf, v)
+	case []byte:
+		encodeString(buf, string(v))
+	case Raw:
+		buf.Write(v)
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range v {
+			encodeString(buf, s)
+		}
+		buf.WriteByte('e')
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('d')
+		for _, k := range keys {
+			encodeString(buf, k)
+			if err := encode(buf, v[k]); err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+		}
+		buf.WriteByte('e')
+	default:
+		return fmt.Errorf("bencode: unsupported type %T", v)
+	}
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, n int64) {
+	buf.WriteByte('i')
+	buf.WriteString(strconv.FormatInt(n, 10))
+	buf.WriteByte('e')
+}
+
+// encodeUint writes unsigned values that may not fit in an int64
+func encodeUint(buf *bytes.Buffer, n uint64) {
+	buf.WriteByte('i')
+	buf.WriteString(strconv.FormatUint(n, 10))
+	buf.WriteByte('e')
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
@@ -0,0 +1,152 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "int", in: "i42e", want: int64(42)},
+		{name: "zero", in: "i0e", want: int64(0)},
+		{name: "negative int", in: "i-7e", want: int64(-7)},
+		{name: "int64 max", in: "i9223372036854775807e", want: int64(9223372036854775807)},
+		{name: "string", in: "4:spam", want: "spam"},
+		{name: "empty string", in: "0:", want: ""},
+		{name: "binary string", in: "3:\x00\xff:", want: "\x00\xff:"},
+		{name: "list", in: "l4:spami1ee", want: []interface{}{"spam", int64(1)}},
+		{name: "empty list", in: "le", want: []interface{}{}},
+		{name: "dict", in: "d3:cow3:moo4:spaml1:a1:bee", want: map[string]interface{}{
+			"cow":  "moo",
+			"spam": []interface{}{"a", "b"},
+		}},
+
+		{name: "int leading zero", in: "i03e", wantErr: true},
+		{name: "negative zero", in: "i-0e", wantErr: true},
+		{name: "negative leading zero", in: "i-03e", wantErr: true},
+		{name: "int plus sign", in: "i+3e", wantErr: true},
+		{name: "empty int", in: "ie", wantErr: true},
+		{name: "lone minus", in: "i-e", wantErr: true},
+		{name: "int overflow", in: "i9223372036854775808e", wantErr: true},
+		{name: "unterminated int", in: "i42", wantErr: true},
+		{name: "length leading zero", in: "04:spam", wantErr: true},
+		{name: "negative length", in: "-1:a", wantErr: true},
+		{name: "length plus sign", in: "+1:a", wantErr: true},
+		{name: "missing colon", in: "4spam", wantErr: true},
+		{name: "truncated string", in: "5:spam", wantErr: true},
+		{name: "huge length", in: "99999999999999999999:a", wantErr: true},
+		{name: "unterminated list", in: "l4:spam", wantErr: true},
+		{name: "unterminated dict", in: "d3:cow3:moo", wantErr: true},
+		{name: "dict int key", in: "di1e3:mooe", wantErr: true},
+		{name: "dict missing value", in: "d3:cowe", wantErr: true},
+		{name: "trailing data", in: "i1ei2e", wantErr: true},
+		{name: "unknown type", in: "x", wantErr: true},
+		{name: "empty input", in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.in))
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("Decode(%q) = %#v, %v; want ErrInvalid", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode(%q) = %#v, want %#v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDepthLimit(t *testing.T) {
+	nested := func(depth int) []byte {
+		return []byte(strings.Repeat("l", depth) + strings.Repeat("e", depth))
+	}
+	if _, err := Decode(nested(maxDepth + 1)); err != nil {
+		t.Errorf("%d levels: %v", maxDepth+1, err)
+	}
+	if _, err := Decode(nested(maxDepth + 2)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("%d levels: err = %v, want ErrInvalid", maxDepth+2, err)
+	}
+	// Deep enough to overflow the stack without the limit
+	if _, err := Decode(nested(1 << 20)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("%d levels: err = %v, want ErrInvalid", 1<<20, err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	in := "d4:infod4:name1:ae3:numi-5e4:listl1:xee"
+	got, err := Split([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Raw{
+		"info": Raw("d4:name1:ae"),
+		"num":  Raw("i-5e"),
+		"list": Raw("l1:xe"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"", "le", "i1e", "d4:info", "d4:infoi1ee1:x", "d4:infoi01ee"} {
+		if _, err := Split([]byte(bad)); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Split(%q): err = %v, want ErrInvalid", bad, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	got, err := Encode(map[string]interface{}{
+		"zeta":    []string{"a", "bc"},
+		"alpha":   int32(-1),
+		"private": true,
+		"raw":     Raw("i7e"),
+		"nested":  []interface{}{[]byte("x"), map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "d5:alphai-1e6:nestedl1:xdee7:privatei1e3:rawi7e4:zetal1:a2:bcee"
+	if string(got) != want {
+		t.Errorf("Encode = %q, want %q", got, want)
+	}
+
+	ints, err := Encode([]interface{}{int8(-8), int16(-16), uint(1), uint8(8), uint16(16), uint64(1 << 63)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "li-8ei-16ei1ei8ei16ei9223372036854775808ee"; string(ints) != want {
+		t.Errorf("Encode = %q, want %q", ints, want)
+	}
+
+	if _, err := Encode(map[string]interface{}{"f": 1.5}); err == nil {
+		t.Error("expected an error for a float")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := "d4:infod5:filesld6:lengthi3e4:pathl1:a1:beee4:name3:dire3:numi-12ee"
+	v, err := Decode([]byte(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("round trip = %q, want %q", out, in)
+	}
+}
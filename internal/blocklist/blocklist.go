@@ -0,0 +1,371 @@
+// Package blocklist loads P2P blocklists into rTorrent's IP filter and keeps
+// them there across rTorrent restarts and scheduled reloads.
+package blocklist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/pathutil"
+	"rtorrent-go/internal/rtorrent"
+)
+
+// pollInterval is how often rTorrent is checked for restarts, which wipe
+// its IP filter, and the reload interval for expiry
+const pollInterval = 30 * time.Second
+
+var (
+	// ErrEmpty is returned for an import without a single usable range
+	ErrEmpty = errors.New("no IPv4 ranges found")
+	// ErrSourceNotAllowed is returned for a new source outside the
+	// blocklist directory and the download roots
+	ErrSourceNotAllowed = errors.New("blocklist source not allowed")
+	// ErrExists is returned when importing a list under the name of one
+	// already stored
+	ErrExists = errors.New("a blocklist with that name already exists")
+)
+
+// SourceStatus reports on one blocklist file
+type SourceStatus struct {
+	Path    string `json:"path"`
+	Ranges  int    `json:"ranges"`
+	Skipped int    `json:"skipped"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Status describes the last load
+type Status struct {
+	Enabled bool           `json:"enabled"`
+	Sources []SourceStatus `json:"sources"`
+	// Ranges is the number of ranges left after merging
+	Ranges    int    `json:"ranges"`
+	Addresses uint64 `json:"addresses"`
+	// Active is the number of entries in rTorrent's filter
+	Active   int64     `json:"active"`
+	LoadedAt time.Time `json:"loaded_at"`
+	Error    string    `json:"error,omitempty"`
+	// Loading is set while a requested reload hasn't finished yet
+	Loading bool `json:"loading"`
+}
+
+// Manager owns the blocklist settings and loads them into rTorrent
+type Manager struct {
+	// loadMu serializes loads, which can take a while for large lists
+	loadMu sync.Mutex
+
+	mu     sync.Mutex
+	client rtorrent.Client
+	cfg    *config.Config
+	status Status
+	// pid identifies the rTorrent process the lists were loaded into
+	pid    int64
+	reload chan struct{}
+	// requested counts reloads asked for through requestReload, loaded
+	// how many of them a finished load covered
+	requested, loaded int
+}
+
+func New(client rtorrent.Client, cfg *config.Config) *Manager {
+	return &Manager{
+		client: client,
+		cfg:    cfg,
+		reload: make(chan struct{}, 1),
+	}
+}
+
+// SetClient swaps the rTorrent client, e.g. after setup connected to a
+// different instance, and loads the lists into it
+func (m *Manager) SetClient(client rtorrent.Client) {
+	m.mu.Lock()
+	m.client = client
+	m.pid = 0
+	m.mu.Unlock()
+	m.requestReload()
+}
+
+// Settings returns the blocklist configuration
+func (m *Manager) Settings() config.BlocklistConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.cfg.Blocklist
+	b.Sources = slices.Clone(b.Sources)
+	return b
+}
+
+// SetSettings saves new settings and has Run reload the lists, returning
+// the status with Loading set until the load is done. rTorrent can't
+// remove entries from its filter, so dropping a source or disabling the
+// lists only takes full effect once rTorrent restarts.
+//
+// Sources not configured yet must lie in the blocklist directory or a
+// download root. FilterPath is overwritten on every load, so it can only
+// be changed in the config file and b.FilterPath is ignored.
+func (m *Manager) SetSettings(ctx context.Context, b config.BlocklistConfig) (Status, error) {
+	if b.ReloadInterval < 0 {
+		return Status{}, fmt.Errorf("reload interval must not be negative")
+	}
+
+	m.mu.Lock()
+	current := m.cfg.Blocklist
+	allowed := append([]string{Dir()}, m.cfg.Downloads.AllowedRoots()...)
+	m.mu.Unlock()
+
+	var sources []string
+	for _, s := range b.Sources {
+		if s = strings.TrimSpace(s); s == "" || slices.Contains(sources, s) {
+			continue
+		}
+		if !slices.Contains(current.Sources, s) && (!filepath.IsAbs(s) || !pathutil.Inside(s, allowed)) {
+			return Status{}, fmt.Errorf("%w: %s", ErrSourceNotAllowed, s)
+		}
+		sources = append(sources, s)
+	}
+	b.Sources = sources
+
+	m.mu.Lock()
+	b.FilterPath = m.cfg.Blocklist.FilterPath
+	err := config.Update(func() { m.cfg.Blocklist = b })
+	m.mu.Unlock()
+	if err != nil {
+		return Status{}, fmt.Errorf("save config: %w", err)
+	}
+	// Loading here would tie a push of many batches to the request, and a
+	// client going away halfway would leave a partial filter
+	m.requestReload()
+	return m.Status(ctx), nil
+}
+
+// Import stores an uploaded list next to the config and adds it to the
+// sources, loading it like SetSettings. A list already stored under the
+// same name is left alone and ErrExists returned.
+func (m *Manager) Import(ctx context.Context, name string, data []byte) (Status, error) {
+	res, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return Status{}, err
+	}
+	if len(res.Ranges) == 0 {
+		return Status{}, ErrEmpty
+	}
+
+	base := filepath.Base(name)
+	if base == "." || base == ".." || base == string(filepath.Separator) {
+		return Status{}, fmt.Errorf("%w: invalid file name %q", ErrSourceNotAllowed, name)
+	}
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Status{}, err
+	}
+	path := filepath.Join(dir, base)
+	// Never replace a stored list, which may be a source under another
+	// setting or be in use by a load
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return Status{}, fmt.Errorf("%w: %s", ErrExists, base)
+	}
+	if err != nil {
+		return Status{}, err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return Status{}, err
+	}
+
+	b := m.Settings()
+	if !slices.Contains(b.Sources, path) {
+		b.Sources = append(b.Sources, path)
+	}
+	return m.SetSettings(ctx, b)
+}
+
+// Dir is where imported lists are stored, next to the config
+func Dir() string {
+	return filepath.Join(config.Dir(), "blocklists")
+}
+
+// Status returns the result of the last load with rTorrent's current
+// entry count
+func (m *Manager) Status(ctx context.Context) Status {
+	m.mu.Lock()
+	client := m.client
+	status := m.status
+	status.Enabled = m.cfg.Blocklist.Enabled
+	status.Loading = m.loaded != m.requested
+	status.Sources = slices.Clone(status.Sources)
+	m.mu.Unlock()
+
+	if client != nil {
+		if n, err := client.IPFilterSize(ctx); err == nil {
+			status.Active = n
+		}
+	}
+	return status
+}
+
+// Reload reads every source again and pushes the merged ranges to
+// rTorrent
+func (m *Manager) Reload(ctx context.Context) error {
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	m.mu.Lock()
+	client := m.client
+	b := m.cfg.Blocklist
+	b.Sources = slices.Clone(b.Sources)
+	// Requests made from here on need another load to see their settings
+	requested := m.requested
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.loaded = requested
+		m.mu.Unlock()
+	}()
+	if client == nil || !b.Enabled {
+		return nil
+	}
+
+	status := Status{LoadedAt: time.Now()}
+	var ranges []Range
+	for _, path := range b.Sources {
+		source := SourceStatus{Path: path}
+		res, err := parseFile(path)
+		if err != nil {
+			source.Error = err.Error()
+			log.Printf("Blocklist %s: %v", path, err)
+		} else {
+			source.Ranges, source.Skipped = len(res.Ranges), res.Skipped
+			ranges = append(ranges, res.Ranges...)
+		}
+		status.Sources = append(status.Sources, source)
+	}
+	merged := Merge(ranges)
+	status.Ranges = len(merged)
+	status.Addresses = Count(merged)
+
+	err := push(ctx, client, b.FilterPath, merged)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		log.Printf("Blocklist: loaded %d ranges from %d source(s)", len(merged), len(b.Sources))
+	}
+	pid, _ := client.GetPID(ctx)
+
+	m.mu.Lock()
+	m.status = status
+	if err == nil {
+		m.pid = pid
+	}
+	m.mu.Unlock()
+	return err
+}
+
+// requestReload makes Run reload soon without blocking the caller
+func (m *Manager) requestReload() {
+	m.mu.Lock()
+	m.requested++
+	m.mu.Unlock()
+	select {
+	case m.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Run loads the lists, then reloads them when rTorrent restarts or the
+// reload interval passes, until ctx is cancelled
+func (m *Manager) Run(ctx context.Context) {
+	m.reloadLogged(ctx)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.reload:
+			m.reloadLogged(ctx)
+		case <-ticker.C:
+			if m.due(ctx) {
+				m.reloadLogged(ctx)
+			}
+		}
+	}
+}
+
+func (m *Manager) reloadLogged(ctx context.Context) {
+	if err := m.Reload(ctx); err != nil {
+		log.Printf("Blocklist: reload failed: %v", err)
+	}
+}
+
+// due reports whether rTorrent restarted since the last load or the
+// reload interval has passed
+func (m *Manager) due(ctx context.Context) bool {
+	m.mu.Lock()
+	client, pid := m.client, m.pid
+	b, loadedAt := m.cfg.Blocklist, m.status.LoadedAt
+	m.mu.Unlock()
+	if client == nil || !b.Enabled {
+		return false
+	}
+	if b.ReloadInterval > 0 && time.Since(loadedAt) >= b.ReloadInterval {
+		return true
+	}
+	current, err := client.GetPID(ctx)
+	return err == nil && current != pid
+}
+
+func parseFile(path string) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// push hands the ranges to rTorrent, through a file it loads itself when
+// filterPath is set and over XML-RPC otherwise
+func push(ctx context.Context, client rtorrent.Client, filterPath string, ranges []Range) error {
+	if filterPath == "" {
+		var blocks []string
+		for _, r := range ranges {
+			blocks = append(blocks, r.CIDRs()...)
+		}
+		return client.AddIPFilterBlocks(ctx, blocks)
+	}
+
+	// Write next to the target and rename, so rTorrent never reads a
+	// half-written list
+	tmp, err := os.CreateTemp(filepath.Dir(filterPath), ".blocklist-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := Write(tmp, ranges); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filterPath); err != nil {
+		return err
+	}
+	return client.LoadIPFilter(ctx, filterPath)
+}
@@ -0,0 +1,154 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+// newTestManager loads a fresh config from a temp dir, with a download
+// root next to it, and returns a manager without an rTorrent client
+func newTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(dir, "config.yaml"))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "downloads")
+	cfg.Downloads = config.DownloadsConfig{DefaultPath: root}
+	cfg.Blocklist.Sources = []string{"/etc/blocklists/level1.p2p"}
+	cfg.Blocklist.FilterPath = filepath.Join(dir, "filter.dat")
+	return New(nil, cfg), root
+}
+
+func TestSetSettingsRestrictsSources(t *testing.T) {
+	ctx := context.Background()
+	m, root := newTestManager(t)
+
+	for _, source := range []string{
+		"/etc/passwd",
+		filepath.Join(root, "..", "config.yaml"),
+		"relative.p2p",
+		Dir(),
+	} {
+		_, err := m.SetSettings(ctx, config.BlocklistConfig{Sources: []string{source}})
+		if !errors.Is(err, ErrSourceNotAllowed) {
+			t.Errorf("source %s: err = %v, want ErrSourceNotAllowed", source, err)
+		}
+	}
+
+	allowed := []string{
+		// Already configured in the config file
+		"/etc/blocklists/level1.p2p",
+		filepath.Join(root, "lists", "level1.p2p"),
+		filepath.Join(Dir(), "imported.dat"),
+	}
+	if _, err := m.SetSettings(ctx, config.BlocklistConfig{Sources: allowed}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Settings().Sources; !slices.Equal(got, allowed) {
+		t.Errorf("sources = %v, want %v", got, allowed)
+	}
+}
+
+func TestSetSettingsKeepsFilterPath(t *testing.T) {
+	m, _ := newTestManager(t)
+	want := m.Settings().FilterPath
+
+	_, err := m.SetSettings(context.Background(), config.BlocklistConfig{FilterPath: "/etc/passwd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Settings().FilterPath; got != want {
+		t.Errorf("filter path = %q, want %q", got, want)
+	}
+}
+
+func TestImportStaysInDir(t *testing.T) {
+	m, _ := newTestManager(t)
+	data := []byte("10.0.0.0/8\n")
+
+	if _, err := m.Import(context.Background(), "..", data); !errors.Is(err, ErrSourceNotAllowed) {
+		t.Errorf("err = %v, want ErrSourceNotAllowed", err)
+	}
+	if _, err := m.Import(context.Background(), "../../evil.p2p", data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(Dir(), "evil.p2p")
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("import not stored in the blocklist dir: %v", err)
+	}
+	if !slices.Contains(m.Settings().Sources, path) {
+		t.Errorf("sources = %v, want %s", m.Settings().Sources, path)
+	}
+}
+
+func TestImportKeepsExisting(t *testing.T) {
+	m, _ := newTestManager(t)
+	if _, err := m.Import(context.Background(), "level1.p2p", []byte("10.0.0.0/8\n")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.Import(context.Background(), "level1.p2p", []byte("192.168.0.0/16\n"))
+	if !errors.Is(err, ErrExists) {
+		t.Errorf("err = %v, want ErrExists", err)
+	}
+	data, err := os.ReadFile(filepath.Join(Dir(), "level1.p2p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "10.0.0.0/8\n" {
+		t.Errorf("stored list = %q, want the first import", data)
+	}
+}
+
+func TestSetSettingsLoadsInBackground(t *testing.T) {
+	m, root := newTestManager(t)
+	client := rtorrent.NewClient("mock")
+	m.SetClient(client)
+	m.cfg.Blocklist.FilterPath = ""
+	source := filepath.Join(root, "level1.cidr")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(source, []byte("10.0.0.0/8\n192.168.1.0/24\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The request going away must not cut the load short
+	ctx, cancel := context.WithCancel(context.Background())
+	status, err := m.SetSettings(ctx, config.BlocklistConfig{Enabled: true, Sources: []string{source}})
+	cancel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Loading || status.Active != 0 {
+		t.Fatalf("status = %+v, want loading with nothing pushed yet", status)
+	}
+
+	run, stop := context.WithCancel(context.Background())
+	defer stop()
+	go m.Run(run)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status = m.Status(context.Background())
+		if !status.Loading {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reload never finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Active != 2 || status.Ranges != 2 || status.Error != "" {
+		t.Errorf("status = %+v, want both ranges loaded", status)
+	}
+}
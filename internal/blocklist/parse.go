@@ -0,0 +1,222 @@
+package blocklist
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// emuleAllowed is the access level from which eMule .dat entries allow
+// rather than block a range
+const emuleAllowed = 128
+
+// Range is an inclusive IPv4 range
+type Range struct {
+	Start uint32
+	End   uint32
+}
+
+func (r Range) String() string {
+	return formatIP(r.Start) + "-" + formatIP(r.End)
+}
+
+// Result is what Parse found in a list
+type Result struct {
+	Ranges []Range
+	// Skipped counts lines that weren't blank, comments or ranges, e.g.
+	// IPv6 entries
+	Skipped int
+}
+
+// Parse reads a blocklist in eMule .dat, PeerGuardian .p2p or CIDR format.
+// The format is detected per line, so concatenated lists work too. Gzipped
+// input is decompressed.
+func Parse(r io.Reader) (*Result, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	res := &Result{}
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || strings.HasPrefix(line, "//") {
+			continue
+		}
+		r, ok, err := parseLine(line)
+		switch {
+		case err != nil:
+			res.Skipped++
+		case ok:
+			res.Ranges = append(res.Ranges, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// parseLine returns the range a line blocks. ok is false for eMule
+// entries whose access level allows the range.
+func parseLine(line string) (r Range, ok bool, err error) {
+	// eMule: "1.2.3.0 - 1.2.3.255 , 000 , description"
+	if fields := strings.SplitN(line, ",", 3); len(fields) >= 2 {
+		if r, err := parseSpan(fields[0]); err == nil {
+			level, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+			if err != nil {
+				return Range{}, false, fmt.Errorf("bad access level %q", fields[1])
+			}
+			return r, level < emuleAllowed, nil
+		}
+	}
+	switch {
+	case strings.Contains(line, ":"):
+		// PeerGuardian: "description:1.2.3.0-1.2.3.255". The description
+		// may contain colons and commas, the range can't.
+		r, err = parseSpan(line[strings.LastIndex(line, ":")+1:])
+	case strings.Contains(line, "/"):
+		r, err = parseCIDR(line)
+	default:
+		r, err = parseSpan(line)
+	}
+	return r, err == nil, err
+}
+
+// parseSpan reads "a.b.c.d - e.f.g.h" or a single address
+func parseSpan(s string) (Range, error) {
+	from, to, found := strings.Cut(s, "-")
+	start, err := parseIP(from)
+	if err != nil {
+		return Range{}, err
+	}
+	if !found {
+		return Range{Start: start, End: start}, nil
+	}
+	end, err := parseIP(to)
+	if err != nil {
+		return Range{}, err
+	}
+	if end < start {
+		return Range{}, fmt.Errorf("range %s ends before it starts", s)
+	}
+	return Range{Start: start, End: end}, nil
+}
+
+func parseCIDR(s string) (Range, error) {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		return Range{}, err
+	}
+	ip := network.IP.To4()
+	if ip == nil {
+		return Range{}, fmt.Errorf("%s is not IPv4", s)
+	}
+	ones, _ := network.Mask.Size()
+	start := binary.BigEndian.Uint32(ip)
+	return Range{Start: start, End: start | uint32(uint64(1)<<(32-ones)-1)}, nil
+}
+
+// parseIP reads a dotted IPv4 address. eMule lists zero-pad the octets
+// ("001.002.003.004"), which net.ParseIP rejects.
+func parseIP(s string) (uint32, error) {
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) != 4 {
+		return 0, fmt.Errorf("%q is not an IPv4 address", s)
+	}
+	var ip uint32
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an IPv4 address", s)
+		}
+		ip = ip<<8 | uint32(n)
+	}
+	return ip, nil
+}
+
+func formatIP(ip uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], ip)
+	return net.IP(b[:]).String()
+}
+
+// Merge sorts the ranges and joins overlapping and adjacent ones
+func Merge(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := append([]Range(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	merged := []Range{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		// Compare in 64 bits so a range ending at 255.255.255.255
+		// doesn't wrap around
+		if uint64(r.Start) <= uint64(last.End)+1 {
+			if r.End > last.End {
+				last.End = r.End
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// CIDRs splits the range into the fewest CIDR blocks covering it exactly
+func (r Range) CIDRs() []string {
+	var blocks []string
+	start, end := uint64(r.Start), uint64(r.End)
+	for start <= end {
+		// Largest block aligned at start that doesn't pass end
+		size := uint(32)
+		if start != 0 {
+			size = uint(bits.TrailingZeros32(uint32(start)))
+		}
+		for size > 0 && start+(uint64(1)<<size)-1 > end {
+			size--
+		}
+		blocks = append(blocks, formatIP(uint32(start))+"/"+strconv.Itoa(32-int(size)))
+		start += uint64(1) << size
+	}
+	return blocks
+}
+
+// Write writes one CIDR block per line, the format rTorrent's
+// ipv4_filter.load reads
+func Write(w io.Writer, ranges []Range) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range ranges {
+		for _, block := range r.CIDRs() {
+			if _, err := bw.WriteString(block + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Count returns how many addresses the ranges cover
+func Count(ranges []Range) uint64 {
+	var n uint64
+	for _, r := range ranges {
+		n += uint64(r.End) - uint64(r.Start) + 1
+	}
+	return n
+}
@@ -0,0 +1,234 @@
+package blocklist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func ip(a, b, c, d byte) uint32 {
+	return uint32(a)<<24 | uint32(b)<<16 | uint32(c)<<8 | uint32(d)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		in          string
+		want        []Range
+		wantSkipped int
+	}{
+		{
+			name: "emule",
+			in:   "001.002.003.000 - 001.002.003.255 , 000 , Some org\n",
+			want: []Range{{ip(1, 2, 3, 0), ip(1, 2, 3, 255)}},
+		},
+		{
+			name: "emule access levels",
+			in: "1.0.0.0 - 1.0.0.9 , 127 , blocked\n" +
+				"2.0.0.0 - 2.0.0.9 , 128 , allowed\n" +
+				"3.0.0.0 - 3.0.0.9 , 255 , allowed\n",
+			want: []Range{{ip(1, 0, 0, 0), ip(1, 0, 0, 9)}},
+		},
+		{
+			name:        "emule bad access level",
+			in:          "1.0.0.0 - 1.0.0.9 , high , x\n",
+			wantSkipped: 1,
+		},
+		{
+			name: "peerguardian",
+			in:   "Some org:1.2.3.0-1.2.3.255\n",
+			want: []Range{{ip(1, 2, 3, 0), ip(1, 2, 3, 255)}},
+		},
+		{
+			name: "peerguardian with colons and commas in the description",
+			in:   "Foo, Inc: mirror 2:4.5.6.7-4.5.6.8\n",
+			want: []Range{{ip(4, 5, 6, 7), ip(4, 5, 6, 8)}},
+		},
+		{
+			name: "cidr",
+			in:   "10.0.0.0/8\n192.168.1.7/32\n0.0.0.0/0\n",
+			want: []Range{
+				{ip(10, 0, 0, 0), ip(10, 255, 255, 255)},
+				{ip(192, 168, 1, 7), ip(192, 168, 1, 7)},
+				{0, ip(255, 255, 255, 255)},
+			},
+		},
+		{
+			name: "cidr with host bits set",
+			in:   "10.1.2.3/16\n",
+			want: []Range{{ip(10, 1, 0, 0), ip(10, 1, 255, 255)}},
+		},
+		{
+			name: "plain spans and addresses",
+			in:   "5.6.7.8\n 5.6.7.10 - 5.6.7.20 \n",
+			want: []Range{{ip(5, 6, 7, 8), ip(5, 6, 7, 8)}, {ip(5, 6, 7, 10), ip(5, 6, 7, 20)}},
+		},
+		{
+			name: "comments and blank lines",
+			in:   "# header\n// note\n\n   \n1.1.1.1\n",
+			want: []Range{{ip(1, 1, 1, 1), ip(1, 1, 1, 1)}},
+		},
+		{
+			name: "mixed formats",
+			in:   "1.0.0.0 - 1.0.0.255 , 0 , a\nb:2.0.0.0-2.0.0.255\n3.0.0.0/24\n",
+			want: []Range{
+				{ip(1, 0, 0, 0), ip(1, 0, 0, 255)},
+				{ip(2, 0, 0, 0), ip(2, 0, 0, 255)},
+				{ip(3, 0, 0, 0), ip(3, 0, 0, 255)},
+			},
+		},
+		{
+			name: "skipped lines",
+			in: "2001:db8::/32\n" +
+				"v6:2001:db8::1-2001:db8::2\n" +
+				"not an address\n" +
+				"1.2.3\n" +
+				"1.2.3.256\n" +
+				"1.2.3.9-1.2.3.1\n" +
+				"7.7.7.7\n",
+			want:        []Range{{ip(7, 7, 7, 7), ip(7, 7, 7, 7)}},
+			wantSkipped: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Parse(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Ranges, tt.want) {
+				t.Errorf("Ranges = %v, want %v", res.Ranges, tt.want)
+			}
+			if res.Skipped != tt.wantSkipped {
+				t.Errorf("Skipped = %d, want %d", res.Skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestParseGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("a:1.2.3.4-1.2.3.5\n"))
+	gz.Close()
+
+	res, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Range{{ip(1, 2, 3, 4), ip(1, 2, 3, 5)}}; !reflect.DeepEqual(res.Ranges, want) {
+		t.Errorf("Ranges = %v, want %v", res.Ranges, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	const top = 1<<32 - 1
+	tests := []struct {
+		name string
+		in   []Range
+		want []Range
+	}{
+		{name: "empty", in: nil, want: nil},
+		{name: "single", in: []Range{{5, 9}}, want: []Range{{5, 9}}},
+		{name: "unsorted and disjoint", in: []Range{{20, 30}, {1, 5}}, want: []Range{{1, 5}, {20, 30}}},
+		{name: "overlapping", in: []Range{{1, 10}, {5, 20}}, want: []Range{{1, 20}}},
+		{name: "adjacent", in: []Range{{11, 20}, {1, 10}}, want: []Range{{1, 20}}},
+		{name: "one apart", in: []Range{{1, 10}, {12, 20}}, want: []Range{{1, 10}, {12, 20}}},
+		{name: "contained", in: []Range{{1, 100}, {10, 20}, {30, 40}}, want: []Range{{1, 100}}},
+		{name: "duplicates", in: []Range{{3, 3}, {3, 3}}, want: []Range{{3, 3}}},
+		{
+			// last.End+1 would wrap to 0 in 32 bits and swallow the
+			// next range
+			name: "ending at the top",
+			in:   []Range{{top - 10, top}, {0, 0}},
+			want: []Range{{0, 0}, {top - 10, top}},
+		},
+		{name: "joined at the top", in: []Range{{top - 10, top}, {top - 20, top - 11}}, want: []Range{{top - 20, top}}},
+		{name: "everything", in: []Range{{0, top}, {7, 8}}, want: []Range{{0, top}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Merge(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeLeavesInputAlone(t *testing.T) {
+	in := []Range{{20, 30}, {1, 25}}
+	Merge(in)
+	if want := []Range{{20, 30}, {1, 25}}; !reflect.DeepEqual(in, want) {
+		t.Errorf("input changed to %v", in)
+	}
+}
+
+func TestCIDRs(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		want []string
+	}{
+		{name: "everything", r: Range{0, ip(255, 255, 255, 255)}, want: []string{"0.0.0.0/0"}},
+		{name: "single address", r: Range{ip(1, 2, 3, 4), ip(1, 2, 3, 4)}, want: []string{"1.2.3.4/32"}},
+		{name: "zero address", r: Range{0, 0}, want: []string{"0.0.0.0/32"}},
+		{name: "top address", r: Range{ip(255, 255, 255, 255), ip(255, 255, 255, 255)}, want: []string{"255.255.255.255/32"}},
+		{name: "aligned block", r: Range{ip(10, 0, 0, 0), ip(10, 255, 255, 255)}, want: []string{"10.0.0.0/8"}},
+		{
+			name: "unaligned",
+			r:    Range{ip(0, 0, 0, 1), ip(0, 0, 0, 6)},
+			want: []string{"0.0.0.1/32", "0.0.0.2/31", "0.0.0.4/31", "0.0.0.6/32"},
+		},
+		{
+			name: "ending at the top",
+			r:    Range{ip(255, 255, 255, 253), ip(255, 255, 255, 255)},
+			want: []string{"255.255.255.253/32", "255.255.255.254/31"},
+		},
+		{
+			name: "upper half from an odd start",
+			r:    Range{ip(127, 255, 255, 255), ip(255, 255, 255, 255)},
+			want: []string{"127.255.255.255/32", "128.0.0.0/1"},
+		},
+		{
+			name: "all but the top",
+			r:    Range{0, ip(255, 255, 255, 254)},
+			want: []string{
+				"0.0.0.0/1", "128.0.0.0/2", "192.0.0.0/3", "224.0.0.0/4",
+				"240.0.0.0/5", "248.0.0.0/6", "252.0.0.0/7", "254.0.0.0/8",
+				"255.0.0.0/9", "255.128.0.0/10", "255.192.0.0/11", "255.224.0.0/12",
+				"255.240.0.0/13", "255.248.0.0/14", "255.252.0.0/15", "255.254.0.0/16",
+				"255.255.0.0/17", "255.255.128.0/18", "255.255.192.0/19", "255.255.224.0/20",
+				"255.255.240.0/21", "255.255.248.0/22", "255.255.252.0/23", "255.255.254.0/24",
+				"255.255.255.0/25", "255.255.255.128/26", "255.255.255.192/27", "255.255.255.224/28",
+				"255.255.255.240/29", "255.255.255.248/30", "255.255.255.252/31", "255.255.255.254/32",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.CIDRs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%v.CIDRs() = %q, want %q", tt.r, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAndCount(t *testing.T) {
+	ranges := []Range{{ip(1, 2, 3, 4), ip(1, 2, 3, 4)}, {ip(0, 0, 0, 1), ip(0, 0, 0, 6)}}
+	var buf bytes.Buffer
+	if err := Write(&buf, ranges); err != nil {
+		t.Fatal(err)
+	}
+	want := "1.2.3.4/32\n0.0.0.1/32\n0.0.0.2/31\n0.0.0.4/31\n0.0.0.6/32\n"
+	if buf.String() != want {
+		t.Errorf("Write = %q, want %q", buf.String(), want)
+	}
+	if n := Count(ranges); n != 7 {
+		t.Errorf("Count = %d, want 7", n)
+	}
+	if n := Count([]Range{{0, 1<<32 - 1}}); n != 1<<32 {
+		t.Errorf("Count(everything) = %d, want %d", n, uint64(1)<<32)
+	}
+}
@@ -4,48 +4,242 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	RTorrent    RTorrentConfig    `mapstructure:"rtorrent"`
-	Server      ServerConfig      `mapstructure:"server"`
-	Downloads   DownloadsConfig   `mapstructure:"downloads"`
-	Preferences PreferencesConfig `mapstructure:"preferences"`
-	Security    SecurityConfig    `mapstructure:"security"`
+	RTorrent    RTorrentConfig    `mapstructure:"rtorrent" yaml:"rtorrent"`
+	Server      ServerConfig      `mapstructure:"server" yaml:"server"`
+	Downloads   DownloadsConfig   `mapstructure:"downloads" yaml:"downloads"`
+	Preferences PreferencesConfig `mapstructure:"preferences" yaml:"preferences"`
+	Security    SecurityConfig    `mapstructure:"security" yaml:"security"`
+	Bandwidth   BandwidthConfig   `mapstructure:"bandwidth" yaml:"bandwidth"`
+	Fetch       FetchConfig       `mapstructure:"fetch" yaml:"fetch"`
+	GeoIP       GeoIPConfig       `mapstructure:"geoip" yaml:"geoip"`
+	Blocklist   BlocklistConfig   `mapstructure:"blocklist" yaml:"blocklist"`
 }
 
 type RTorrentConfig struct {
-	Socket  string        `mapstructure:"socket"`
-	Timeout time.Duration `mapstructure:"timeout"`
+	Socket          string        `mapstructure:"socket" yaml:"socket"`
+	Timeout         time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	MaxResponseSize int64         `mapstructure:"max_response_size" yaml:"max_response_size"`
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Host string `mapstructure:"host"`
+	Port int    `mapstructure:"port" yaml:"port"`
+	Host string `mapstructure:"host" yaml:"host"`
 }
 
 type DownloadsConfig struct {
-	DefaultPath string `mapstructure:"default_path"`
-	TempPath    string `mapstructure:"temp_path"`
+	DefaultPath string   `mapstructure:"default_path" yaml:"default_path"`
+	TempPath    string   `mapstructure:"temp_path" yaml:"temp_path"`
+	Roots       []string `mapstructure:"roots" yaml:"roots"`
+}
+
+// AllowedRoots returns every directory VibeTorrent may modify on disk:
+// the default and temp paths plus any extra configured roots.
+func (d DownloadsConfig) AllowedRoots() []string {
+	var roots []string
+	for _, root := range append([]string{d.DefaultPath, d.TempPath}, d.Roots...) {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
 }
 
 type PreferencesConfig struct {
-	Theme           string        `mapstructure:"theme"`
-	ItemsPerPage    int           `mapstructure:"items_per_page"`
-	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	Theme           string        `mapstructure:"theme" yaml:"theme"`
+	ItemsPerPage    int           `mapstructure:"items_per_page" yaml:"items_per_page"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval" yaml:"refresh_interval"`
 }
 
 type SecurityConfig struct {
-	AuthEnabled  bool   `mapstructure:"auth_enabled"`
-	Username     string `mapstructure:"username"`
-	PasswordHash string `mapstructure:"password_hash"`
+	AuthEnabled  bool   `mapstructure:"auth_enabled" yaml:"auth_enabled"`
+	Username     string `mapstructure:"username" yaml:"username"`
+	PasswordHash string `mapstructure:"password_hash" yaml:"password_hash"`
+}
+
+type BandwidthConfig struct {
+	Turtle   TurtleConfig   `mapstructure:"turtle" yaml:"turtle"`
+	Schedule ScheduleConfig `mapstructure:"schedule" yaml:"schedule"`
+	// Unscheduled holds the limits that were in effect before the schedule
+	// applied its first slot. Unlimited slots put them back, as does
+	// switching the schedule off, which clears them.
+	Unscheduled *SpeedState `mapstructure:"unscheduled" yaml:"unscheduled,omitempty"`
+	// Groups are named throttles torrents can be assigned to
+	Groups []ThrottleGroupConfig `mapstructure:"groups" yaml:"groups"`
+	// LabelGroups puts every torrent with a label into a group
+	LabelGroups []LabelGroupConfig `mapstructure:"label_groups" yaml:"label_groups"`
+}
+
+// ThrottleGroupConfig is a named throttle. Rates are in KB/s, 0 means
+// unlimited.
+type ThrottleGroupConfig struct {
+	Name          string `mapstructure:"name" yaml:"name"`
+	DownloadLimit int64  `mapstructure:"download_limit" yaml:"download_limit"`
+	UploadLimit   int64  `mapstructure:"upload_limit" yaml:"upload_limit"`
+}
+
+type LabelGroupConfig struct {
+	Label string `mapstructure:"label" yaml:"label"`
+	Group string `mapstructure:"group" yaml:"group"`
+}
+
+// GroupForLabel returns the throttle group torrents with label belong in
+func (b BandwidthConfig) GroupForLabel(label string) (string, bool) {
+	for _, m := range b.LabelGroups {
+		if m.Label == label {
+			return m.Group, true
+		}
+	}
+	return "", false
+}
+
+// LimitsConfig is a set of speed caps. Rates are in KB/s and 0 means
+// unlimited; a MaxPeers of 0 leaves rTorrent's peer limit alone.
+type LimitsConfig struct {
+	DownloadLimit int64 `mapstructure:"download_limit" yaml:"download_limit"`
+	UploadLimit   int64 `mapstructure:"upload_limit" yaml:"upload_limit"`
+	MaxPeers      int64 `mapstructure:"max_peers" yaml:"max_peers"`
+}
+
+// TurtleConfig is the alternate speed profile
+type TurtleConfig struct {
+	Enabled      bool `mapstructure:"enabled" yaml:"enabled"`
+	LimitsConfig `mapstructure:",squash" yaml:",inline"`
+	// Previous holds the values that were in effect before turtle mode was
+	// switched on, so they can be restored even after a restart.
+	Previous SpeedState `mapstructure:"previous" yaml:"previous"`
+}
+
+// Schedule slot profiles, one character per hour in ScheduleConfig.Grid
+const (
+	SlotUnlimited = 'u'
+	SlotTurtle    = 't'
+	SlotCustom    = 'c'
+)
+
+// ScheduleConfig maps every hour of the week to a speed profile
+type ScheduleConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Grid has one row per weekday starting with Monday and one character
+	// per hour: u (unlimited, i.e. the limits set outside the schedule),
+	// t (turtle) or c (custom).
+	Grid   []string     `mapstructure:"grid" yaml:"grid"`
+	Custom LimitsConfig `mapstructure:"custom" yaml:"custom"`
+}
+
+// SlotAt returns the profile scheduled for t. Missing or unknown cells
+// count as unlimited.
+func (s ScheduleConfig) SlotAt(t time.Time) byte {
+	day := (int(t.Weekday()) + 6) % 7
+	if day >= len(s.Grid) || t.Hour() >= len(s.Grid[day]) {
+		return SlotUnlimited
+	}
+	switch slot := s.Grid[day][t.Hour()]; slot {
+	case SlotTurtle, SlotCustom:
+		return slot
+	}
+	return SlotUnlimited
+}
+
+// ValidateGrid checks the grid has 7 rows of 24 known slots
+func ValidateGrid(grid []string) error {
+	if len(grid) != 7 {
+		return fmt.Errorf("schedule grid needs 7 rows, got %d", len(grid))
+	}
+	for i, row := range grid {
+		if len(row) != 24 {
+			return fmt.Errorf("schedule row %d needs 24 slots, got %d", i+1, len(row))
+		}
+		for _, slot := range []byte(row) {
+			if slot != SlotUnlimited && slot != SlotTurtle && slot != SlotCustom {
+				return fmt.Errorf("schedule row %d has unknown slot %q", i+1, slot)
+			}
+		}
+	}
+	return nil
+}
+
+// SpeedState is a snapshot of rTorrent's global limits in bytes per second
+type SpeedState struct {
+	DownloadRate int64 `mapstructure:"download_rate" yaml:"download_rate"`
+	UploadRate   int64 `mapstructure:"upload_rate" yaml:"upload_rate"`
+	MaxPeers     int64 `mapstructure:"max_peers" yaml:"max_peers"`
+}
+
+// FetchConfig controls how VibeTorrent downloads http(s) .torrent URLs
+// itself instead of leaving it to rTorrent
+type FetchConfig struct {
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	// MaxSize is the largest .torrent accepted, in bytes
+	MaxSize int64        `mapstructure:"max_size" yaml:"max_size"`
+	Sites   []SiteConfig `mapstructure:"sites" yaml:"sites"`
+}
+
+// SiteConfig holds what is sent along when fetching from a domain and its
+// subdomains, e.g. the login cookie of a private tracker
+type SiteConfig struct {
+	Domain string `mapstructure:"domain" yaml:"domain"`
+	// Cookie is sent as the Cookie header, e.g. "uid=1; pass=abc"
+	Cookie  string            `mapstructure:"cookie" yaml:"cookie"`
+	Headers map[string]string `mapstructure:"headers" yaml:"headers"`
+}
+
+// SiteFor returns the site settings for host. The most specific domain
+// wins, so a subdomain can override its parent.
+func (f FetchConfig) SiteFor(host string) (SiteConfig, bool) {
+	host = strings.ToLower(host)
+	var best SiteConfig
+	found := false
+	for _, site := range f.Sites {
+		domain := strings.ToLower(strings.TrimPrefix(site.Domain, "."))
+		if domain == "" || (host != domain && !strings.HasSuffix(host, "."+domain)) {
+			continue
+		}
+		if !found || len(domain) > len(best.Domain) {
+			best, found = site, true
+			best.Domain = domain
+		}
+	}
+	return best, found
+}
+
+// GeoIPConfig points at a local MaxMind format country database used to
+// show where peers are. Lookups are disabled when Database is empty.
+type GeoIPConfig struct {
+	Database string `mapstructure:"database" yaml:"database"`
+}
+
+// BlocklistConfig holds the P2P blocklists pushed into rTorrent's IP filter
+type BlocklistConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// Sources are blocklist files on this machine in eMule .dat,
+	// PeerGuardian .p2p or CIDR format, optionally gzipped. Sources added
+	// through the API must be in the blocklists directory next to the
+	// config or in a download root.
+	Sources []string `mapstructure:"sources" yaml:"sources"`
+	// FilterPath is where the merged list is written for rTorrent to load,
+	// so it must be readable by rTorrent. When empty the ranges are sent
+	// over XML-RPC instead. It is only read from the config file.
+	FilterPath string `mapstructure:"filter_path" yaml:"filter_path"`
+	// ReloadInterval re-reads the sources periodically; 0 only loads them
+	// at startup, when rTorrent restarts and on demand
+	ReloadInterval time.Duration `mapstructure:"reload_interval" yaml:"reload_interval"`
 }
 
 var AppConfig *Config
 
+// mu serializes changes to AppConfig with writing it out. Settings owned
+// by different packages share AppConfig, viper and the file, so each
+// package's own lock isn't enough.
+var mu sync.Mutex
+
 // getConfigPath returns the path to the config file
 func getConfigPath() string {
 	// Try environment variable first
@@ -68,6 +262,12 @@ func getConfigPath() string {
 	return "./config.yaml"
 }
 
+// Dir returns the directory holding the config file, where files managed
+// by VibeTorrent itself are kept too
+func Dir() string {
+	return filepath.Dir(getConfigPath())
+}
+
 // LoadConfig loads configuration from file and environment variables
 func LoadConfig() (*Config, error) {
 	configPath := getConfigPath()
@@ -78,6 +278,9 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	// Values saved by an earlier load would otherwise shadow the file
+	viper.Reset()
+
 	// Set default values
 	setDefaults()
 
@@ -127,6 +330,7 @@ func setDefaults() {
 	// RTorrent defaults
 	viper.SetDefault("rtorrent.socket", "")
 	viper.SetDefault("rtorrent.timeout", "30s")
+	viper.SetDefault("rtorrent.max_response_size", 32*1024*1024)
 
 	// Server defaults
 	viper.SetDefault("server.port", 8080)
@@ -135,6 +339,7 @@ func setDefaults() {
 	// Downloads defaults
 	viper.SetDefault("downloads.default_path", "/downloads")
 	viper.SetDefault("downloads.temp_path", "/downloads/incomplete")
+	viper.SetDefault("downloads.roots", []string{})
 
 	// Preferences defaults
 	viper.SetDefault("preferences.theme", "dark")
@@ -145,6 +350,33 @@ func setDefaults() {
 	viper.SetDefault("security.auth_enabled", false)
 	viper.SetDefault("security.username", "admin")
 	viper.SetDefault("security.password_hash", "")
+
+	// Bandwidth defaults
+	viper.SetDefault("bandwidth.turtle.enabled", false)
+	viper.SetDefault("bandwidth.turtle.download_limit", 500)
+	viper.SetDefault("bandwidth.turtle.upload_limit", 100)
+	viper.SetDefault("bandwidth.turtle.max_peers", 20)
+	viper.SetDefault("bandwidth.schedule.enabled", false)
+	viper.SetDefault("bandwidth.schedule.grid", []string{})
+	viper.SetDefault("bandwidth.schedule.custom.download_limit", 2048)
+	viper.SetDefault("bandwidth.schedule.custom.upload_limit", 512)
+	viper.SetDefault("bandwidth.schedule.custom.max_peers", 0)
+	viper.SetDefault("bandwidth.groups", []interface{}{})
+	viper.SetDefault("bandwidth.label_groups", []interface{}{})
+
+	// Fetch defaults
+	viper.SetDefault("fetch.timeout", "30s")
+	viper.SetDefault("fetch.max_size", 10*1024*1024)
+	viper.SetDefault("fetch.sites", []interface{}{})
+
+	// GeoIP defaults
+	viper.SetDefault("geoip.database", "")
+
+	// Blocklist defaults
+	viper.SetDefault("blocklist.enabled", false)
+	viper.SetDefault("blocklist.sources", []string{})
+	viper.SetDefault("blocklist.filter_path", "")
+	viper.SetDefault("blocklist.reload_interval", "24h")
 }
 
 // createDefaultConfig creates a default configuration file
@@ -160,6 +392,8 @@ rtorrent:
   #   - tcp://localhost:5000
   socket: ""
   timeout: 30s
+  # Largest XML-RPC response accepted, in bytes (32 MB)
+  max_response_size: 33554432
 
 # Web Server Settings
 server:
@@ -170,6 +404,9 @@ server:
 downloads:
   default_path: "/downloads"
   temp_path: "/downloads/incomplete"
+  # Extra directories VibeTorrent may delete or move data in.
+  # default_path and temp_path are always allowed.
+  roots: []
 
 # UI Preferences
 preferences:
@@ -177,6 +414,69 @@ preferences:
   items_per_page: 50
   refresh_interval: 2s
 
+# Bandwidth Settings
+bandwidth:
+  # Alternate "turtle mode" profile, toggled from the dashboard header.
+  # Limits are in KB/s (0 = unlimited), max_peers 0 keeps the current value.
+  turtle:
+    enabled: false
+    download_limit: 500
+    upload_limit: 100
+    max_peers: 20
+  # Weekly schedule, one row per day starting Monday, one letter per hour:
+  # u = unlimited, t = turtle, c = custom limits below.
+  schedule:
+    enabled: false
+    grid: []
+    custom:
+      download_limit: 2048
+      upload_limit: 512
+      max_peers: 0
+  # Named throttle groups (KB/s, 0 = unlimited) and labels whose torrents
+  # are put into a group automatically, e.g.
+  #   groups:
+  #     - name: backups
+  #       download_limit: 2048
+  #       upload_limit: 2048
+  #   label_groups:
+  #     - label: Backups
+  #       group: backups
+  groups: []
+  label_groups: []
+
+# Fetching .torrent URLs
+# http(s) links are downloaded by VibeTorrent, which sends the cookie and
+# headers of the matching site (subdomains included), e.g.
+#   sites:
+#     - domain: tracker.example.org
+#       cookie: "uid=1234; pass=secret"
+#       headers:
+#         Authorization: "Bearer token"
+fetch:
+  timeout: 30s
+  # Largest .torrent accepted, in bytes (10 MB)
+  max_size: 10485760
+  sites: []
+
+# Peer Countries
+# Path to a MaxMind format country database (.mmdb), e.g. GeoLite2-Country
+# or DB-IP Lite. Lookups are done locally; leave empty to disable.
+geoip:
+  database: ""
+
+# IP Blocklists
+# Files in eMule .dat, PeerGuardian .p2p or CIDR format (gzip is fine),
+# merged and pushed into rTorrent's IP filter. Sources added from the UI
+# must be inside a download folder or the blocklists folder next to this
+# file. filter_path is where the merged list is written for rTorrent to
+# load and must be readable by it; leave empty to send the ranges over
+# XML-RPC instead. It can only be set here.
+blocklist:
+  enabled: false
+  sources: []
+  filter_path: ""
+  reload_interval: 24h
+
 # Security Settings (Future feature)
 security:
   auth_enabled: false
@@ -194,6 +494,22 @@ security:
 
 // SaveConfig saves the current configuration to file
 func SaveConfig() error {
+	mu.Lock()
+	defer mu.Unlock()
+	return save()
+}
+
+// Update runs change, which modifies AppConfig, and saves the result while
+// holding the config lock. Changes made at runtime go through Update so
+// concurrent saves never see a half-applied change.
+func Update(change func()) error {
+	mu.Lock()
+	defer mu.Unlock()
+	change()
+	return save()
+}
+
+func save() error {
 	if AppConfig == nil {
 		return fmt.Errorf("no config loaded")
 	}
@@ -203,6 +519,10 @@ func SaveConfig() error {
 	viper.Set("downloads", AppConfig.Downloads)
 	viper.Set("preferences", AppConfig.Preferences)
 	viper.Set("security", AppConfig.Security)
+	viper.Set("bandwidth", AppConfig.Bandwidth)
+	viper.Set("fetch", AppConfig.Fetch)
+	viper.Set("geoip", AppConfig.GeoIP)
+	viper.Set("blocklist", AppConfig.Blocklist)
 
 	return viper.WriteConfig()
 }
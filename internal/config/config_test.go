@@ -0,0 +1,58 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpdateConcurrentSaves(t *testing.T) {
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two sections of the config changed at the same time, plus plain
+	// saves like the setup page does
+	const rounds = 20
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= rounds; i++ {
+			if err := Update(func() { cfg.Bandwidth.Turtle.DownloadLimit = int64(i) }); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= rounds; i++ {
+			if err := Update(func() { cfg.Preferences.RefreshInterval = time.Duration(i) * time.Second }); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if err := SaveConfig(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	reloaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Bandwidth.Turtle.DownloadLimit; got != rounds {
+		t.Errorf("turtle download limit = %d, want %d", got, rounds)
+	}
+	if got := reloaded.Preferences.RefreshInterval; got != rounds*time.Second {
+		t.Errorf("refresh interval = %v, want %v", got, rounds*time.Second)
+	}
+}
@@ -0,0 +1,154 @@
+// Package fetch downloads remote .torrent files on the server, so private
+// trackers get the cookies and headers configured for them and failures can
+// be reported instead of disappearing inside rTorrent.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/metainfo"
+)
+
+const (
+	defaultTimeout = 30 * time.Second
+	defaultMaxSize = 10 << 20
+	// maxRedirects matches net/http's own limit
+	maxRedirects = 10
+)
+
+var (
+	// ErrUnsupported is returned for URLs that aren't http(s)
+	ErrUnsupported = errors.New("only http and https URLs can be fetched")
+	// ErrStatus is returned when the site answers with an error status
+	ErrStatus = errors.New("download refused")
+	// ErrTooLarge is returned when the response exceeds the size limit
+	ErrTooLarge = errors.New("download too large")
+	// ErrNotTorrent is returned when the response isn't a .torrent file
+	ErrNotTorrent = errors.New("not a torrent file")
+)
+
+// Fetcher downloads .torrent files
+type Fetcher struct {
+	client  *http.Client
+	cfg     config.FetchConfig
+	maxSize int64
+}
+
+func New(cfg config.FetchConfig) *Fetcher {
+	f := &Fetcher{cfg: cfg, maxSize: cfg.MaxSize}
+	if f.maxSize <= 0 {
+		f.maxSize = defaultMaxSize
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			// net/http forwards the first request's headers to wherever
+			// the redirect points, only dropping the cookie, so strip what
+			// was configured for that site before adding the new site's
+			from, ok := f.cfg.SiteFor(via[0].URL.Hostname())
+			if to, _ := f.cfg.SiteFor(req.URL.Hostname()); ok && to.Domain != from.Domain {
+				for name := range from.Headers {
+					req.Header.Del(name)
+				}
+				if from.Cookie != "" {
+					req.Header.Del("Cookie")
+				}
+			}
+			f.authorize(req)
+			return nil
+		},
+	}
+	return f
+}
+
+// IsFetchable reports whether rawURL is one the fetcher handles
+func IsFetchable(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Fetch downloads rawURL and checks that it is a valid .torrent
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	if !IsFetchable(rawURL) {
+		return nil, ErrUnsupported
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	req.Header.Set("Accept", "application/x-bittorrent, */*;q=0.8")
+	req.Header.Set("User-Agent", "VibeTorrent")
+	f.authorize(req)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		hint := ""
+		switch resp.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			hint = fmt.Sprintf(" (check the cookie configured for %s)", req.URL.Hostname())
+		}
+		return nil, fmt.Errorf("%w: %s answered %s%s", ErrStatus, req.URL.Host, resp.Status, hint)
+	}
+	if resp.ContentLength > f.maxSize {
+		return nil, fmt.Errorf("%w: %d bytes, limit is %d", ErrTooLarge, resp.ContentLength, f.maxSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", req.URL.Host, err)
+	}
+	if int64(len(data)) > f.maxSize {
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrTooLarge, f.maxSize)
+	}
+
+	if _, err := metainfo.Parse(data); err != nil {
+		if looksLikeHTML(resp.Header.Get("Content-Type"), data) {
+			// Usually a login page served in place of the file
+			return nil, fmt.Errorf("%w: %s sent a web page, the site may need a login cookie", ErrNotTorrent, req.URL.Hostname())
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNotTorrent, err)
+	}
+	return data, nil
+}
+
+// authorize adds the cookie and headers configured for the request's host
+func (f *Fetcher) authorize(req *http.Request) {
+	site, ok := f.cfg.SiteFor(req.URL.Hostname())
+	if !ok {
+		return
+	}
+	for name, value := range site.Headers {
+		req.Header.Set(name, value)
+	}
+	if site.Cookie != "" {
+		req.Header.Set("Cookie", site.Cookie)
+	}
+}
+
+func looksLikeHTML(contentType string, data []byte) bool {
+	if strings.HasPrefix(contentType, "text/html") {
+		return true
+	}
+	head := bytes.ToLower(bytes.TrimSpace(data[:min(len(data), 512)]))
+	return bytes.HasPrefix(head, []byte("<!doctype html")) || bytes.HasPrefix(head, []byte("<html"))
+}
@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"rtorrent-go/internal/bencode"
+	"rtorrent-go/internal/config"
+)
+
+func testTorrent(t *testing.T) []byte {
+	t.Helper()
+	data, err := bencode.Encode(map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "a",
+			"length":       1,
+			"piece length": 16,
+			"pieces":       strings.Repeat("x", 20),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestRedirectHeaders(t *testing.T) {
+	torrent := testTorrent(t)
+	var seen http.Header
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+		w.Write(torrent)
+	}))
+	defer target.Close()
+
+	// The tracker is reached as 127.0.0.1 and redirects to localhost, so
+	// the two count as different sites
+	targetURL, _ := url.Parse(target.URL)
+	targetURL.Host = "localhost:" + targetURL.Port()
+	tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, targetURL.String(), http.StatusFound)
+			return
+		}
+		seen = r.Header.Clone()
+		w.Write(torrent)
+	}))
+	defer tracker.Close()
+
+	trackerSite := config.SiteConfig{
+		Domain:  "127.0.0.1",
+		Cookie:  "uid=1; pass=secret",
+		Headers: map[string]string{"X-Passkey": "secret"},
+	}
+	tests := []struct {
+		name  string
+		sites []config.SiteConfig
+		path  string
+		want  map[string]string
+	}{
+		{
+			name:  "no redirect",
+			sites: []config.SiteConfig{trackerSite},
+			path:  "/file",
+			want:  map[string]string{"X-Passkey": "secret", "Cookie": "uid=1; pass=secret"},
+		},
+		{
+			name:  "to an unconfigured site",
+			sites: []config.SiteConfig{trackerSite},
+			path:  "/redirect",
+			want:  map[string]string{"X-Passkey": "", "Cookie": ""},
+		},
+		{
+			name: "to another configured site",
+			sites: []config.SiteConfig{trackerSite, {
+				Domain:  "localhost",
+				Headers: map[string]string{"Authorization": "Bearer cdn"},
+			}},
+			path: "/redirect",
+			want: map[string]string{"X-Passkey": "", "Cookie": "", "Authorization": "Bearer cdn"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = nil
+			f := New(config.FetchConfig{Sites: tt.sites})
+			if _, err := f.Fetch(context.Background(), tracker.URL+tt.path); err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := seen.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
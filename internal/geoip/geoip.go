@@ -0,0 +1,84 @@
+// Package geoip maps peer addresses to countries using a local MaxMind
+// format database (GeoLite2-Country, DB-IP Lite and the like), so lookups
+// never leave the machine.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// maxCached bounds the lookup cache; peers come and go, so it's simply
+// dropped when full
+const maxCached = 4096
+
+// DB answers country lookups. A nil *DB is valid and knows nothing, which
+// is what callers get when no database is configured.
+type DB struct {
+	r *reader
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// Open loads the whole database into memory
+func Open(path string) (*DB, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &DB{r: r, cache: make(map[string]string)}, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code for addr, or "" when the
+// address is unknown, private or not an IP
+func (db *DB) Country(addr string) string {
+	if db == nil {
+		return ""
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return ""
+	}
+
+	key := ip.String()
+	db.mu.Lock()
+	code, ok := db.cache[key]
+	db.mu.Unlock()
+	if ok {
+		return code
+	}
+
+	code = db.lookup(ip)
+	db.mu.Lock()
+	if len(db.cache) >= maxCached {
+		db.cache = make(map[string]string)
+	}
+	db.cache[key] = code
+	db.mu.Unlock()
+	return code
+}
+
+func (db *DB) lookup(ip net.IP) string {
+	v, err := db.r.lookup(ip)
+	if err != nil || v == nil {
+		return ""
+	}
+	record, _ := v.(map[string]interface{})
+	// Fall back to where the block is registered, e.g. for anycast
+	// ranges without a physical location
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := record[key].(map[string]interface{})
+		if code, ok := country["iso_code"].(string); ok && code != "" {
+			return strings.ToUpper(code)
+		}
+	}
+	return ""
+}
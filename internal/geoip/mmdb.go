@@ -0,0 +1,326 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+)
+
+// ErrInvalid is returned for files that aren't MaxMind databases
+var ErrInvalid = errors.New("invalid MaxMind database")
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// maxDepth bounds nesting while decoding, as a guard against corrupt files
+const maxDepth = 32
+
+// reader is a minimal MaxMind DB (.mmdb) reader: a binary search tree over
+// IP bits whose leaves point into a section of self-describing values.
+type reader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node reached after the 96 zero bits that prefix
+	// IPv4 addresses in an IPv6 tree
+	ipv4Start uint
+}
+
+func newReader(buf []byte) (*reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no metadata", ErrInvalid)
+	}
+	metaStart := i + len(metadataMarker)
+	meta, _, err := (&decoder{buf: buf[metaStart:]}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalid, err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalid)
+	}
+
+	r := &reader{
+		nodeCount:  uintField(m, "node_count"),
+		recordSize: uintField(m, "record_size"),
+		ipVersion:  uintField(m, "ip_version"),
+	}
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalid, r.recordSize)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	// The data section follows the tree after 16 zero bytes
+	if r.nodeCount == 0 || treeSize+16 > uint(i) {
+		return nil, fmt.Errorf("%w: search tree of %d nodes doesn't fit", ErrInvalid, r.nodeCount)
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+16 : i]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for bit := 0; bit < 96 && node < r.nodeCount; bit++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+func uintField(m map[string]interface{}, key string) uint {
+	if v, ok := m[key].(uint64); ok {
+		return uint(v)
+	}
+	return 0
+}
+
+// record reads the left (bit 0) or right (bit 1) record of a node
+func (r *reader) record(node uint, bit uint) uint {
+	b := r.tree[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// lookup returns the record stored for ip, or nil when there is none
+func (r *reader) lookup(ip net.IP) (interface{}, error) {
+	node := uint(0)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < len(ip)*8 && node < r.nodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: search tree deeper than the address", ErrInvalid)
+	}
+	offset := node - r.nodeCount - 16
+	if offset >= uint(len(r.data)) {
+		return nil, fmt.Errorf("%w: record points outside the data section", ErrInvalid)
+	}
+	v, _, err := (&decoder{buf: r.data}).decode(offset, 0)
+	return v, err
+}
+
+// Data section types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns it with the offset just
+// past it. Maps decode to map[string]interface{}, arrays to
+// []interface{}, unsigned integers to uint64 and signed ones to int64.
+func (d *decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("values nested too deeply")
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(target, depth+1)
+		return v, next, err
+	}
+
+	// Sizes come from the file, so don't preallocate more entries than
+	// the bytes left could hold
+	capacity := min(size, uint(len(d.buf))-min(offset, uint(len(d.buf))))
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, capacity)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			m[k], offset, err = d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, capacity)
+		for i := uint(0); i < size; i++ {
+			var v interface{}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errors.New("value runs past the end of the data")
+	}
+	b := d.buf[offset:end]
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double is not 8 bytes")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float is not 4 bytes")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("unsigned integer too long")
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("int32 too long")
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		// Shorter encodings are zero padded, so shift the sign in
+		return int64(int32(n<<(32-8*size)) >> (32 - 8*size)), end, nil
+	case typeUint128:
+		// Nothing we read uses them; keep the raw bytes
+		return append([]byte(nil), b...), end, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
+
+// control reads a value's control byte(s) and returns its type, its size
+// (for pointers, the raw size bits) and where its payload starts
+func (d *decoder) control(offset uint) (typ, size, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errors.New("offset past the end of the data")
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ = uint(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errors.New("truncated extended type")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+		if typ < typeInt32 || typ > typeFloat || typ == typeContainer || typ == typeEndMarker {
+			return 0, 0, 0, fmt.Errorf("unsupported data type %d", typ)
+		}
+	}
+	size = uint(ctrl & 0x1f)
+	if typ == typePointer {
+		return typ, size, offset, nil
+	}
+
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, errors.New("truncated size")
+		}
+		var extra uint
+		for _, c := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	return typ, size, offset, nil
+}
+
+// pointer decodes a pointer from the size bits of its control byte and
+// returns the data offset it points at and the offset after it
+func (d *decoder) pointer(sizeBits, offset uint) (uint, uint, error) {
+	n := (sizeBits>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+	var v uint
+	if n < 4 {
+		v = sizeBits & 0x7
+	}
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		v += 2048
+	case 3:
+		v += 526336
+	}
+	return v, offset + n, nil
+}
@@ -0,0 +1,311 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// Encoders for the data section, just enough to build test databases
+
+func ctrl(typ, size int) []byte {
+	if typ > 7 {
+		return []byte{byte(size), byte(typ - 7)}
+	}
+	return []byte{byte(typ<<5 | size)}
+}
+
+func encString(s string) []byte {
+	if len(s) >= 29 {
+		n := len(s) - 29
+		if n < 256 {
+			return append([]byte{typeString<<5 | 29, byte(n)}, s...)
+		}
+		n = len(s) - 285
+		return append([]byte{typeString<<5 | 30, byte(n >> 8), byte(n)}, s...)
+	}
+	return append(ctrl(typeString, len(s)), s...)
+}
+
+func encUint(typ int, n uint64, size int) []byte {
+	b := ctrl(typ, size)
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(n>>(8*i)))
+	}
+	return b
+}
+
+// encMap encodes a map from alternating encoded keys and values
+func encMap(kv ...[]byte) []byte {
+	b := ctrl(typeMap, len(kv)/2)
+	for _, part := range kv {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// encPointer encodes a pointer to offset using the given size class
+func encPointer(ss int, offset uint) []byte {
+	switch ss {
+	case 0:
+		return []byte{typePointer<<5 | byte(offset>>8), byte(offset)}
+	case 1:
+		v := offset - 2048
+		return []byte{typePointer<<5 | 1<<3 | byte(v>>16), byte(v >> 8), byte(v)}
+	case 2:
+		v := offset - 526336
+		return []byte{typePointer<<5 | 2<<3 | byte(v>>24), byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	b := []byte{typePointer<<5 | 3<<3, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], uint32(offset))
+	return b
+}
+
+// trieNode is a search tree node under construction. A child is either
+// another node or, when data is set, a pointer into the data section.
+type trieNode struct {
+	index int
+	child [2]*trieNode
+	data  [2]int
+}
+
+type treeBuilder struct {
+	nodes []*trieNode
+}
+
+func (b *treeBuilder) node() *trieNode {
+	n := &trieNode{index: len(b.nodes), data: [2]int{-1, -1}}
+	b.nodes = append(b.nodes, n)
+	return n
+}
+
+// insert maps the first bits of ip to the value at offset in the data section
+func (b *treeBuilder) insert(ip net.IP, bits int, offset int) {
+	n := b.nodes[0]
+	for i := 0; i < bits; i++ {
+		bit := ip[i/8] >> (7 - i%8) & 1
+		if i == bits-1 {
+			n.data[bit] = offset
+			return
+		}
+		if n.child[bit] == nil {
+			n.child[bit] = b.node()
+		}
+		n = n.child[bit]
+	}
+}
+
+func (b *treeBuilder) encode(recordSize int) []byte {
+	count := len(b.nodes)
+	var tree []byte
+	for _, n := range b.nodes {
+		var rec [2]uint32
+		for bit := range rec {
+			switch {
+			case n.child[bit] != nil:
+				rec[bit] = uint32(n.child[bit].index)
+			case n.data[bit] >= 0:
+				rec[bit] = uint32(count + 16 + n.data[bit])
+			default:
+				rec[bit] = uint32(count)
+			}
+		}
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(rec[0]>>16), byte(rec[0]>>8), byte(rec[0]),
+				byte(rec[1]>>16), byte(rec[1]>>8), byte(rec[1]))
+		case 28:
+			tree = append(tree, byte(rec[0]>>16), byte(rec[0]>>8), byte(rec[0]),
+				byte(rec[0]>>24<<4|rec[1]>>24&0x0f),
+				byte(rec[1]>>16), byte(rec[1]>>8), byte(rec[1]))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, rec[0])
+			tree = binary.BigEndian.AppendUint32(tree, rec[1])
+		}
+	}
+	return tree
+}
+
+// buildDB assembles a database from a tree, a data section and metadata
+func buildDB(tree *treeBuilder, recordSize, ipVersion int, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(tree.encode(recordSize))
+	buf.Write(make([]byte, 16))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encMap(
+		encString("node_count"), encUint(typeUint32, uint64(len(tree.nodes)), 4),
+		encString("record_size"), encUint(typeUint16, uint64(recordSize), 2),
+		encString("ip_version"), encUint(typeUint16, uint64(ipVersion), 1),
+		encString("database_type"), encString("Test-Country"),
+	))
+	return buf.Bytes()
+}
+
+// testData holds a German record at offset 0 and, after some padding, a
+// record at frOffset whose only country is registered_country, reached
+// through a pointer
+func testData() (data []byte, frOffset int) {
+	data = encMap(encString("country"), encMap(encString("iso_code"), encString("de")))
+	fr := len(data)
+	data = append(data, encMap(encString("iso_code"), encString("FR"))...)
+	data = append(data, encString(strings.Repeat("x", 300))...)
+	frOffset = len(data)
+	data = append(data, encMap(encString("registered_country"), encPointer(0, uint(fr)))...)
+	return data, frOffset
+}
+
+func TestReaderLookup(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			data, frOffset := testData()
+			tree := &treeBuilder{}
+			tree.node()
+			v4 := net.ParseIP("1.2.3.0")
+			if ipVersion == 6 {
+				// IPv4 lives under the first 96 zero bits
+				tree.insert(append(make(net.IP, 12), v4.To4()...), 96+24, 0)
+				tree.insert(net.ParseIP("2001:db8::"), 32, frOffset)
+			} else {
+				tree.insert(v4.To4(), 24, 0)
+				tree.insert(net.ParseIP("5.6.0.0").To4(), 16, frOffset)
+			}
+
+			r, err := newReader(buildDB(tree, recordSize, ipVersion, data))
+			if err != nil {
+				t.Fatalf("record size %d, IPv%d: %v", recordSize, ipVersion, err)
+			}
+			db := &DB{r: r, cache: make(map[string]string)}
+
+			tests := map[string]string{
+				"1.2.3.4":     "DE",
+				"1.2.4.1":     "",
+				"9.9.9.9":     "",
+				"2001:db8::1": "",
+				"2a00::1":     "",
+			}
+			if ipVersion == 6 {
+				// Mapped addresses are looked up as IPv4 too
+				tests["::ffff:1.2.3.200"] = "DE"
+				tests["2001:db8::1"] = "FR"
+			} else {
+				tests["5.6.7.8"] = "FR"
+			}
+			for addr, want := range tests {
+				if got := db.Country(addr); got != want {
+					t.Errorf("record size %d, IPv%d: Country(%s) = %q, want %q", recordSize, ipVersion, addr, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRecordSizes(t *testing.T) {
+	// Values using every bit a record can hold
+	want := map[int][2]uint{24: {0xABCDEF, 0x123456}, 28: {0xABCDEF1, 0x9876543}, 32: {0xDEADBEEF, 0x01020304}}
+	for size, rec := range want {
+		b := make([]byte, size/4)
+		switch size {
+		case 24:
+			copy(b, []byte{0xAB, 0xCD, 0xEF, 0x12, 0x34, 0x56})
+		case 28:
+			copy(b, []byte{0xBC, 0xDE, 0xF1, 0xA9, 0x87, 0x65, 0x43})
+		case 32:
+			copy(b, []byte{0xDE, 0xAD, 0xBE, 0xEF, 0x01, 0x02, 0x03, 0x04})
+		}
+		r := &reader{tree: b, recordSize: uint(size)}
+		if left, right := r.record(0, 0), r.record(0, 1); left != rec[0] || right != rec[1] {
+			t.Errorf("record size %d: got %#x, %#x, want %#x, %#x", size, left, right, rec[0], rec[1])
+		}
+	}
+}
+
+func TestDecodePointers(t *testing.T) {
+	for ss, target := range []uint{0x7FF, 526335, 526336 + 10, 12} {
+		buf := make([]byte, target+8)
+		copy(buf[target:], encString("hi"))
+		p := encPointer(ss, target)
+		copy(buf, p)
+
+		v, next, err := (&decoder{buf: buf}).decode(0, 0)
+		if err != nil {
+			t.Fatalf("pointer size %d: %v", ss+1, err)
+		}
+		if v != "hi" || next != uint(len(p)) {
+			t.Errorf("pointer size %d: got %v, next %d, want hi, %d", ss+1, v, next, len(p))
+		}
+	}
+}
+
+func TestDecodeTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want interface{}
+	}{
+		{"string", encString("abc"), "abc"},
+		{"long string", encString(strings.Repeat("y", 29)), strings.Repeat("y", 29)},
+		{"longer string", encString(strings.Repeat("z", 300)), strings.Repeat("z", 300)},
+		{"uint16", encUint(typeUint16, 513, 2), uint64(513)},
+		{"uint32 short", encUint(typeUint32, 7, 1), uint64(7)},
+		{"uint64", encUint(typeUint64, 1<<40, 6), uint64(1 << 40)},
+		{"int32 negative", encUint(typeInt32, 0xFFFE, 2), int64(-2)},
+		{"bool", ctrl(typeBool, 1), true},
+		{"double", append(ctrl(typeDouble, 8), 0x3F, 0xF8, 0, 0, 0, 0, 0, 0), 1.5},
+		{"float", append(ctrl(typeFloat, 4), 0x3F, 0xC0, 0, 0), 1.5},
+		{"array", append(ctrl(typeArray, 2), append(encString("a"), encUint(typeUint16, 1, 1)...)...), []interface{}{"a", uint64(1)}},
+		{"map", encMap(encString("k"), encString("v")), map[string]interface{}{"k": "v"}},
+	}
+	for _, tt := range tests {
+		v, next, err := (&decoder{buf: tt.in}).decode(0, 0)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.want) || next != uint(len(tt.in)) {
+			t.Errorf("%s: got %#v, next %d, want %#v, %d", tt.name, v, next, tt.want, len(tt.in))
+		}
+	}
+}
+
+func TestDecodeHugeSizes(t *testing.T) {
+	// Both claim 16 million entries in a few bytes
+	for _, typ := range []int{typeMap, typeArray} {
+		in := append(ctrl(typ, 31), 0xFF, 0xFF, 0xFF)
+		in = append(in, encString("k")...)
+
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, _, err := (&decoder{buf: in}).decode(0, 0)
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Errorf("type %d: no error for a truncated value", typ)
+		}
+		if grew := after.TotalAlloc - before.TotalAlloc; grew > 1<<20 {
+			t.Errorf("type %d: allocated %d bytes for a %d byte value", typ, grew, len(in))
+		}
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	tree := &treeBuilder{}
+	tree.node()
+	valid := buildDB(tree, 24, 6, encString("x"))
+
+	tests := map[string][]byte{
+		"no metadata":        []byte("not a database"),
+		"bad record size":    bytes.Replace(valid, encUint(typeUint16, 24, 2), encUint(typeUint16, 20, 2), 1),
+		"tree doesn't fit":   valid[len(valid)-100:],
+		"metadata not a map": append(append([]byte(nil), metadataMarker...), encString("x")...),
+	}
+	for name, buf := range tests {
+		if _, err := newReader(buf); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+		}
+	}
+}
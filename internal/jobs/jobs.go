@@ -0,0 +1,125 @@
+// Package jobs runs long operations in the background and keeps their
+// progress around so the UI can poll it.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	StateRunning = "running"
+	StateDone    = "done"
+	StateFailed  = "failed"
+)
+
+// retention is how long finished jobs stay visible
+const retention = time.Hour
+
+// Job is a snapshot of a background operation
+type Job struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	Title    string    `json:"title"`
+	State    string    `json:"state"`
+	Done     int64     `json:"done"`
+	Total    int64     `json:"total"`
+	Error    string    `json:"error,omitempty"`
+	Result   string    `json:"result,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished,omitempty"`
+}
+
+// Progress lets a running job report how far it got
+type Progress func(done, total int64)
+
+// Func is the work a job performs. The returned string is stored as the
+// job's result, e.g. a path or hash the UI may want to show.
+type Func func(ctx context.Context, progress Progress) (string, error)
+
+// Manager keeps track of running and recently finished jobs
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job)}
+}
+
+// Start runs fn in its own goroutine and returns the new job right away.
+// The job gets a fresh context since it outlives the HTTP request that
+// started it.
+func (m *Manager) Start(kind, title string, fn Func) Job {
+	job := &Job{
+		ID:      newID(),
+		Kind:    kind,
+		Title:   title,
+		State:   StateRunning,
+		Started: time.Now(),
+	}
+
+	m.mu.Lock()
+	m.prune()
+	m.jobs[job.ID] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	go func() {
+		result, err := fn(context.Background(), func(done, total int64) {
+			m.mu.Lock()
+			job.Done, job.Total = done, total
+			m.mu.Unlock()
+		})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		job.Finished = time.Now()
+		job.Result = result
+		if err != nil {
+			job.State = StateFailed
+			job.Error = err.Error()
+			log.Printf("Job %s (%s) failed: %v", job.ID, job.Title, err)
+			return
+		}
+		job.State = StateDone
+		if job.Total > 0 {
+			job.Done = job.Total
+		}
+		log.Printf("Job %s (%s) finished in %s", job.ID, job.Title, job.Finished.Sub(job.Started).Round(time.Second))
+	}()
+
+	return snapshot
+}
+
+// Get returns a snapshot of the job with the given id, which is gone
+// once the job finished longer than the retention ago
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// prune drops finished jobs past their retention. Callers hold m.mu.
+func (m *Manager) prune() {
+	for id, job := range m.jobs {
+		if job.State != StateRunning && time.Since(job.Finished) > retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
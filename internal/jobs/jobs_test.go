@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// wait polls the job until it is no longer running
+func wait(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s disappeared", id)
+		}
+		if job.State != StateRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s still running", id)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobProgress(t *testing.T) {
+	m := NewManager()
+	reported := make(chan struct{})
+	release := make(chan struct{})
+	job := m.Start("move", "Move ABC", func(ctx context.Context, progress Progress) (string, error) {
+		progress(3, 10)
+		close(reported)
+		<-release
+		progress(7, 10)
+		return "/new/path", nil
+	})
+	if job.ID == "" || job.Kind != "move" || job.Title != "Move ABC" || job.State != StateRunning {
+		t.Fatalf("started job = %+v", job)
+	}
+
+	<-reported
+	running, ok := m.Get(job.ID)
+	if !ok || running.State != StateRunning || running.Done != 3 || running.Total != 10 {
+		t.Errorf("running job = %+v, %v", running, ok)
+	}
+
+	close(release)
+	done := wait(t, m, job.ID)
+	// A finished job counts as complete whatever was last reported
+	if done.State != StateDone || done.Done != 10 || done.Result != "/new/path" || done.Finished.IsZero() {
+		t.Errorf("finished job = %+v", done)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	m := NewManager()
+	job := m.Start("create", "Create torrent", func(ctx context.Context, progress Progress) (string, error) {
+		progress(1, 4)
+		return "", errors.New("disk full")
+	})
+	failed := wait(t, m, job.ID)
+	if failed.State != StateFailed || failed.Error != "disk full" || failed.Done != 1 {
+		t.Errorf("failed job = %+v", failed)
+	}
+}
+
+func TestGetUnknownOrExpired(t *testing.T) {
+	m := NewManager()
+	if _, ok := m.Get("nope"); ok {
+		t.Error("unknown job found")
+	}
+
+	job := m.Start("move", "Move ABC", func(ctx context.Context, progress Progress) (string, error) {
+		return "", nil
+	})
+	wait(t, m, job.ID)
+	m.mu.Lock()
+	m.jobs[job.ID].Finished = time.Now().Add(-retention - time.Minute)
+	m.mu.Unlock()
+	if _, ok := m.Get(job.ID); ok {
+		t.Error("job found after its retention")
+	}
+}
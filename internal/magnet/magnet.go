@@ -0,0 +1,95 @@
+// Package magnet parses magnet URIs so they can be validated before they
+// are handed to rTorrent.
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is returned for anything that isn't a usable BitTorrent magnet
+var ErrInvalid = errors.New("invalid magnet link")
+
+// Link is the part of a magnet URI we care about
+type Link struct {
+	// InfoHash is the v1 info-hash as 40 uppercase hex digits, the way
+	// rTorrent names torrents
+	InfoHash string
+	// Name is the display name (dn), empty if the link has none
+	Name string
+	// Trackers lists the tr parameters in order
+	Trackers []string
+	// Length is the exact length (xl) in bytes, 0 if unknown
+	Length int64
+}
+
+// IsMagnet reports whether s looks like a magnet URI at all
+func IsMagnet(s string) bool {
+	return len(s) >= 7 && strings.EqualFold(s[:7], "magnet:")
+}
+
+// Parse validates a magnet URI and extracts its btih, dn, tr and xl
+// parameters. Both hex and base32 info-hashes are accepted.
+func Parse(uri string) (*Link, error) {
+	uri = strings.TrimSpace(uri)
+	if !IsMagnet(uri) {
+		return nil, fmt.Errorf("%w: not a magnet URI", ErrInvalid)
+	}
+	// url.ParseQuery is happy with everything after "magnet:?"
+	query := strings.TrimPrefix(uri[7:], "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	link := &Link{
+		Name:     params.Get("dn"),
+		Trackers: params["tr"],
+	}
+	for _, xt := range params["xt"] {
+		if len(xt) > 9 && strings.EqualFold(xt[:9], "urn:btih:") {
+			hash, err := parseInfoHash(xt[9:])
+			if err != nil {
+				return nil, err
+			}
+			link.InfoHash = hash
+			break
+		}
+	}
+	if link.InfoHash == "" {
+		return nil, fmt.Errorf("%w: missing urn:btih info-hash", ErrInvalid)
+	}
+
+	if xl := params.Get("xl"); xl != "" {
+		n, err := strconv.ParseInt(xl, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: bad exact length %q", ErrInvalid, xl)
+		}
+		link.Length = n
+	}
+
+	return link, nil
+}
+
+func parseInfoHash(s string) (string, error) {
+	switch len(s) {
+	case 40:
+		if _, err := hex.DecodeString(s); err != nil {
+			return "", fmt.Errorf("%w: info-hash %q is not hex", ErrInvalid, s)
+		}
+		return strings.ToUpper(s), nil
+	case 32:
+		raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(s))
+		if err != nil {
+			return "", fmt.Errorf("%w: info-hash %q is not base32", ErrInvalid, s)
+		}
+		return strings.ToUpper(hex.EncodeToString(raw)), nil
+	default:
+		return "", fmt.Errorf("%w: info-hash %q has the wrong length", ErrInvalid, s)
+	}
+}
@@ -0,0 +1,57 @@
+package magnet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	const hash = "C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"
+	tests := []struct {
+		name    string
+		uri     string
+		want    *Link
+		wantErr bool
+	}{
+		{
+			name: "hex",
+			uri:  "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Some+Name&tr=udp%3A%2F%2Fa%3A1&tr=http%3A%2F%2Fb%2Fannounce&xl=1024",
+			want: &Link{InfoHash: hash, Name: "Some Name", Trackers: []string{"udp://a:1", "http://b/announce"}, Length: 1024},
+		},
+		{
+			name: "base32",
+			uri:  "MAGNET:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+			want: &Link{InfoHash: hash},
+		},
+		{
+			name: "surrounding space and other xt first",
+			uri:  "  magnet:?xt=urn:btmh:1220abcd&xt=URN:BTIH:" + hash + "  ",
+			want: &Link{InfoHash: hash},
+		},
+		{name: "not a magnet", uri: "http://example.com/a.torrent", wantErr: true},
+		{name: "no info-hash", uri: "magnet:?dn=x", wantErr: true},
+		{name: "short hash", uri: "magnet:?xt=urn:btih:c12fe1", wantErr: true},
+		{name: "bad hex", uri: "magnet:?xt=urn:btih:z12fe1c06bba254a9dc9f519b335aa7c1367a88a", wantErr: true},
+		{name: "bad base32", uri: "magnet:?xt=urn:btih:1EX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK", wantErr: true},
+		{name: "negative length", uri: "magnet:?xt=urn:btih:" + hash + "&xl=-1", wantErr: true},
+		{name: "bad escape", uri: "magnet:?xt=urn:btih:" + hash + "&dn=%zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.uri)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("Parse = %+v, %v; want ErrInvalid", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
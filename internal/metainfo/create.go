@@ -0,0 +1,274 @@
+package metainfo
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rtorrent-go/internal/bencode"
+)
+
+// ErrInvalidOptions is returned by Create for unusable settings
+var ErrInvalidOptions = errors.New("invalid torrent options")
+
+// Piece lengths accepted by Create. Auto picks one in this range.
+const (
+	MinPieceLength = 16 << 10
+	MaxPieceLength = 16 << 20
+	// targetPieces is roughly how many pieces the auto piece length aims for
+	targetPieces = 1500
+)
+
+// CreateOptions describes a torrent to build from files on disk
+type CreateOptions struct {
+	// Path is a file or directory; directories become multi-file torrents
+	Path string
+	// Trackers are announce URLs, each in its own tier
+	Trackers []string
+	// PieceLength is a power of two, or 0 to pick one from the size
+	PieceLength int64
+	Private     bool
+	Comment     string
+	// WebSeeds are BEP 19 HTTP seeds
+	WebSeeds  []string
+	CreatedBy string
+}
+
+// ProgressFunc receives the number of bytes hashed so far
+type ProgressFunc func(done, total int64)
+
+type sourceFile struct {
+	path   string
+	parts  []string
+	length int64
+}
+
+// Create hashes the content at opts.Path and returns a bencoded v1
+// .torrent for it.
+func Create(ctx context.Context, opts CreateOptions, progress ProgressFunc) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(done, total int64) {}
+	}
+
+	root := filepath.Clean(opts.Path)
+	files, total, multi, err := collectFiles(root)
+	if err != nil {
+		return nil, err
+	}
+	pieceLength := opts.PieceLength
+	if pieceLength == 0 {
+		pieceLength = AutoPieceLength(total)
+	}
+
+	pieces, err := hashPieces(ctx, files, total, pieceLength, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string]interface{}{
+		"name":         filepath.Base(root),
+		"piece length": pieceLength,
+		"pieces":       pieces,
+	}
+	if opts.Private {
+		info["private"] = 1
+	}
+	if multi {
+		list := make([]interface{}, len(files))
+		for i, f := range files {
+			list[i] = map[string]interface{}{
+				"length": f.length,
+				"path":   f.parts,
+			}
+		}
+		info["files"] = list
+	} else {
+		info["length"] = files[0].length
+	}
+
+	torrent := map[string]interface{}{
+		"info":          info,
+		"creation date": time.Now().Unix(),
+	}
+	if len(opts.Trackers) > 0 {
+		torrent["announce"] = opts.Trackers[0]
+		tiers := make([]interface{}, len(opts.Trackers))
+		for i, tracker := range opts.Trackers {
+			tiers[i] = []string{tracker}
+		}
+		torrent["announce-list"] = tiers
+	}
+	if len(opts.WebSeeds) > 0 {
+		torrent["url-list"] = opts.WebSeeds
+	}
+	if opts.Comment != "" {
+		torrent["comment"] = opts.Comment
+	}
+	if opts.CreatedBy != "" {
+		torrent["created by"] = opts.CreatedBy
+	}
+	return bencode.Encode(torrent)
+}
+
+// AutoPieceLength picks a power-of-two piece length that gives about
+// targetPieces pieces for total bytes.
+func AutoPieceLength(total int64) int64 {
+	length := int64(MinPieceLength)
+	for length < MaxPieceLength && total/length > targetPieces {
+		length *= 2
+	}
+	return length
+}
+
+// Validate checks the options without touching the content
+func (o CreateOptions) Validate() error {
+	if !filepath.IsAbs(o.Path) {
+		return fmt.Errorf("%w: path must be absolute", ErrInvalidOptions)
+	}
+	if l := o.PieceLength; l != 0 && (l < MinPieceLength || l > MaxPieceLength || l&(l-1) != 0) {
+		return fmt.Errorf("%w: piece length must be a power of two between %d KiB and %d MiB", ErrInvalidOptions, MinPieceLength>>10, MaxPieceLength>>20)
+	}
+	for _, tracker := range o.Trackers {
+		if !validURL(tracker, "http", "https", "udp") {
+			return fmt.Errorf("%w: bad tracker URL %q", ErrInvalidOptions, tracker)
+		}
+	}
+	for _, seed := range o.WebSeeds {
+		if !validURL(seed, "http", "https") {
+			return fmt.Errorf("%w: bad web seed URL %q", ErrInvalidOptions, seed)
+		}
+	}
+	return nil
+}
+
+func validURL(s string, schemes ...string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectFiles lists the regular files making up the torrent in lexical
+// order. Symlinks and special files are skipped.
+func collectFiles(root string) ([]sourceFile, int64, bool, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if info.Mode().IsRegular() {
+		return []sourceFile{{path: root, length: info.Size()}}, info.Size(), false, nil
+	}
+	if !info.IsDir() {
+		return nil, 0, false, fmt.Errorf("%w: %s is not a regular file or directory", ErrInvalidOptions, root)
+	}
+
+	var files []sourceFile
+	var total int64
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, sourceFile{
+			path:   path,
+			parts:  strings.Split(filepath.ToSlash(rel), "/"),
+			length: info.Size(),
+		})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if len(files) == 0 {
+		return nil, 0, false, fmt.Errorf("%w: %s contains no files", ErrInvalidOptions, root)
+	}
+	return files, total, true, nil
+}
+
+// hashPieces reads the files back to back and returns the concatenated
+// SHA-1 of every piece.
+func hashPieces(ctx context.Context, files []sourceFile, total, pieceLength int64, progress ProgressFunc) ([]byte, error) {
+	if total == 0 {
+		return nil, fmt.Errorf("%w: content is empty", ErrInvalidOptions)
+	}
+
+	pieces := make([]byte, 0, (total+pieceLength-1)/pieceLength*sha1.Size)
+	h := sha1.New()
+	var inPiece, done int64
+	buf := make([]byte, 256<<10)
+
+	for _, f := range files {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, err
+		}
+		var read int64
+		for {
+			if err := ctx.Err(); err != nil {
+				file.Close()
+				return nil, err
+			}
+			n, err := file.Read(buf)
+			for chunk := buf[:n]; len(chunk) > 0; {
+				take := pieceLength - inPiece
+				if take > int64(len(chunk)) {
+					take = int64(len(chunk))
+				}
+				h.Write(chunk[:take])
+				chunk = chunk[take:]
+				inPiece += take
+				if inPiece == pieceLength {
+					pieces = h.Sum(pieces)
+					h.Reset()
+					inPiece = 0
+				}
+			}
+			read += int64(n)
+			done += int64(n)
+			progress(done, total)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		file.Close()
+		if read != f.length {
+			return nil, fmt.Errorf("%s changed size while hashing", f.path)
+		}
+	}
+	if inPiece > 0 {
+		pieces = h.Sum(pieces)
+	}
+	return pieces, nil
+}
@@ -0,0 +1,193 @@
+package metainfo
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"rtorrent-go/internal/bencode"
+)
+
+// writePattern writes n bytes of i%mod to path
+func writePattern(t *testing.T, path string, n, mod int) {
+	t.Helper()
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % mod)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pieceHashes returns the hex piece hashes of a created torrent
+func pieceHashes(t *testing.T, data []byte) []string {
+	t.Helper()
+	v, err := bencode.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, _ := v.(map[string]interface{})["info"].(map[string]interface{})
+	pieces, ok := info["pieces"].(string)
+	if !ok || len(pieces)%20 != 0 {
+		t.Fatalf("pieces = %#v", info["pieces"])
+	}
+	var hashes []string
+	for i := 0; i < len(pieces); i += 20 {
+		hashes = append(hashes, hex.EncodeToString([]byte(pieces[i:i+20])))
+	}
+	return hashes
+}
+
+func TestCreateMultiFile(t *testing.T) {
+	// Pieces of 16 KiB span all three files: 20000 + 4 + 30000 bytes,
+	// hashed in lexical order. The expected values were computed
+	// independently of this package.
+	root := filepath.Join(t.TempDir(), "dataset")
+	writePattern(t, filepath.Join(root, "a.bin"), 20000, 251)
+	if err := os.WriteFile(filepath.Join(root, "c.txt"), []byte("tail"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writePattern(t, filepath.Join(root, "sub", "b.bin"), 30000, 241)
+	if err := os.Symlink(filepath.Join(root, "a.bin"), filepath.Join(root, "link.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	var lastDone, lastTotal int64
+	data, err := Create(context.Background(), CreateOptions{
+		Path:        root,
+		Trackers:    []string{"http://tracker.example/announce", "udp://backup.example:80"},
+		PieceLength: 16 << 10,
+		Private:     true,
+		Comment:     "test data",
+		WebSeeds:    []string{"https://seed.example/dataset/"},
+		CreatedBy:   "VibeTorrent",
+	}, func(done, total int64) { lastDone, lastTotal = done, total })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastDone != 50004 || lastTotal != 50004 {
+		t.Errorf("last progress = %d/%d, want 50004/50004", lastDone, lastTotal)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash != "C19CDD8649672E94C3723CCC4520EE09E8FC6BC6" {
+		t.Errorf("info hash = %s", m.InfoHash)
+	}
+	wantPieces := []string{
+		"68f3b81a11de1e1629e81555b4e70aed955d1140",
+		"c97d789a3bda0fc425a2ef60764a1328d83c9105",
+		"0417334986e5c33f5f8f0d56c35558315e23d619",
+		"5ea09da1140c81a566f3d1b580d0693de0573b78",
+	}
+	if got := pieceHashes(t, data); !reflect.DeepEqual(got, wantPieces) {
+		t.Errorf("pieces = %v, want %v", got, wantPieces)
+	}
+	wantFiles := []File{
+		{Index: 0, Path: "a.bin", Length: 20000},
+		{Index: 1, Path: "c.txt", Length: 4},
+		{Index: 2, Path: "sub/b.bin", Length: 30000},
+	}
+	if !reflect.DeepEqual(m.Files, wantFiles) {
+		t.Errorf("files = %+v, want %+v", m.Files, wantFiles)
+	}
+	if !m.Private || m.Comment != "test data" || m.CreatedBy != "VibeTorrent" || m.Name != "dataset" {
+		t.Errorf("metainfo = %+v", m)
+	}
+	if !reflect.DeepEqual(m.Trackers, []string{"http://tracker.example/announce", "udp://backup.example:80"}) {
+		t.Errorf("trackers = %v", m.Trackers)
+	}
+}
+
+func TestCreateSingleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.bin")
+	writePattern(t, path, 20000, 251)
+
+	data, err := Create(context.Background(), CreateOptions{Path: path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Auto piece length for 20000 bytes is the minimum, 16 KiB
+	if m.InfoHash != "0F66FBE1FBAFEBEF51710AC41839E2FC1E76B4AC" || m.PieceLength != 16<<10 || m.PieceCount != 2 {
+		t.Errorf("metainfo = %+v", m)
+	}
+	if m.Private || m.MultiFile {
+		t.Errorf("private %v, multi-file %v, want neither", m.Private, m.MultiFile)
+	}
+	v, err := bencode.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := v.(map[string]interface{})["info"].(map[string]interface{})
+	if _, ok := info["private"]; ok {
+		t.Error("public torrent has a private key")
+	}
+}
+
+func TestAutoPieceLength(t *testing.T) {
+	tests := map[int64]int64{
+		0:              MinPieceLength,
+		1500 << 14:     MinPieceLength,
+		1501 << 14:     32 << 10,
+		1 << 30:        1 << 20,
+		4 << 30:        4 << 20,
+		1 << 40:        MaxPieceLength,
+		(1 << 40) * 10: MaxPieceLength,
+	}
+	for total, want := range tests {
+		if got := AutoPieceLength(total); got != want {
+			t.Errorf("AutoPieceLength(%d) = %d, want %d", total, got, want)
+		}
+	}
+}
+
+func TestCreateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	if err := os.Mkdir(empty, 0755); err != nil {
+		t.Fatal(err)
+	}
+	zero := filepath.Join(dir, "zero.bin")
+	if err := os.WriteFile(zero, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]CreateOptions{
+		"relative path":          {Path: "data"},
+		"piece length too small": {Path: zero, PieceLength: 8 << 10},
+		"piece length not power": {Path: zero, PieceLength: 48 << 10},
+		"bad tracker":            {Path: zero, Trackers: []string{"ftp://tracker"}},
+		"bad web seed":           {Path: zero, WebSeeds: []string{"udp://seed"}},
+		"no files":               {Path: empty},
+		"empty content":          {Path: zero},
+	}
+	for name, opts := range tests {
+		if _, err := Create(context.Background(), opts, nil); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("%s: err = %v, want ErrInvalidOptions", name, err)
+		}
+	}
+}
+
+func TestCreateCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.bin")
+	writePattern(t, path, 20000, 251)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Create(ctx, CreateOptions{Path: path}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
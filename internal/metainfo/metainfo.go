@@ -0,0 +1,195 @@
+// Package metainfo reads the contents of .torrent files
+package metainfo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"rtorrent-go/internal/bencode"
+)
+
+// ErrInvalid is returned for data that isn't a usable .torrent file
+var ErrInvalid = errors.New("invalid torrent file")
+
+// File is one file of a torrent. Index matches rTorrent's file index.
+type File struct {
+	Index  int    `json:"index"`
+	Path   string `json:"path"`
+	Length int64  `json:"length"`
+}
+
+// MetaInfo is what a .torrent file says about its torrent
+type MetaInfo struct {
+	Name string `json:"name"`
+	// InfoHash is the SHA-1 of the info dictionary as 40 uppercase hex
+	// digits, the way rTorrent names torrents
+	InfoHash     string   `json:"info_hash"`
+	Length       int64    `json:"length"`
+	PieceLength  int64    `json:"piece_length"`
+	PieceCount   int      `json:"piece_count"`
+	Private      bool     `json:"private"`
+	Comment      string   `json:"comment"`
+	CreatedBy    string   `json:"created_by"`
+	CreationDate int64    `json:"creation_date"`
+	Trackers     []string `json:"trackers"`
+	// Files is in torrent order; single-file torrents have one entry
+	// named after the torrent.
+	Files []File `json:"files"`
+	// MultiFile is set for torrents with an info.files list, whose files
+	// live in a folder named after the torrent, even if there is only one
+	MultiFile bool `json:"multi_file"`
+}
+
+// Parse decodes a .torrent file
+func Parse(data []byte) (*MetaInfo, error) {
+	top, err := bencode.Split(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	rawInfo, ok := top["info"]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing info dictionary", ErrInvalid)
+	}
+	decoded, err := bencode.Decode(rawInfo)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	info, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: info is not a dictionary", ErrInvalid)
+	}
+
+	sum := sha1.Sum(rawInfo)
+	m := &MetaInfo{
+		InfoHash:    strings.ToUpper(hex.EncodeToString(sum[:])),
+		Name:        str(info["name"]),
+		PieceLength: integer(info["piece length"]),
+		Private:     integer(info["private"]) == 1,
+	}
+	if m.Name == "" {
+		return nil, fmt.Errorf("%w: missing name", ErrInvalid)
+	}
+	if m.PieceLength <= 0 {
+		return nil, fmt.Errorf("%w: missing piece length", ErrInvalid)
+	}
+	pieces := str(info["pieces"])
+	if len(pieces) == 0 || len(pieces)%sha1.Size != 0 {
+		return nil, fmt.Errorf("%w: bad piece hashes", ErrInvalid)
+	}
+	m.PieceCount = len(pieces) / sha1.Size
+
+	if err := m.readFiles(info); err != nil {
+		return nil, err
+	}
+	if expected := (m.Length + m.PieceLength - 1) / m.PieceLength; int64(m.PieceCount) != expected {
+		return nil, fmt.Errorf("%w: %d pieces for %d bytes", ErrInvalid, m.PieceCount, m.Length)
+	}
+
+	m.Trackers = readTrackers(top)
+	m.Comment = decodeString(top["comment"])
+	m.CreatedBy = decodeString(top["created by"])
+	if raw, ok := top["creation date"]; ok {
+		if v, err := bencode.Decode(raw); err == nil {
+			m.CreationDate = integer(v)
+		}
+	}
+	return m, nil
+}
+
+func (m *MetaInfo) readFiles(info map[string]interface{}) error {
+	files, multi := info["files"].([]interface{})
+	if !multi {
+		length := integer(info["length"])
+		if length < 0 {
+			return fmt.Errorf("%w: negative length", ErrInvalid)
+		}
+		m.Files = []File{{Index: 0, Path: m.Name, Length: length}}
+		m.Length = length
+		return nil
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("%w: empty file list", ErrInvalid)
+	}
+	m.MultiFile = true
+	m.Files = make([]File, 0, len(files))
+	for i, item := range files {
+		f, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: file %d is not a dictionary", ErrInvalid, i)
+		}
+		parts, _ := f["path"].([]interface{})
+		path := make([]string, 0, len(parts))
+		for _, p := range parts {
+			part := str(p)
+			if part == "" || part == "." || part == ".." || strings.Contains(part, "/") {
+				return fmt.Errorf("%w: file %d has a bad path", ErrInvalid, i)
+			}
+			path = append(path, part)
+		}
+		if len(path) == 0 {
+			return fmt.Errorf("%w: file %d has no path", ErrInvalid, i)
+		}
+		length := integer(f["length"])
+		if length < 0 {
+			return fmt.Errorf("%w: file %d has a negative length", ErrInvalid, i)
+		}
+		m.Files = append(m.Files, File{Index: i, Path: strings.Join(path, "/"), Length: length})
+		m.Length += length
+	}
+	return nil
+}
+
+// readTrackers flattens announce-list, falling back to announce, keeping
+// the first occurrence of each URL.
+func readTrackers(top map[string]bencode.Raw) []string {
+	var urls []string
+	if raw, ok := top["announce-list"]; ok {
+		if v, err := bencode.Decode(raw); err == nil {
+			tiers, _ := v.([]interface{})
+			for _, tier := range tiers {
+				list, _ := tier.([]interface{})
+				for _, u := range list {
+					urls = append(urls, str(u))
+				}
+			}
+		}
+	}
+	if len(urls) == 0 {
+		urls = append(urls, decodeString(top["announce"]))
+	}
+
+	seen := make(map[string]bool, len(urls))
+	trackers := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			trackers = append(trackers, u)
+		}
+	}
+	return trackers
+}
+
+func decodeString(raw bencode.Raw) string {
+	if raw == nil {
+		return ""
+	}
+	v, err := bencode.Decode(raw)
+	if err != nil {
+		return ""
+	}
+	return str(v)
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func integer(v interface{}) int64 {
+	n, _ := v.(int64)
+	return n
+}
@@ -0,0 +1,113 @@
+package metainfo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// knownInfo is a single-file info dictionary whose SHA-1 was computed
+// independently of this package
+const (
+	knownInfo = "d6:lengthi20e4:name5:a.txt12:piece lengthi16e6:pieces40:" +
+		"aaaaaaaaaaaaaaaaaaaabbbbbbbbbbbbbbbbbbbbe"
+	knownHash = "3226FDBA276DA9105C7BC664E616F383E8B36389"
+)
+
+func TestParseSingleFile(t *testing.T) {
+	data := "d8:announce17:http://a/announce7:comment2:hi10:created by2:vt13:creation datei1700000000e" +
+		"4:info" + knownInfo + "e"
+	m, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &MetaInfo{
+		Name:         "a.txt",
+		InfoHash:     knownHash,
+		Length:       20,
+		PieceLength:  16,
+		PieceCount:   2,
+		Comment:      "hi",
+		CreatedBy:    "vt",
+		CreationDate: 1700000000,
+		Trackers:     []string{"http://a/announce"},
+		Files:        []File{{Index: 0, Path: "a.txt", Length: 20}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("Parse = %+v, want %+v", m, want)
+	}
+}
+
+func TestParseMultiFile(t *testing.T) {
+	data := "d8:announce1:x13:announce-listll1:ael1:b1:aee" +
+		"4:infod5:filesld6:lengthi10e4:pathl3:dir5:a.bineed6:lengthi6e4:pathl5:b.bineee" +
+		"4:name5:album12:piece lengthi16e6:pieces20:aaaaaaaaaaaaaaaaaaaa7:privatei1eee"
+	m, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.MultiFile || !m.Private || m.Length != 16 || m.PieceCount != 1 {
+		t.Errorf("Parse = %+v", m)
+	}
+	wantFiles := []File{{Index: 0, Path: "dir/a.bin", Length: 10}, {Index: 1, Path: "b.bin", Length: 6}}
+	if !reflect.DeepEqual(m.Files, wantFiles) {
+		t.Errorf("Files = %+v, want %+v", m.Files, wantFiles)
+	}
+	// announce-list wins over announce, duplicates dropped
+	if want := []string{"a", "b"}; !reflect.DeepEqual(m.Trackers, want) {
+		t.Errorf("Trackers = %q, want %q", m.Trackers, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	info := func(fields string) string {
+		return "d4:info" + "d" + fields + "ee"
+	}
+	const pieces = "12:piece lengthi16e6:pieces20:aaaaaaaaaaaaaaaaaaaa"
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not bencode", data: "<html>"},
+		{name: "not a dictionary", data: "l4:infoe"},
+		{name: "missing info", data: "d8:announce1:xe"},
+		{name: "info not a dictionary", data: "d4:info4:spame"},
+		{name: "missing name", data: info("6:lengthi1e" + pieces)},
+		{name: "missing piece length", data: info("6:lengthi1e4:name1:a6:pieces20:aaaaaaaaaaaaaaaaaaaa")},
+		{name: "zero piece length", data: info("6:lengthi1e4:name1:a12:piece lengthi0e6:pieces20:aaaaaaaaaaaaaaaaaaaa")},
+		{name: "pieces not a multiple of 20", data: info("6:lengthi1e4:name1:a12:piece lengthi16e6:pieces3:abc")},
+		{name: "too few pieces", data: info("6:lengthi17e4:name1:a" + pieces)},
+		{name: "negative length", data: info("6:lengthi-1e4:name1:a" + pieces)},
+		{name: "empty file list", data: info("5:filesle4:name1:a" + pieces)},
+		{name: "file not a dictionary", data: info("5:filesli1ee4:name1:a" + pieces)},
+		{name: "file without path", data: info("5:filesld6:lengthi1eee4:name1:a" + pieces)},
+		{name: "dot dot in path", data: info("5:filesld6:lengthi1e4:pathl2:..1:xeee4:name1:a" + pieces)},
+		{name: "slash in path", data: info("5:filesld6:lengthi1e4:pathl3:a/beee4:name1:a" + pieces)},
+		{name: "negative file length", data: info("5:filesld6:lengthi-1e4:pathl1:xeee4:name1:a" + pieces)},
+		{name: "trailing data", data: "d4:info" + knownInfo + "eXX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if m, err := Parse([]byte(tt.data)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Parse = %+v, %v; want ErrInvalid", m, err)
+			}
+		})
+	}
+}
+
+func TestInfoHashIgnoresOtherKeys(t *testing.T) {
+	// The hash covers the info dictionary exactly as encoded, whatever
+	// surrounds it
+	for _, data := range []string{
+		"d4:info" + knownInfo + "e",
+		"d8:announce3:foo4:info" + knownInfo + "4:zzzzi1ee",
+	} {
+		m, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.InfoHash != knownHash {
+			t.Errorf("InfoHash = %s, want %s", m.InfoHash, knownHash)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package metainfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"rtorrent-go/internal/bencode"
+)
+
+// WithFastResume adds libtorrent resume data to a .torrent marking every
+// piece as complete, so rTorrent starts seeding the data in dir without
+// hashing it first. dir is the torrent's base directory: the folder holding
+// the files of a multi-file torrent, or the one containing a single file.
+// priorities overrides the normal priority of individual files by index.
+// Every file must exist with the right size, except skipped ones with
+// priority 0: those are marked as not downloaded along with every piece
+// they share with other files.
+func WithFastResume(data []byte, dir string, priorities map[int]int) ([]byte, error) {
+	m, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	top, err := bencode.Split(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	done := make([]bool, m.PieceCount)
+	for i := range done {
+		done[i] = true
+	}
+	mtimes := make([]int64, len(m.Files))
+	var offset int64
+	for i, f := range m.Files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		priority, ok := priorities[f.Index]
+		if !ok {
+			priority = 1
+		}
+
+		info, err := os.Stat(path)
+		if err == nil && info.Size() != f.Length {
+			err = fmt.Errorf("%s is %d bytes, expected %d", path, info.Size(), f.Length)
+		}
+		switch {
+		case err == nil:
+			mtimes[i] = info.ModTime().Unix()
+		case priority != 0:
+			return nil, fmt.Errorf("data is not complete: %w", err)
+		case f.Length > 0:
+			for p := offset / m.PieceLength; p <= (offset+f.Length-1)/m.PieceLength; p++ {
+				done[p] = false
+			}
+		}
+		offset += f.Length
+	}
+
+	files := make([]interface{}, len(m.Files))
+	complete := true
+	offset = 0
+	for i, f := range m.Files {
+		priority, ok := priorities[f.Index]
+		if !ok {
+			priority = 1
+		}
+		// Number of finished pieces the file touches
+		var completed int64
+		for p := offset / m.PieceLength; p < (offset+f.Length+m.PieceLength-1)/m.PieceLength; p++ {
+			if done[p] {
+				completed++
+			} else {
+				complete = false
+			}
+		}
+		files[i] = map[string]interface{}{
+			"priority":  priority,
+			"mtime":     mtimes[i],
+			"completed": completed,
+		}
+		offset += f.Length
+	}
+
+	// An integer bitfield means every piece is done
+	var bitfield interface{} = m.PieceCount
+	if !complete {
+		bits := make([]byte, (m.PieceCount+7)/8)
+		for p, ok := range done {
+			if ok {
+				bits[p/8] |= 0x80 >> (p % 8)
+			}
+		}
+		bitfield = string(bits)
+	}
+
+	torrent := make(map[string]interface{}, len(top)+1)
+	for k, v := range top {
+		torrent[k] = v
+	}
+	torrent["libtorrent_resume"] = map[string]interface{}{
+		"bitfield":                   bitfield,
+		"files":                      files,
+		"uncertain_pieces.timestamp": time.Now().Unix(),
+	}
+	return bencode.Encode(torrent)
+}
@@ -0,0 +1,104 @@
+package metainfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"rtorrent-go/internal/bencode"
+)
+
+// resumeTorrent is a 48 byte torrent in 16 byte pieces whose middle file
+// shares piece 1 with both neighbours
+func resumeTorrent(t *testing.T) []byte {
+	t.Helper()
+	var files []interface{}
+	for _, f := range []struct {
+		name   string
+		length int
+	}{{"a.bin", 20}, {"b.bin", 10}, {"c.bin", 18}} {
+		files = append(files, map[string]interface{}{"length": f.length, "path": []string{f.name}})
+	}
+	data, err := bencode.Encode(map[string]interface{}{
+		"info": map[string]interface{}{
+			"name":         "album",
+			"files":        files,
+			"piece length": 16,
+			"pieces":       strings.Repeat("x", 3*20),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// resumeData writes the files of resumeTorrent except skip to a temp dir
+// and returns the libtorrent_resume WithFastResume produces for it
+func resumeData(t *testing.T, priorities map[int]int, skip ...string) (map[string]interface{}, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, length := range map[string]int{"a.bin": 20, "b.bin": 10, "c.bin": 18} {
+		if slices.Contains(skip, name) {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, length), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := WithFastResume(resumeTorrent(t), dir, priorities)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := bencode.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded.(map[string]interface{})["libtorrent_resume"].(map[string]interface{}), nil
+}
+
+// fileStates returns the priority and completed count of every file
+func fileStates(resume map[string]interface{}) [][2]int64 {
+	var states [][2]int64
+	for _, f := range resume["files"].([]interface{}) {
+		file := f.(map[string]interface{})
+		states = append(states, [2]int64{file["priority"].(int64), file["completed"].(int64)})
+	}
+	return states
+}
+
+func TestWithFastResumeComplete(t *testing.T) {
+	resume, err := resumeData(t, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resume["bitfield"]; got != int64(3) {
+		t.Errorf("bitfield = %#v, want every piece", got)
+	}
+	if got, want := fileStates(resume), [][2]int64{{1, 2}, {1, 1}, {1, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestWithFastResumeSkippedFileMissing(t *testing.T) {
+	resume, err := resumeData(t, map[int]int{1: 0}, "b.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Piece 1 holds part of b.bin, so only pieces 0 and 2 are done
+	if got := resume["bitfield"]; got != "\xa0" {
+		t.Errorf("bitfield = %q, want pieces 0 and 2", got)
+	}
+	if got, want := fileStates(resume), [][2]int64{{1, 1}, {0, 0}, {1, 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestWithFastResumeWantedFileMissing(t *testing.T) {
+	if _, err := resumeData(t, map[int]int{0: 0}, "b.bin"); err == nil {
+		t.Error("missing wanted file accepted")
+	}
+}
@@ -0,0 +1,68 @@
+// Package pathutil confines filesystem operations to the configured
+// download roots.
+package pathutil
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// CleanRoots drops empty entries and cleans the rest
+func CleanRoots(roots []string) []string {
+	var cleaned []string
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		cleaned = append(cleaned, filepath.Clean(root))
+	}
+	return cleaned
+}
+
+// Inside reports whether path lies strictly inside one of roots.
+// Symlinks are resolved on both sides first so a link can't be used to
+// escape a root.
+func Inside(path string, roots []string) bool {
+	rel, ok := relToRoot(path, roots)
+	return ok && rel != "."
+}
+
+// Under reports whether path is one of roots or lies inside one
+func Under(path string, roots []string) bool {
+	_, ok := relToRoot(path, roots)
+	return ok
+}
+
+func relToRoot(path string, roots []string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		return "", false
+	}
+	path = resolve(filepath.Clean(path))
+
+	for _, root := range roots {
+		rel, err := filepath.Rel(resolve(filepath.Clean(root)), path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+// resolve follows symlinks in path. When path doesn't exist yet, its
+// deepest existing ancestor is resolved instead so a link further up
+// can't be used to escape a root.
+func resolve(path string) string {
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
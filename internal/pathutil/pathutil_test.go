@@ -0,0 +1,79 @@
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInsideUnder(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"data/sub", "data2", "outside"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"data/sub/a.bin", "outside/secret"} {
+		if err := os.WriteFile(filepath.Join(base, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"data/escape": filepath.Join(base, "outside"),
+		"data/alias":  filepath.Join(base, "data", "sub"),
+		"rootlink":    filepath.Join(base, "data"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(base, "data")
+
+	tests := []struct {
+		name       string
+		path       string
+		roots      []string
+		wantInside bool
+		wantUnder  bool
+	}{
+		{name: "root itself", path: root, wantUnder: true},
+		{name: "root with trailing slash", path: root + "/", wantUnder: true},
+		{name: "file", path: filepath.Join(root, "sub", "a.bin"), wantInside: true, wantUnder: true},
+		{name: "sibling with root as prefix", path: filepath.Join(base, "data2", "x")},
+		{name: "dot dot out of root", path: filepath.Join(root, "..", "data2", "x")},
+		{name: "dot dot to parent", path: root + "/.."},
+		{name: "dot dot staying inside", path: root + "/sub/../a.bin", wantInside: true, wantUnder: true},
+		{name: "relative", path: "data/sub/a.bin"},
+		{name: "symlink escape", path: filepath.Join(root, "escape", "secret")},
+		{name: "symlink escape to missing file", path: filepath.Join(root, "escape", "new", "file")},
+		{name: "symlink inside root", path: filepath.Join(root, "alias", "a.bin"), wantInside: true, wantUnder: true},
+		{name: "missing path", path: filepath.Join(root, "new", "deeper", "file"), wantInside: true, wantUnder: true},
+		{name: "missing path outside", path: filepath.Join(base, "new", "file")},
+		{name: "root through symlink", path: filepath.Join(root, "sub"), roots: []string{filepath.Join(base, "rootlink")}, wantInside: true, wantUnder: true},
+		{name: "missing path through symlinked root", path: filepath.Join(base, "rootlink", "new", "file"), wantInside: true, wantUnder: true},
+		{name: "second root", path: filepath.Join(base, "data2", "x"), roots: []string{root, filepath.Join(base, "data2")}, wantInside: true, wantUnder: true},
+		{name: "no roots", path: filepath.Join(root, "sub"), roots: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := tt.roots
+			if roots == nil {
+				roots = []string{root}
+			}
+			if got := Inside(tt.path, roots); got != tt.wantInside {
+				t.Errorf("Inside(%s) = %v, want %v", tt.path, got, tt.wantInside)
+			}
+			if got := Under(tt.path, roots); got != tt.wantUnder {
+				t.Errorf("Under(%s) = %v, want %v", tt.path, got, tt.wantUnder)
+			}
+		})
+	}
+}
+
+func TestCleanRoots(t *testing.T) {
+	got := CleanRoots([]string{"", "/data/", "/data/../other", ""})
+	if len(got) != 2 || got[0] != "/data" || got[1] != "/other" {
+		t.Errorf("CleanRoots = %v", got)
+	}
+}
@@ -0,0 +1,142 @@
+// Package resolver watches magnet links while rTorrent fetches their
+// metadata and re-applies the options they were added with once the real
+// torrent has replaced the placeholder.
+package resolver
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"rtorrent-go/internal/rtorrent"
+)
+
+const (
+	// pollInterval is how often pending magnets are checked
+	pollInterval = 5 * time.Second
+	// appearTimeout drops magnets that never showed up in rTorrent
+	appearTimeout = 2 * time.Minute
+	// maxMissing is how many polls a tracked torrent may be absent for
+	// before it is considered removed. rTorrent briefly drops the
+	// placeholder while it swaps in the resolved torrent.
+	maxMissing = 6
+)
+
+type pending struct {
+	opts    rtorrent.AddOptions
+	added   time.Time
+	seen    bool
+	missing int
+}
+
+// Resolver keeps the options of magnets that are still fetching metadata
+type Resolver struct {
+	mu      sync.Mutex
+	client  rtorrent.Client
+	pending map[string]*pending
+}
+
+func New(client rtorrent.Client) *Resolver {
+	return &Resolver{
+		client:  client,
+		pending: make(map[string]*pending),
+	}
+}
+
+// SetClient swaps the rTorrent client, e.g. after setup connected to a
+// different instance. Pending magnets belonged to the old one and are
+// forgotten.
+func (r *Resolver) SetClient(client rtorrent.Client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = client
+	r.pending = make(map[string]*pending)
+}
+
+// Track remembers the options a magnet with the given info-hash was added
+// with until its metadata arrives.
+func (r *Resolver) Track(hash string, opts rtorrent.AddOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending[hash] = &pending{opts: opts, added: time.Now()}
+}
+
+// Run loops until ctx is cancelled
+func (r *Resolver) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check(ctx)
+		}
+	}
+}
+
+func (r *Resolver) check(ctx context.Context) {
+	r.mu.Lock()
+	client, tracked := r.client, len(r.pending)
+	r.mu.Unlock()
+	if tracked == 0 || client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pollInterval)
+	defer cancel()
+
+	torrents, err := client.GetTorrents(ctx)
+	if err != nil {
+		return
+	}
+	byHash := make(map[string]rtorrent.Torrent, len(torrents))
+	for _, t := range torrents {
+		byHash[t.Hash] = t
+	}
+
+	// Decide under the lock, then talk to rTorrent without it so Track
+	// never waits on a slow client
+	type resolved struct {
+		hash, name string
+		opts       rtorrent.AddOptions
+	}
+	var ready []resolved
+	r.mu.Lock()
+	if r.client != client {
+		// Setup switched instances meanwhile and forgot these magnets
+		r.mu.Unlock()
+		return
+	}
+	for hash, p := range r.pending {
+		t, ok := byHash[hash]
+		switch {
+		case !ok && !p.seen:
+			if time.Since(p.added) > appearTimeout {
+				log.Printf("Magnet %s never appeared in rTorrent, dropping its options", hash)
+				delete(r.pending, hash)
+			}
+		case !ok:
+			p.missing++
+			if p.missing >= maxMissing {
+				log.Printf("Magnet %s was removed before its metadata arrived", hash)
+				delete(r.pending, hash)
+			}
+		case t.FetchingMetadata:
+			p.seen, p.missing = true, 0
+		default:
+			ready = append(ready, resolved{hash: hash, name: t.Name, opts: p.opts})
+			delete(r.pending, hash)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, t := range ready {
+		if err := client.ApplyAddOptions(ctx, t.hash, t.opts); err != nil {
+			log.Printf("Failed to re-apply options to %s: %v", t.hash, err)
+		} else {
+			log.Printf("Metadata for %s (%s) arrived, re-applied its options", t.hash, t.name)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"rtorrent-go/internal/rtorrent"
+)
+
+// magnetClient is the mock client with a torrent list tests control and a
+// record of the options re-applied to each torrent
+type magnetClient struct {
+	rtorrent.Client
+
+	mu       sync.Mutex
+	torrents []rtorrent.Torrent
+	listErr  error
+	applyErr error
+	applied  map[string]rtorrent.AddOptions
+}
+
+func newMagnetClient(torrents ...rtorrent.Torrent) *magnetClient {
+	return &magnetClient{
+		Client:   rtorrent.NewClient("mock"),
+		torrents: torrents,
+		applied:  make(map[string]rtorrent.AddOptions),
+	}
+}
+
+func (c *magnetClient) GetTorrents(ctx context.Context) ([]rtorrent.Torrent, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]rtorrent.Torrent(nil), c.torrents...), c.listErr
+}
+
+func (c *magnetClient) ApplyAddOptions(ctx context.Context, hash string, opts rtorrent.AddOptions) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.applied[hash] = opts
+	return c.applyErr
+}
+
+func (c *magnetClient) set(torrents ...rtorrent.Torrent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.torrents = torrents
+}
+
+func (c *magnetClient) appliedTo(hash string) (rtorrent.AddOptions, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	opts, ok := c.applied[hash]
+	return opts, ok
+}
+
+func (r *Resolver) tracking(hash string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.pending[hash]
+	return ok
+}
+
+func TestCheckAppliesOptionsOnceResolved(t *testing.T) {
+	ctx := context.Background()
+	opts := rtorrent.AddOptions{Label: "OS", Priority: 3, ThrottleGroup: "slow", FilePriorities: map[int]int{1: 0}}
+	client := newMagnetClient(rtorrent.Torrent{Hash: "ABC", Name: "ABC", FetchingMetadata: true})
+	r := New(client)
+	r.Track("ABC", opts)
+
+	r.check(ctx)
+	if _, ok := client.appliedTo("ABC"); ok {
+		t.Fatal("options applied while metadata is still being fetched")
+	}
+
+	// The placeholder disappears briefly while rTorrent swaps it out
+	client.set()
+	r.check(ctx)
+	if !r.tracking("ABC") {
+		t.Fatal("magnet dropped during the swap")
+	}
+
+	client.set(rtorrent.Torrent{Hash: "ABC", Name: "Debian"})
+	r.check(ctx)
+	got, ok := client.appliedTo("ABC")
+	if !ok || !reflect.DeepEqual(got, opts) {
+		t.Fatalf("applied %+v (%v), want %+v", got, ok, opts)
+	}
+	if r.tracking("ABC") {
+		t.Error("magnet still tracked after its options were applied")
+	}
+}
+
+func TestCheckDropsEntries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("never appeared", func(t *testing.T) {
+		client := newMagnetClient()
+		r := New(client)
+		r.Track("ABC", rtorrent.AddOptions{Label: "x"})
+		r.check(ctx)
+		if !r.tracking("ABC") {
+			t.Fatal("magnet dropped before the timeout")
+		}
+		r.pending["ABC"].added = time.Now().Add(-appearTimeout - time.Second)
+		r.check(ctx)
+		if r.tracking("ABC") {
+			t.Error("magnet kept after the timeout")
+		}
+	})
+
+	t.Run("removed while fetching", func(t *testing.T) {
+		client := newMagnetClient(rtorrent.Torrent{Hash: "ABC", FetchingMetadata: true})
+		r := New(client)
+		r.Track("ABC", rtorrent.AddOptions{Label: "x"})
+		r.check(ctx)
+		client.set()
+		for i := 0; i < maxMissing; i++ {
+			if !r.tracking("ABC") {
+				t.Fatalf("magnet dropped after %d missed polls", i)
+			}
+			r.check(ctx)
+		}
+		if r.tracking("ABC") {
+			t.Error("magnet kept after it was removed")
+		}
+		if _, ok := client.appliedTo("ABC"); ok {
+			t.Error("options applied to a removed torrent")
+		}
+	})
+
+	t.Run("apply fails", func(t *testing.T) {
+		client := newMagnetClient(rtorrent.Torrent{Hash: "ABC"})
+		client.applyErr = errors.New("boom")
+		r := New(client)
+		r.Track("ABC", rtorrent.AddOptions{Label: "x"})
+		r.check(ctx)
+		if _, ok := client.appliedTo("ABC"); !ok {
+			t.Fatal("options never applied")
+		}
+		if r.tracking("ABC") {
+			t.Error("magnet kept after a failed apply")
+		}
+	})
+
+	t.Run("listing fails", func(t *testing.T) {
+		client := newMagnetClient()
+		client.listErr = errors.New("unreachable")
+		r := New(client)
+		r.Track("ABC", rtorrent.AddOptions{Label: "x"})
+		r.pending["ABC"].added = time.Now().Add(-appearTimeout - time.Second)
+		r.check(ctx)
+		if !r.tracking("ABC") {
+			t.Error("magnet dropped although rTorrent could not be asked")
+		}
+	})
+}
+
+func TestSetClientForgetsPending(t *testing.T) {
+	r := New(newMagnetClient())
+	r.Track("ABC", rtorrent.AddOptions{})
+	r.SetClient(newMagnetClient())
+	if r.tracking("ABC") {
+		t.Error("magnet of the old client still tracked")
+	}
+}
@@ -0,0 +1,179 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"rtorrent-go/internal/metainfo"
+)
+
+// customDisplayName holds a name chosen at add time, shown instead of the
+// torrent's own
+const customDisplayName = "vt_title"
+
+// AddOptions are the choices made when adding a torrent. Everything rTorrent
+// can take as a load-time command is passed that way.
+type AddOptions struct {
+	AutoStart    bool   `json:"auto_start"`
+	DownloadPath string `json:"download_path"`
+	Label        string `json:"label"`
+	// Priority is 1 (low) to 3 (high); 0 keeps rTorrent's default
+	Priority int `json:"priority"`
+	// Name replaces the torrent's name in the UI
+	Name          string `json:"name"`
+	ThrottleGroup string `json:"throttle_group"`
+	// FilePriorities maps file indices to their initial priority, e.g. 0
+	// to skip a file
+	FilePriorities map[int]int `json:"file_priorities"`
+	// SkipHashCheck marks data already in DownloadPath as complete so it
+	// seeds without being hashed. Only .torrent data supports it.
+	SkipHashCheck bool `json:"skip_hash_check"`
+}
+
+// ErrSkipHashCheck is returned when a hash check can't be skipped, e.g. for
+// a torrent added by URL
+var ErrSkipHashCheck = errors.New("cannot skip the hash check")
+
+// loadCommands turns the options into commands run on the new download
+func (o AddOptions) loadCommands() []Value {
+	var cmds []string
+	if o.DownloadPath != "" {
+		cmds = append(cmds, "d.directory_base.set="+commandArg(o.DownloadPath))
+	}
+	if o.Label != "" {
+		cmds = append(cmds, "d.custom1.set="+commandArg(o.Label))
+	}
+	if o.Priority > 0 {
+		cmds = append(cmds, fmt.Sprintf("d.priority.set=%d", o.Priority))
+	}
+	if o.Name != "" {
+		cmds = append(cmds, fmt.Sprintf("d.custom.set=%s,%s", customDisplayName, commandArg(o.Name)))
+	}
+	if o.ThrottleGroup != "" {
+		cmds = append(cmds, "d.throttle_name.set="+commandArg(o.ThrottleGroup))
+	}
+
+	values := make([]Value, len(cmds))
+	for i, cmd := range cmds {
+		values[i] = Value{String: stringPtr(cmd)}
+	}
+	return values
+}
+
+// applyFilePriorities sets the initial file priorities, one multicall per
+// distinct priority
+func (c *xmlrpcClient) applyFilePriorities(ctx context.Context, hash string, priorities map[int]int) error {
+	byPriority := make(map[int][]int)
+	for index, priority := range priorities {
+		byPriority[priority] = append(byPriority[priority], index)
+	}
+	for priority, indices := range byPriority {
+		sort.Ints(indices)
+		if err := c.SetFilePriority(ctx, hash, indices, priority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addData loads .torrent data with the given options. File priorities have
+// no load-time command, so torrents with any are loaded stopped, adjusted
+// and then started.
+func (c *xmlrpcClient) addData(ctx context.Context, data []byte, opts AddOptions) error {
+	var meta *metainfo.MetaInfo
+	if opts.SkipHashCheck || len(opts.FilePriorities) > 0 {
+		var err error
+		if meta, err = metainfo.Parse(data); err != nil {
+			return err
+		}
+	}
+
+	if opts.SkipHashCheck {
+		dir := opts.DownloadPath
+		if dir == "" {
+			resp, err := c.call(ctx, "directory.default")
+			if err != nil {
+				return err
+			}
+			if len(resp.Params) == 0 {
+				return fmt.Errorf("%w: empty directory.default response", ErrProtocol)
+			}
+			dir = resp.Params[0].Value.GetString()
+			// Without d.directory_base.set, rTorrent puts the files of a
+			// multi-file torrent in a folder named after it
+			if meta.MultiFile {
+				dir = filepath.Join(dir, meta.Name)
+			}
+		}
+		resumed, err := metainfo.WithFastResume(data, dir, opts.FilePriorities)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrSkipHashCheck, err)
+		}
+		data = resumed
+	}
+
+	deferStart := opts.AutoStart && len(opts.FilePriorities) > 0
+	method := "load.raw"
+	if opts.AutoStart && !deferStart {
+		method = "load.raw_start"
+	}
+	args := append([]Value{
+		{String: stringPtr("")}, // Target (empty for default)
+		{Base64: data},          // Torrent file data as base64
+	}, opts.loadCommands()...)
+	if _, err := c.call(ctx, method, args...); err != nil {
+		return err
+	}
+
+	if len(opts.FilePriorities) > 0 {
+		if err := c.applyFilePriorities(ctx, meta.InfoHash, opts.FilePriorities); err != nil {
+			return err
+		}
+	}
+	if deferStart {
+		return c.StartTorrent(ctx, meta.InfoHash)
+	}
+	return nil
+}
+
+// ApplyAddOptions applies add-time options to a torrent that is already
+// loaded, e.g. once a magnet's metadata has replaced the placeholder.
+func (c *xmlrpcClient) ApplyAddOptions(ctx context.Context, hash string, opts AddOptions) error {
+	if opts.DownloadPath != "" {
+		if err := c.SetDirectory(ctx, hash, opts.DownloadPath); err != nil {
+			return err
+		}
+	}
+	if opts.Label != "" {
+		if err := c.SetLabel(ctx, hash, opts.Label); err != nil {
+			return err
+		}
+	}
+	if opts.Priority > 0 {
+		if err := c.SetPriority(ctx, hash, opts.Priority); err != nil {
+			return err
+		}
+	}
+	if opts.Name != "" {
+		if _, err := c.call(ctx, "d.custom.set", Value{String: stringPtr(hash)}, Value{String: stringPtr(customDisplayName)}, Value{String: stringPtr(opts.Name)}); err != nil {
+			return err
+		}
+	}
+	if opts.ThrottleGroup != "" {
+		if err := c.SetTorrentThrottle(ctx, hash, opts.ThrottleGroup); err != nil {
+			return err
+		}
+	}
+	if len(opts.FilePriorities) > 0 {
+		if err := c.applyFilePriorities(ctx, hash, opts.FilePriorities); err != nil {
+			return err
+		}
+	}
+	if !opts.AutoStart {
+		return c.StopTorrent(ctx, hash)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rtorrent-go/internal/bencode"
+	"rtorrent-go/internal/metainfo"
+)
+
+const testPieceLength = 16
+
+// testTorrent builds .torrent data. With no paths it is a single-file
+// torrent of length bytes; otherwise each path is a file of length bytes
+// in an info.files list.
+func testTorrent(t *testing.T, name string, length int, paths ...string) []byte {
+	t.Helper()
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": testPieceLength,
+	}
+	total := length
+	if len(paths) == 0 {
+		info["length"] = length
+	} else {
+		total = length * len(paths)
+		var files []interface{}
+		for _, p := range paths {
+			var parts []interface{}
+			for _, part := range strings.Split(p, "/") {
+				parts = append(parts, part)
+			}
+			files = append(files, map[string]interface{}{"length": length, "path": parts})
+		}
+		info["files"] = files
+	}
+	pieces := (total + testPieceLength - 1) / testPieceLength
+	info["pieces"] = strings.Repeat("x", 20*pieces)
+
+	data, err := bencode.Encode(map[string]interface{}{
+		"announce": "http://tracker.example/announce",
+		"info":     info,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func writeTestFile(t *testing.T, path string, length int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, make([]byte, length), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		opts AddOptions
+		want []string
+	}{
+		{name: "defaults", opts: AddOptions{AutoStart: true}},
+		{
+			name: "everything",
+			opts: AddOptions{
+				DownloadPath:  "/data/my \"films\"",
+				Label:         `a\b`,
+				Priority:      3,
+				Name:          "Nice, name",
+				ThrottleGroup: "slow",
+			},
+			want: []string{
+				`d.directory_base.set="/data/my \"films\""`,
+				`d.custom1.set="a\\b"`,
+				`d.priority.set=3`,
+				`d.custom.set=vt_title,"Nice, name"`,
+				`d.throttle_name.set="slow"`,
+			},
+		},
+		{
+			name: "file priorities and hash check have no load command",
+			opts: AddOptions{FilePriorities: map[int]int{0: 0}, SkipHashCheck: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range tt.opts.loadCommands() {
+				got = append(got, v.GetString())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadCommands() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddDataStart(t *testing.T) {
+	data := testTorrent(t, "multi", 20, "a.bin", "b.bin", "c.bin")
+	meta, err := metainfo.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts AddOptions
+		want []string
+	}{
+		{
+			name: "auto start",
+			opts: AddOptions{AutoStart: true},
+			want: []string{"load.raw_start"},
+		},
+		{
+			name: "stopped",
+			opts: AddOptions{},
+			want: []string{"load.raw"},
+		},
+		{
+			// Loaded stopped so no skipped file is allocated before its
+			// priority is set, then started
+			name: "file priorities defer the start",
+			opts: AddOptions{AutoStart: true, FilePriorities: map[int]int{1: 0}},
+			want: []string{"load.raw", "f.priority.set", "d.update_priorities", "d.start"},
+		},
+		{
+			name: "file priorities without auto start",
+			opts: AddOptions{FilePriorities: map[int]int{0: 2, 2: 2}},
+			want: []string{"load.raw", "f.priority.set", "f.priority.set", "d.update_priorities"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, c := newFakeRTorrent(t, nil)
+			if err := c.AddTorrentByData(context.Background(), data, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.Methods(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("calls = %q, want %q", got, tt.want)
+			}
+			for _, call := range fake.Calls()[1:] {
+				target := call.Args[0].GetString()
+				if !strings.HasPrefix(target, meta.InfoHash) {
+					t.Errorf("%s targets %q, want torrent %s", call.Method, target, meta.InfoHash)
+				}
+			}
+		})
+	}
+}
+
+func TestAddDataSkipHashCheck(t *testing.T) {
+	single := testTorrent(t, "single.iso", 40)
+	multi := testTorrent(t, "album", 10, "one.flac", "disc 2/two.flac")
+
+	tests := []struct {
+		name         string
+		data         []byte
+		downloadPath bool
+		// files are created relative to the temporary directory
+		files   []string
+		wantErr bool
+	}{
+		{
+			name:  "single file in directory.default",
+			data:  single,
+			files: []string{"single.iso"},
+		},
+		{
+			name:         "single file in download path",
+			data:         single,
+			downloadPath: true,
+			files:        []string{"single.iso"},
+		},
+		{
+			name:  "multi-file in a folder under directory.default",
+			data:  multi,
+			files: []string{"album/one.flac", "album/disc 2/two.flac"},
+		},
+		{
+			name:         "multi-file directly in download path",
+			data:         multi,
+			downloadPath: true,
+			files:        []string{"one.flac", "disc 2/two.flac"},
+		},
+		{
+			// The files would only be found here if directory.default
+			// were taken as the base
+			name:    "multi-file outside its folder",
+			data:    multi,
+			files:   []string{"one.flac", "disc 2/two.flac"},
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			data:    multi,
+			files:   []string{"album/one.flac"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			meta, err := metainfo.Parse(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, f := range tt.files {
+				writeTestFile(t, filepath.Join(dir, filepath.FromSlash(f)), int(meta.Files[i].Length))
+			}
+
+			fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+				if method == "directory.default" {
+					return Value{String: stringPtr(dir)}, nil
+				}
+				return Value{Int: intPtr(0)}, nil
+			})
+			opts := AddOptions{SkipHashCheck: true}
+			if tt.downloadPath {
+				opts.DownloadPath = dir
+			}
+			err = c.AddTorrentByData(context.Background(), tt.data, opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSkipHashCheck) {
+					t.Fatalf("err = %v, want ErrSkipHashCheck", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var loaded []byte
+			for _, call := range fake.Calls() {
+				if call.Method == "load.raw" {
+					loaded = call.Args[1].Base64
+				}
+			}
+			top, err := bencode.Split(loaded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, ok := top["libtorrent_resume"]
+			if !ok {
+				t.Fatal("loaded torrent has no libtorrent_resume")
+			}
+			decoded, err := bencode.Decode(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resume := decoded.(map[string]interface{})
+			if got := resume["bitfield"]; got != int64(meta.PieceCount) {
+				t.Errorf("bitfield = %v, want %d", got, meta.PieceCount)
+			}
+			if files := resume["files"].([]interface{}); len(files) != len(meta.Files) {
+				t.Errorf("resume has %d files, want %d", len(files), len(meta.Files))
+			}
+		})
+	}
+}
+
+func TestMockRecordsAddOptions(t *testing.T) {
+	client := NewClient("mock").(*mockClient)
+	first := AddOptions{AutoStart: true, Label: "linux"}
+	second := AddOptions{DownloadPath: "/data", FilePriorities: map[int]int{0: 0}}
+	if err := client.AddTorrentByData(context.Background(), testTorrent(t, "a", 1), first); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddTorrentByUrl(context.Background(), "http://example.com/a.torrent", second); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.AddedOptions(), []AddOptions{first, second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AddedOptions() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package rtorrent
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+)
+
+// Bitfield records which pieces of a torrent are complete. Bit i is the
+// most significant bit first, as in the BitTorrent wire format.
+type Bitfield struct {
+	PieceCount int
+	bits       []byte
+}
+
+// NewBitfield wraps a raw bitmap of pieceCount pieces
+func NewBitfield(pieceCount int, raw []byte) (*Bitfield, error) {
+	if pieceCount < 0 || len(raw) != (pieceCount+7)/8 {
+		return nil, fmt.Errorf("%w: bitfield of %d bytes for %d pieces", ErrProtocol, len(raw), pieceCount)
+	}
+	return &Bitfield{PieceCount: pieceCount, bits: raw}, nil
+}
+
+// Has reports whether piece i is complete
+func (b *Bitfield) Has(i int) bool {
+	if i < 0 || i >= b.PieceCount {
+		return false
+	}
+	return b.bits[i/8]&(0x80>>(i%8)) != 0
+}
+
+// Completed counts the complete pieces
+func (b *Bitfield) Completed() int {
+	return b.count(0, b.PieceCount)
+}
+
+// Buckets splits the pieces into n consecutive, nearly equal ranges and
+// returns how many percent of each range is complete. n is capped at the
+// piece count so no bucket is empty.
+func (b *Bitfield) Buckets(n int) []int {
+	if n > b.PieceCount {
+		n = b.PieceCount
+	}
+	if n <= 0 {
+		return []int{}
+	}
+	buckets := make([]int, n)
+	for i := range buckets {
+		start := i * b.PieceCount / n
+		end := (i + 1) * b.PieceCount / n
+		buckets[i] = b.count(start, end) * 100 / (end - start)
+	}
+	return buckets
+}
+
+// count returns the complete pieces in [start, end), a byte at a time
+// where it can
+func (b *Bitfield) count(start, end int) int {
+	n := 0
+	for i := start; i < end; {
+		if i%8 == 0 && end-i >= 8 {
+			n += bits.OnesCount8(b.bits[i/8])
+			i += 8
+			continue
+		}
+		if b.Has(i) {
+			n++
+		}
+		i++
+	}
+	return n
+}
+
+// GetBitfield fetches and decodes d.bitfield. rTorrent returns an empty
+// bitfield for closed torrents, which is filled in from the completed
+// chunk count: all or nothing.
+func (c *xmlrpcClient) GetBitfield(ctx context.Context, hash string) (*Bitfield, error) {
+	results, err := c.multicall(ctx, torrentGetters(hash, "d.size_chunks", "d.completed_chunks", "d.bitfield"))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+	pieceCount := int(results[0].Value.GetLong())
+	completed := int(results[1].Value.GetLong())
+
+	encoded := results[2].Value.GetString()
+	if encoded == "" {
+		raw := make([]byte, (pieceCount+7)/8)
+		if pieceCount > 0 && completed == pieceCount {
+			for i := range raw {
+				raw[i] = 0xff
+			}
+			// Keep the padding bits clear
+			if rem := pieceCount % 8; rem != 0 {
+				raw[len(raw)-1] = byte(0xff << (8 - rem))
+			}
+		}
+		return NewBitfield(pieceCount, raw)
+	}
+	raw, err := hex.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bitfield is not hex: %v", ErrProtocol, err)
+	}
+	return NewBitfield(pieceCount, raw)
+}
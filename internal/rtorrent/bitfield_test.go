@@ -0,0 +1,186 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// bitfieldRTorrent answers the d.bitfield getters with fixed values
+func bitfieldRTorrent(t *testing.T, pieces, completed int64, encoded string) *xmlrpcClient {
+	t.Helper()
+	_, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		switch method {
+		case "d.size_chunks":
+			return Value{I8: intPtr(pieces)}, nil
+		case "d.completed_chunks":
+			return Value{I8: intPtr(completed)}, nil
+		case "d.bitfield":
+			return Value{String: stringPtr(encoded)}, nil
+		}
+		return Value{}, &FaultError{Code: -506, Message: "Method '" + method + "' not defined"}
+	})
+	return c
+}
+
+func TestGetBitfield(t *testing.T) {
+	tests := []struct {
+		name      string
+		pieces    int64
+		completed int64
+		encoded   string
+		want      []bool
+		buckets   int
+		wantPct   []int
+	}{
+		{
+			name:    "most significant bit first",
+			pieces:  8,
+			encoded: "81",
+			want:    []bool{true, false, false, false, false, false, false, true},
+			buckets: 2,
+			wantPct: []int{25, 25},
+		},
+		{
+			name:    "uppercase hex",
+			pieces:  8,
+			encoded: "F0",
+			want:    []bool{true, true, true, true, false, false, false, false},
+			buckets: 4,
+			wantPct: []int{100, 100, 0, 0},
+		},
+		{
+			name:    "piece count not a multiple of 8",
+			pieces:  10,
+			encoded: "ffc0",
+			want:    []bool{true, true, true, true, true, true, true, true, true, true},
+			buckets: 3,
+			wantPct: []int{100, 100, 100},
+		},
+		{
+			name:    "partial last bucket",
+			pieces:  10,
+			encoded: "0040",
+			want:    []bool{false, false, false, false, false, false, false, false, false, true},
+			// Buckets of 3, 3 and 4 pieces
+			buckets: 3,
+			wantPct: []int{0, 0, 25},
+		},
+		{
+			name:    "more buckets than pieces",
+			pieces:  3,
+			encoded: "a0",
+			want:    []bool{true, false, true},
+			buckets: 10,
+			wantPct: []int{100, 0, 100},
+		},
+		{
+			name:      "closed and complete",
+			pieces:    10,
+			completed: 10,
+			want:      []bool{true, true, true, true, true, true, true, true, true, true},
+			buckets:   2,
+			wantPct:   []int{100, 100},
+		},
+		{
+			name:      "closed and incomplete",
+			pieces:    10,
+			completed: 4,
+			want:      make([]bool, 10),
+			buckets:   2,
+			wantPct:   []int{0, 0},
+		},
+		{
+			name:    "no pieces",
+			want:    []bool{},
+			buckets: 4,
+			wantPct: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := bitfieldRTorrent(t, tt.pieces, tt.completed, tt.encoded)
+			b, err := c.GetBitfield(context.Background(), "ABC")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.PieceCount != int(tt.pieces) {
+				t.Fatalf("piece count = %d, want %d", b.PieceCount, tt.pieces)
+			}
+			got := make([]bool, b.PieceCount)
+			completed := 0
+			for i := range got {
+				got[i] = b.Has(i)
+				if got[i] {
+					completed++
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pieces = %v, want %v", got, tt.want)
+			}
+			if b.Completed() != completed {
+				t.Errorf("Completed() = %d, want %d", b.Completed(), completed)
+			}
+			if b.Has(-1) || b.Has(b.PieceCount) {
+				t.Error("Has reports pieces out of range")
+			}
+			if pct := b.Buckets(tt.buckets); !reflect.DeepEqual(pct, tt.wantPct) {
+				t.Errorf("Buckets(%d) = %v, want %v", tt.buckets, pct, tt.wantPct)
+			}
+		})
+	}
+}
+
+func TestGetBitfieldInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		pieces  int64
+		encoded string
+	}{
+		{name: "not hex", pieces: 8, encoded: "zz"},
+		{name: "odd length", pieces: 8, encoded: "f"},
+		{name: "too short", pieces: 9, encoded: "ff"},
+		{name: "too long", pieces: 8, encoded: "ffff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := bitfieldRTorrent(t, tt.pieces, 0, tt.encoded)
+			if _, err := c.GetBitfield(context.Background(), "ABC"); !errors.Is(err, ErrProtocol) {
+				t.Errorf("err = %v, want ErrProtocol", err)
+			}
+		})
+	}
+}
+
+func TestBucketsMatchHas(t *testing.T) {
+	// 1000 pieces with every other byte complete; buckets of 10 pieces
+	// straddle byte boundaries, so whole-byte counting has to agree with
+	// counting piece by piece
+	raw := make([]byte, 125)
+	for i := 0; i < len(raw); i += 2 {
+		raw[i] = 0xff
+	}
+	b, err := NewBitfield(1000, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := b.Buckets(100)
+	if len(buckets) != 100 {
+		t.Fatalf("got %d buckets, want 100", len(buckets))
+	}
+	for i, pct := range buckets {
+		want := 0
+		for piece := i * 10; piece < (i+1)*10; piece++ {
+			if b.Has(piece) {
+				want += 10
+			}
+		}
+		if pct != want {
+			t.Errorf("bucket %d = %d%%, want %d%%", i, pct, want)
+		}
+	}
+	if b.Completed() != 504 {
+		t.Errorf("Completed() = %d, want 504", b.Completed())
+	}
+}
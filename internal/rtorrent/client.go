@@ -1,133 +1,19 @@
 package rtorrent
 
 import (
-	"bytes"
+	"bufio"
 	"context"
 	"encoding/xml"
 	"fmt"
 	"log"
 	"net"
+	"os"
 	"strings"
+	"sync"
 	"time"
-)
-
-// XML-RPC Structures
-type MethodCall struct {
-	XMLName    xml.Name `xml:"methodCall"`
-	MethodName string   `xml:"methodName"`
-	Params     []Param  `xml:"params>param"`
-}
-
-type Param struct {
-	Value Value `xml:"value"`
-}
-
-func (v Value) GetLong() int64 {
-	if v.Int != nil {
-		return *v.Int
-	}
-	if v.I4 != nil {
-		return *v.I4
-	}
-	if v.I8 != nil {
-		return *v.I8
-	}
-	return 0
-}
-
-func (v Value) GetString() string {
-	if v.String != nil {
-		return *v.String
-	}
-	return ""
-}
-
-func (v Value) GetArray() []Value {
-	if v.Array != nil {
-		return v.Array.Data
-	}
-	return nil
-}
 
-type Value struct {
-	String  *string   `xml:"string,omitempty"`
-	Int     *int64    `xml:"int,omitempty"`
-	I4      *int64    `xml:"i4,omitempty"`
-	I8      *int64    `xml:"i8,omitempty"`
-	Double  *float64  `xml:"double,omitempty"`
-	Boolean *bool     `xml:"boolean,omitempty"`
-	Base64  []byte    `xml:"base64,omitempty"`
-	Array   *ValArray `xml:"array,omitempty"`
-}
-
-func (v Value) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	if v.String != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			String  string   `xml:"string"`
-		}
-		return e.Encode(temp{String: *v.String})
-	}
-	if v.Int != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			Int     int64    `xml:"int"`
-		}
-		return e.Encode(temp{Int: *v.Int})
-	}
-	if v.I4 != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			I4      int64    `xml:"i4"`
-		}
-		return e.Encode(temp{I4: *v.I4})
-	}
-	if v.Double != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			Double  float64  `xml:"double"`
-		}
-		return e.Encode(temp{Double: *v.Double})
-	}
-	if v.Boolean != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			Boolean bool     `xml:"boolean"`
-		}
-		return e.Encode(temp{Boolean: *v.Boolean})
-	}
-	if v.Base64 != nil {
-		type temp struct {
-			XMLName xml.Name `xml:"value"`
-			Base64  []byte   `xml:"base64"`
-		}
-		return e.Encode(temp{Base64: v.Base64})
-	}
-	if v.Array != nil {
-		type temp struct {
-			XMLName xml.Name  `xml:"value"`
-			Array   *ValArray `xml:"array"`
-		}
-		return e.Encode(temp{Array: v.Array})
-	}
-	// Fallback/Empty
-	return e.Encode(struct {
-		XMLName xml.Name `xml:"value"`
-	}{})
-}
-
-type ValArray struct {
-	Data []Value `xml:"data>value"`
-}
-
-type MethodResponse struct {
-	Params []Param `xml:"params>param"`
-	Fault  *Fault  `xml:"fault,omitempty"`
-}
-
-type Fault struct {
-	Value Value `xml:"value"`
-}
+	"rtorrent-go/internal/magnet"
+)
 
 // Torrent matching our application's needs
 type Torrent struct {
@@ -146,57 +32,184 @@ type Torrent struct {
 	PieceSize  int64  `json:"piece_size"`
 	SavePath   string `json:"save_path"`
 	Priority   int    `json:"priority"`
+	// ThrottleName is the named throttle group, empty for the global one
+	ThrottleName string `json:"throttle_name"`
+	// FetchingMetadata is set for magnets rTorrent hasn't resolved yet
+	FetchingMetadata bool `json:"fetching_metadata"`
 }
 
 type File struct {
+	Index     int    `json:"index"`
 	Name      string `json:"name"`
 	Size      int64  `json:"size"`
 	Completed int64  `json:"completed"`
 	Priority  int    `json:"priority"`
 }
 
+type Peer struct {
+	ID           string  `json:"id"`
+	Address      string  `json:"address"`
+	Port         int     `json:"port"`
+	Client       string  `json:"client"`
+	DownloadRate int     `json:"download_rate"`
+	UploadRate   int     `json:"upload_rate"`
+	Progress     float64 `json:"progress"`
+	Encrypted    bool    `json:"encrypted"`
+	Incoming     bool    `json:"incoming"`
+	Snubbed      bool    `json:"snubbed"`
+	// Country is an ISO 3166-1 code filled in by the GeoIP lookup, not
+	// by rTorrent
+	Country string `json:"country,omitempty"`
+}
+
+type Tracker struct {
+	Index        int    `json:"index"`
+	URL          string `json:"url"`
+	Type         string `json:"type"`
+	Enabled      bool   `json:"enabled"`
+	Seeders      int    `json:"seeders"`
+	Leechers     int    `json:"leechers"`
+	LastAnnounce int64  `json:"last_announce"`
+	NextAnnounce int64  `json:"next_announce"`
+	LastError    string `json:"last_error"`
+}
+
 type Client interface {
 	TestConnection() error
+	GetPID(ctx context.Context) (int64, error)
 	GetTorrents(ctx context.Context) ([]Torrent, error)
 	DeleteTorrent(ctx context.Context, hash string) error
+	DeleteTorrentWithData(ctx context.Context, hash string) error
+	MoveStorage(ctx context.Context, hash, newPath string, moveFiles bool) error
 	GetTorrentFiles(ctx context.Context, hash string) ([]File, error)
+	SetFilePriority(ctx context.Context, hash string, indices []int, priority int) error
 	GetTorrentDetails(ctx context.Context, hash string) (*Torrent, error)
-	AddTorrentByUrl(ctx context.Context, url string, autoStart bool, downloadPath string) error
-	AddTorrentByData(ctx context.Context, data []byte, autoStart bool, downloadPath string) error
+	GetPeers(ctx context.Context, hash string) ([]Peer, error)
+	GetTrackers(ctx context.Context, hash string) ([]Tracker, error)
+	SetTrackerEnabled(ctx context.Context, hash string, index int, enabled bool) error
+	AddTracker(ctx context.Context, hash string, url string) error
+	ReannounceTorrent(ctx context.Context, hash string) error
+	AddTorrentByUrl(ctx context.Context, url string, opts AddOptions) error
+	AddTorrentByData(ctx context.Context, data []byte, opts AddOptions) error
+	ApplyAddOptions(ctx context.Context, hash string, opts AddOptions) error
 	StartTorrent(ctx context.Context, hash string) error
 	PauseTorrent(ctx context.Context, hash string) error
 	StopTorrent(ctx context.Context, hash string) error
 	RecheckTorrent(ctx context.Context, hash string) error
 	SetPriority(ctx context.Context, hash string, priority int) error
 	SetLabel(ctx context.Context, hash string, label string) error
+	GetGlobalThrottle(ctx context.Context) (*Throttle, error)
+	SetGlobalThrottle(ctx context.Context, t Throttle) error
+	GetMaxPeers(ctx context.Context) (int64, error)
+	SetMaxPeers(ctx context.Context, n int64) error
+	SetThrottleGroup(ctx context.Context, name string, downloadRate, uploadRate int64) error
+	SetTorrentThrottle(ctx context.Context, hash, name string) error
+	Capabilities(ctx context.Context) (Capabilities, error)
+	GetPieceModes(ctx context.Context, hash string) (*PieceModes, error)
+	SetFirstLastPiece(ctx context.Context, hash string, enabled bool) error
+	SetSequential(ctx context.Context, hash string, enabled bool) error
+	GetBitfield(ctx context.Context, hash string) (*Bitfield, error)
+	DisconnectPeer(ctx context.Context, hash, peerID string) error
+	BanPeer(ctx context.Context, hash, peerID string) error
+	SetPeerSnubbed(ctx context.Context, hash, peerID string, snubbed bool) error
+	AddPeer(ctx context.Context, hash, address string) error
+	LoadIPFilter(ctx context.Context, path string) error
+	AddIPFilterBlocks(ctx context.Context, blocks []string) error
+	IPFilterSize(ctx context.Context) (int64, error)
+}
+
+// Option configures optional behaviour of the XML-RPC client
+type Option func(*xmlrpcClient)
+
+// WithMaxResponseSize limits how many bytes a single response may contain.
+// Zero or negative values fall back to DefaultMaxResponseSize.
+func WithMaxResponseSize(n int64) Option {
+	return func(c *xmlrpcClient) {
+		if n > 0 {
+			c.maxResponseSize = n
+		}
+	}
 }
 
-func NewClient(addr string) Client {
+// WithTimeout bounds every request to rTorrent, on top of any deadline the
+// caller's context already carries.
+func WithTimeout(d time.Duration) Option {
+	return func(c *xmlrpcClient) {
+		c.timeout = d
+	}
+}
+
+func NewClient(addr string, opts ...Option) Client {
 	if addr == "mock" {
 		log.Println("Initializing rTorrent client in MOCK mode")
-		return &mockClient{}
+		return &mockClient{maxPeers: 100, throttleNames: make(map[string]string), pieceModes: make(map[string]PieceModes), ipFilter: make(map[string]bool)}
 	}
 	log.Printf("Initializing rTorrent client in REAL mode at %s", addr)
-	return &xmlrpcClient{
-		addr: addr,
+	c := &xmlrpcClient{
+		addr:            addr,
+		maxResponseSize: DefaultMaxResponseSize,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 type xmlrpcClient struct {
-	addr string
+	addr            string
+	maxResponseSize int64
+	timeout         time.Duration
+	roots           []string
+
+	capsMu sync.Mutex
+	// caps is filled in by the first Capabilities call
+	caps *Capabilities
+}
+
+// mockClient serves canned data; the little state it keeps lets settings
+// round-trip through the UI in mock mode.
+type mockClient struct {
+	mu       sync.Mutex
+	throttle Throttle
+	maxPeers int64
+	// throttleNames maps torrent hashes to their throttle group
+	throttleNames map[string]string
+	// added records the options of every add, newest last
+	added []AddOptions
+	// pieceModes holds the piece-priority toggles per torrent hash
+	pieceModes map[string]PieceModes
+	// ipFilter is the set of blocked CIDR blocks
+	ipFilter map[string]bool
+}
+
+// AddedOptions returns the options of every torrent added so far, so
+// tests can check what the handlers passed down.
+func (m *mockClient) AddedOptions() []AddOptions {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]AddOptions(nil), m.added...)
 }
 
-type mockClient struct{}
-
 func (m *mockClient) TestConnection() error {
 	return nil
 }
 
+func (m *mockClient) GetPID(ctx context.Context) (int64, error) {
+	return 4242, nil
+}
+
 func (m *mockClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
-	return []Torrent{
+	torrents := []Torrent{
 		{Hash: "123", Name: "Demo Movie 2024", Size: 4500000000, Completed: 2250000000, DownloadRate: 500000, UploadRate: 100000, State: "downloading", Progress: 50, Label: "Movies", DateAdded: 1700000000, PieceCount: 1200, PieceSize: 4194304, SavePath: "/downloads/movies"},
 		{Hash: "456", Name: "Linux ISO 23.10", Size: 2100000000, Completed: 2100000000, DownloadRate: 0, UploadRate: 250000, State: "seeding", Progress: 100, Label: "OS", DateAdded: 1690000000, PieceCount: 600, PieceSize: 2097152, SavePath: "/downloads/isos"},
-	}, nil
+		{Hash: "789", Name: "Debian 12.5 netinst", Size: 658505728, State: "metadata", Label: "OS", DateAdded: 1710000000, SavePath: "/downloads/isos", FetchingMetadata: true},
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range torrents {
+		torrents[i].ThrottleName = m.throttleNames[torrents[i].Hash]
+	}
+	return torrents, nil
 }
 
 func (m *mockClient) GetTorrentDetails(ctx context.Context, hash string) (*Torrent, error) {
@@ -205,25 +218,166 @@ func (m *mockClient) GetTorrentDetails(ctx context.Context, hash string) (*Torre
 
 func (m *mockClient) GetTorrentFiles(ctx context.Context, hash string) ([]File, error) {
 	return []File{
-		{Name: "file1.mp4", Size: 500000000, Completed: 250000000},
-		{Name: "file2.jpg", Size: 1000000, Completed: 1000000},
+		{Index: 0, Name: "Season 1/Episode 01.mkv", Size: 350000000, Completed: 350000000, Priority: 1},
+		{Index: 1, Name: "Season 1/Episode 02.mkv", Size: 350000000, Completed: 120000000, Priority: 2},
+		{Index: 2, Name: "Season 1/Subs/Episode 01.srt", Size: 45000, Completed: 45000, Priority: 1},
+		{Index: 3, Name: "Season 2/Episode 01.mkv", Size: 380000000, Completed: 0, Priority: 0},
+		{Index: 4, Name: "cover.jpg", Size: 1000000, Completed: 1000000, Priority: 1},
 	}, nil
 }
 
+func (m *mockClient) SetFilePriority(ctx context.Context, hash string, indices []int, priority int) error {
+	log.Printf("Mock: Setting priority of files %v in %s to %d", indices, hash, priority)
+	return nil
+}
+
+// GetBitfield makes up a half-done download: a solid start followed by
+// scattered pieces, like a swarm delivering rarest-first
+func (m *mockClient) GetBitfield(ctx context.Context, hash string) (*Bitfield, error) {
+	const pieceCount = 1200
+	raw := make([]byte, (pieceCount+7)/8)
+	for i := 0; i < pieceCount; i++ {
+		if i < 300 || (i*37)%100 < 35 {
+			raw[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return NewBitfield(pieceCount, raw)
+}
+
+func (m *mockClient) GetPeers(ctx context.Context, hash string) ([]Peer, error) {
+	return []Peer{
+		{ID: "2D5142343632302D", Address: "203.0.113.24", Port: 51413, Client: "qBittorrent 4.6.2", DownloadRate: 312000, UploadRate: 18000, Progress: 100, Encrypted: true},
+		{ID: "2D5452343035302D", Address: "198.51.100.7", Port: 6881, Client: "Transmission 4.0.5", DownloadRate: 121000, UploadRate: 42000, Progress: 87, Encrypted: true, Incoming: true},
+		{ID: "2D4C54323039302D", Address: "192.0.2.181", Port: 49152, Client: "libtorrent (Rasterbar) 2.0.9", DownloadRate: 64000, UploadRate: 0, Progress: 100},
+		{ID: "2D4445323131302D", Address: "2001:db8::4a2f", Port: 58846, Client: "Deluge 2.1.1", DownloadRate: 0, UploadRate: 36000, Progress: 12, Incoming: true},
+		{ID: "2D5554333630302D", Address: "203.0.113.199", Port: 23412, Client: "µTorrent 3.6.0", DownloadRate: 3000, UploadRate: 4000, Progress: 54, Snubbed: true},
+	}, nil
+}
+
+func (m *mockClient) DisconnectPeer(ctx context.Context, hash, peerID string) error {
+	log.Printf("Mock: Disconnecting peer %s of %s", peerID, hash)
+	return nil
+}
+
+func (m *mockClient) BanPeer(ctx context.Context, hash, peerID string) error {
+	log.Printf("Mock: Banning peer %s of %s", peerID, hash)
+	return nil
+}
+
+func (m *mockClient) SetPeerSnubbed(ctx context.Context, hash, peerID string, snubbed bool) error {
+	log.Printf("Mock: Setting snubbed of peer %s of %s to %v", peerID, hash, snubbed)
+	return nil
+}
+
+func (m *mockClient) AddPeer(ctx context.Context, hash, address string) error {
+	if err := validatePeerAddress(address); err != nil {
+		return err
+	}
+	log.Printf("Mock: Adding peer %s to %s", address, hash)
+	return nil
+}
+
+func (m *mockClient) LoadIPFilter(ctx context.Context, path string) error {
+	log.Printf("Mock: Loading IP filter from %s", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &FaultError{Code: -503, Message: err.Error()}
+	}
+	return m.AddIPFilterBlocks(ctx, strings.Fields(string(data)))
+}
+
+func (m *mockClient) AddIPFilterBlocks(ctx context.Context, blocks []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, block := range blocks {
+		m.ipFilter[block] = true
+	}
+	return nil
+}
+
+func (m *mockClient) IPFilterSize(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.ipFilter)), nil
+}
+
+func (m *mockClient) GetTrackers(ctx context.Context, hash string) ([]Tracker, error) {
+	now := time.Now().Unix()
+	return []Tracker{
+		{Index: 0, URL: "udp://tracker.opentrackr.org:1337/announce", Type: "udp", Enabled: true, Seeders: 412, Leechers: 37, LastAnnounce: now - 840, NextAnnounce: now + 960},
+		{Index: 1, URL: "https://tracker.example.org/announce", Type: "http", Enabled: true, Seeders: 0, Leechers: 0, LastAnnounce: now - 300, NextAnnounce: now + 1500, LastError: "Tracker: [Failure reason \"unregistered torrent\"]"},
+		{Index: 2, URL: "dht://", Type: "dht", Enabled: false},
+	}, nil
+}
+
+func (m *mockClient) SetTrackerEnabled(ctx context.Context, hash string, index int, enabled bool) error {
+	log.Printf("Mock: Setting tracker %d of %s enabled=%v", index, hash, enabled)
+	return nil
+}
+
+func (m *mockClient) AddTracker(ctx context.Context, hash string, url string) error {
+	log.Printf("Mock: Adding tracker %s to %s", url, hash)
+	return nil
+}
+
+func (m *mockClient) ReannounceTorrent(ctx context.Context, hash string) error {
+	log.Printf("Mock: Reannouncing torrent %s", hash)
+	return nil
+}
+
 func (m *mockClient) DeleteTorrent(ctx context.Context, hash string) error {
 	return nil
 }
 
-func (m *mockClient) AddTorrentByUrl(ctx context.Context, url string, autoStart bool, downloadPath string) error {
-	log.Printf("Mock: Adding torrent from URL: %s (autoStart: %v, path: %s)", url, autoStart, downloadPath)
+func (m *mockClient) DeleteTorrentWithData(ctx context.Context, hash string) error {
+	log.Printf("Mock: Deleting torrent %s with data", hash)
+	return nil
+}
+
+func (m *mockClient) MoveStorage(ctx context.Context, hash, newPath string, moveFiles bool) error {
+	log.Printf("Mock: Moving torrent %s to %s (move files: %v)", hash, newPath, moveFiles)
+	if !moveFiles {
+		return nil
+	}
+	progress := moveProgress(ctx)
+	const total = 100
+	for done := int64(0); done <= total; done += 10 {
+		progress(done, total)
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil
+}
+
+func (m *mockClient) AddTorrentByUrl(ctx context.Context, url string, opts AddOptions) error {
+	if magnet.IsMagnet(url) {
+		if _, err := magnet.Parse(url); err != nil {
+			return err
+		}
+	} else if opts.SkipHashCheck {
+		return fmt.Errorf("%w: torrents added by URL are always checked", ErrSkipHashCheck)
+	}
+	log.Printf("Mock: Adding torrent from URL: %s (options: %+v)", url, opts)
+	m.recordAdd(opts)
+	return nil
+}
+
+func (m *mockClient) AddTorrentByData(ctx context.Context, data []byte, opts AddOptions) error {
+	log.Printf("Mock: Adding torrent from data: %d bytes (options: %+v)", len(data), opts)
+	m.recordAdd(opts)
 	return nil
 }
 
-func (m *mockClient) AddTorrentByData(ctx context.Context, data []byte, autoStart bool, downloadPath string) error {
-	log.Printf("Mock: Adding torrent from data: %d bytes (autoStart: %v, path: %s)", len(data), autoStart, downloadPath)
+func (m *mockClient) ApplyAddOptions(ctx context.Context, hash string, opts AddOptions) error {
+	log.Printf("Mock: Applying add options to %s: %+v", hash, opts)
 	return nil
 }
 
+func (m *mockClient) recordAdd(opts AddOptions) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.added = append(m.added, opts)
+}
+
 func (m *mockClient) StartTorrent(ctx context.Context, hash string) error {
 	log.Printf("Mock: Starting torrent %s", hash)
 	return nil
@@ -254,6 +408,83 @@ func (m *mockClient) SetLabel(ctx context.Context, hash string, label string) er
 	return nil
 }
 
+func (m *mockClient) GetGlobalThrottle(ctx context.Context) (*Throttle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := m.throttle
+	return &t, nil
+}
+
+func (m *mockClient) SetGlobalThrottle(ctx context.Context, t Throttle) error {
+	log.Printf("Mock: Setting global throttle to down=%d up=%d", t.DownloadRate, t.UploadRate)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttle = t
+	return nil
+}
+
+func (m *mockClient) GetMaxPeers(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.maxPeers, nil
+}
+
+func (m *mockClient) SetMaxPeers(ctx context.Context, n int64) error {
+	log.Printf("Mock: Setting max peers to %d", n)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxPeers = n
+	return nil
+}
+
+func (m *mockClient) SetThrottleGroup(ctx context.Context, name string, downloadRate, uploadRate int64) error {
+	log.Printf("Mock: Setting throttle group %s to down=%d up=%d", name, downloadRate, uploadRate)
+	return nil
+}
+
+func (m *mockClient) SetTorrentThrottle(ctx context.Context, hash, name string) error {
+	log.Printf("Mock: Setting throttle group of %s to %q", hash, name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttleNames[hash] = name
+	return nil
+}
+
+// mockCapabilities pretends to be a build with every optional command
+var mockCapabilities = Capabilities{FirstLastPiece: true, Sequential: true}
+
+func (m *mockClient) Capabilities(ctx context.Context) (Capabilities, error) {
+	return mockCapabilities, nil
+}
+
+func (m *mockClient) GetPieceModes(ctx context.Context, hash string) (*PieceModes, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	modes := m.pieceModes[hash]
+	modes.Capabilities = mockCapabilities
+	return &modes, nil
+}
+
+func (m *mockClient) SetFirstLastPiece(ctx context.Context, hash string, enabled bool) error {
+	log.Printf("Mock: Setting first/last piece priority of %s to %v", hash, enabled)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	modes := m.pieceModes[hash]
+	modes.FirstLastPiece = enabled
+	m.pieceModes[hash] = modes
+	return nil
+}
+
+func (m *mockClient) SetSequential(ctx context.Context, hash string, enabled bool) error {
+	log.Printf("Mock: Setting sequential download of %s to %v", hash, enabled)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	modes := m.pieceModes[hash]
+	modes.Sequential = enabled
+	m.pieceModes[hash] = modes
+	return nil
+}
+
 func (c *xmlrpcClient) call(ctx context.Context, method string, args ...Value) (*MethodResponse, error) {
 	call := MethodCall{
 		MethodName: method,
@@ -267,12 +498,11 @@ func (c *xmlrpcClient) call(ctx context.Context, method string, args ...Value) (
 		return nil, err
 	}
 
-	// SCGI Header construction
-	contentLength := len(body)
-	headers := fmt.Sprintf("CONTENT_LENGTH\x00%d\x00SCGI\x001\x00", contentLength)
-	headerLength := len(headers)
-	scgiRequest := []byte(fmt.Sprintf("%d:%s,", headerLength, headers))
-	scgiRequest = append(scgiRequest, body...)
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
 
 	// Determine network type and address
 	network := "tcp"
@@ -285,46 +515,35 @@ func (c *xmlrpcClient) call(ctx context.Context, method string, args ...Value) (
 	d := net.Dialer{Timeout: 5 * time.Second}
 	conn, err := d.DialContext(ctx, network, address)
 	if err != nil {
-		return nil, fmt.Errorf("dial error: %w", err)
+		return nil, transportError(ErrDial, err)
 	}
 	defer conn.Close()
 
-	if _, err := conn.Write(scgiRequest); err != nil {
-		return nil, fmt.Errorf("write error: %w", err)
+	if deadline, ok := ctx.Deadline(); ok {
+		// Without the deadline a wedged socket would outlive the timeout
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, transportError(ErrDial, err)
+		}
 	}
 
-	// Read response
-	// Note: In a production environment, you'd want a more robust reader that handles large responses
-	// and checks for the end of the XML-RPC message.
-	var respBuf bytes.Buffer
-	buf := make([]byte, 8192)
-	for {
-		n, err := conn.Read(buf)
-		if n > 0 {
-			respBuf.Write(buf[:n])
-		}
-		if err != nil {
-			break
-		}
-		if strings.Contains(respBuf.String(), "</methodResponse>") {
-			break
-		}
+	if _, err := conn.Write(encodeSCGIRequest(body)); err != nil {
+		return nil, transportError(ErrProtocol, err)
 	}
 
-	// Strip HTTP/SCGI response headers if present
-	fullResp := respBuf.Bytes()
-	xmlIdx := bytes.Index(fullResp, []byte("<?xml"))
-	if xmlIdx == -1 {
-		return nil, fmt.Errorf("invalid xml response: %s", string(fullResp))
+	// Parse the SCGI headers, then stream the body straight into the
+	// decoder so large multicall responses are handled in a single pass.
+	respBody, err := readSCGIResponse(bufio.NewReaderSize(conn, 64<<10), c.maxResponseSize)
+	if err != nil {
+		return nil, transportError(ErrProtocol, err)
 	}
 
 	var methodResp MethodResponse
-	if err := xml.Unmarshal(fullResp[xmlIdx:], &methodResp); err != nil {
-		return nil, fmt.Errorf("unmarshal error: %w", err)
+	if err := xml.NewDecoder(respBody).Decode(&methodResp); err != nil {
+		return nil, transportError(ErrProtocol, err)
 	}
 
 	if methodResp.Fault != nil {
-		return nil, fmt.Errorf("rpc fault: %v", methodResp.Fault.Value)
+		return nil, faultError(methodResp.Fault.Value)
 	}
 
 	return &methodResp, nil
@@ -342,6 +561,18 @@ func (c *xmlrpcClient) TestConnection() error {
 	return nil
 }
 
+// GetPID returns the process id of rTorrent, which changes when it restarts
+func (c *xmlrpcClient) GetPID(ctx context.Context) (int64, error) {
+	resp, err := c.call(ctx, "system.pid")
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Params) == 0 {
+		return 0, fmt.Errorf("%w: empty system.pid response", ErrProtocol)
+	}
+	return resp.Params[0].Value.GetLong(), nil
+}
+
 func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 	resp, err := c.call(ctx, "d.multicall2",
 		Value{String: stringPtr("")},
@@ -359,6 +590,11 @@ func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 		Value{String: stringPtr("d.chunk_size=")},
 		Value{String: stringPtr("d.directory=")},
 		Value{String: stringPtr("d.priority=")},
+		Value{String: stringPtr("d.throttle_name=")},
+		Value{String: stringPtr("d.is_meta=")},
+		Value{String: stringPtr("d.custom=" + customMagnetName)},
+		Value{String: stringPtr("d.custom=" + customMagnetSize)},
+		Value{String: stringPtr("d.custom=" + customDisplayName)},
 	)
 
 	if err != nil {
@@ -366,7 +602,7 @@ func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 	}
 
 	if len(resp.Params) == 0 {
-		return nil, fmt.Errorf("empty response")
+		return nil, fmt.Errorf("%w: empty response", ErrProtocol)
 	}
 
 	rows := resp.Params[0].Value.GetArray()
@@ -374,7 +610,7 @@ func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 
 	for _, rowValue := range rows {
 		row := rowValue.GetArray()
-		if len(row) < 13 {
+		if len(row) < 18 {
 			continue
 		}
 
@@ -385,13 +621,18 @@ func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 			Completed:    row[3].GetLong(),
 			DownloadRate: int(row[4].GetLong()),
 			UploadRate:   int(row[5].GetLong()),
-			State:        c.mapState(row[6].GetLong(), row[2].GetLong(), row[3].GetLong()),
+			State:        c.mapState(row[6].GetLong(), row[14].GetLong(), row[2].GetLong(), row[3].GetLong()),
 			Label:        row[7].GetString(),
 			DateAdded:    row[8].GetLong(),
 			PieceCount:   row[9].GetLong(),
 			PieceSize:    row[10].GetLong(),
 			SavePath:     row[11].GetString(),
 			Priority:     int(row[12].GetLong()),
+			ThrottleName: row[13].GetString(),
+		}
+		t.applyMagnetInfo(row[14].GetLong(), row[15].GetString(), row[16].GetString())
+		if name := row[17].GetString(); name != "" {
+			t.Name = name
 		}
 
 		if t.Size > 0 {
@@ -405,44 +646,62 @@ func (c *xmlrpcClient) GetTorrents(ctx context.Context) ([]Torrent, error) {
 }
 
 func (c *xmlrpcClient) GetTorrentDetails(ctx context.Context, hash string) (*Torrent, error) {
-	// For a single torrent, we use direct d.* methods instead of multicall
-	// Call d.hash to verify the torrent exists
-	hashResp, err := c.call(ctx, "d.hash", Value{String: stringPtr(hash)})
+	// Fetch every getter in one system.multicall round trip. d.hash comes
+	// first so the first fault returned tells us the torrent doesn't exist.
+	items := torrentGetters(hash,
+		"d.hash",
+		"d.name",
+		"d.size_bytes",
+		"d.completed_bytes",
+		"d.down.rate",
+		"d.up.rate",
+		"d.state",
+		"d.custom1",
+		"d.creation_date",
+		"d.size_chunks",
+		"d.chunk_size",
+		"d.directory",
+		"d.priority",
+		"d.throttle_name",
+		"d.is_meta",
+	)
+	items = append(items,
+		multicallItem{Method: "d.custom", Args: []Value{{String: stringPtr(hash)}, {String: stringPtr(customMagnetName)}}},
+		multicallItem{Method: "d.custom", Args: []Value{{String: stringPtr(hash)}, {String: stringPtr(customMagnetSize)}}},
+		multicallItem{Method: "d.custom", Args: []Value{{String: stringPtr(hash)}, {String: stringPtr(customDisplayName)}}},
+	)
+	results, err := c.multicall(ctx, items)
 	if err != nil {
-		return nil, fmt.Errorf("torrent not found: %w", err)
-	}
-	if len(hashResp.Params) == 0 {
-		return nil, fmt.Errorf("torrent not found")
-	}
-
-	// Now fetch all the details we need
-	name, _ := c.call(ctx, "d.name", Value{String: stringPtr(hash)})
-	size, _ := c.call(ctx, "d.size_bytes", Value{String: stringPtr(hash)})
-	completed, _ := c.call(ctx, "d.completed_bytes", Value{String: stringPtr(hash)})
-	downRate, _ := c.call(ctx, "d.down.rate", Value{String: stringPtr(hash)})
-	upRate, _ := c.call(ctx, "d.up.rate", Value{String: stringPtr(hash)})
-	state, _ := c.call(ctx, "d.state", Value{String: stringPtr(hash)})
-	label, _ := c.call(ctx, "d.custom1", Value{String: stringPtr(hash)})
-	dateAdded, _ := c.call(ctx, "d.creation_date", Value{String: stringPtr(hash)})
-	pieceCount, _ := c.call(ctx, "d.size_chunks", Value{String: stringPtr(hash)})
-	pieceSize, _ := c.call(ctx, "d.chunk_size", Value{String: stringPtr(hash)})
-	savePath, _ := c.call(ctx, "d.directory", Value{String: stringPtr(hash)})
-	priority, _ := c.call(ctx, "d.priority", Value{String: stringPtr(hash)})
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+
+	size := results[2].Value.GetLong()
+	completed := results[3].Value.GetLong()
 
 	t := &Torrent{
 		Hash:         hash,
-		Name:         extractString(name),
-		Size:         extractLong(size),
-		Completed:    extractLong(completed),
-		DownloadRate: int(extractLong(downRate)),
-		UploadRate:   int(extractLong(upRate)),
-		State:        c.mapState(extractLong(state), extractLong(size), extractLong(completed)),
-		Label:        extractString(label),
-		DateAdded:    extractLong(dateAdded),
-		PieceCount:   extractLong(pieceCount),
-		PieceSize:    extractLong(pieceSize),
-		SavePath:     extractString(savePath),
-		Priority:     int(extractLong(priority)),
+		Name:         results[1].Value.GetString(),
+		Size:         size,
+		Completed:    completed,
+		DownloadRate: int(results[4].Value.GetLong()),
+		UploadRate:   int(results[5].Value.GetLong()),
+		State:        c.mapState(results[6].Value.GetLong(), results[14].Value.GetLong(), size, completed),
+		Label:        results[7].Value.GetString(),
+		DateAdded:    results[8].Value.GetLong(),
+		PieceCount:   results[9].Value.GetLong(),
+		PieceSize:    results[10].Value.GetLong(),
+		SavePath:     results[11].Value.GetString(),
+		Priority:     int(results[12].Value.GetLong()),
+		ThrottleName: results[13].Value.GetString(),
+	}
+	t.applyMagnetInfo(results[14].Value.GetLong(), results[15].Value.GetString(), results[16].Value.GetString())
+	if name := results[17].Value.GetString(); name != "" {
+		t.Name = name
 	}
 
 	if t.Size > 0 {
@@ -452,20 +711,6 @@ func (c *xmlrpcClient) GetTorrentDetails(ctx context.Context, hash string) (*Tor
 	return t, nil
 }
 
-func extractString(resp *MethodResponse) string {
-	if resp == nil || len(resp.Params) == 0 {
-		return ""
-	}
-	return resp.Params[0].Value.GetString()
-}
-
-func extractLong(resp *MethodResponse) int64 {
-	if resp == nil || len(resp.Params) == 0 {
-		return 0
-	}
-	return resp.Params[0].Value.GetLong()
-}
-
 func (c *xmlrpcClient) GetTorrentFiles(ctx context.Context, hash string) ([]File, error) {
 	// f.multicall request: hash, "", "f.path=", "f.size_bytes=", "f.completed_bytes=", "f.priority="
 	resp, err := c.call(ctx, "f.multicall",
@@ -482,76 +727,235 @@ func (c *xmlrpcClient) GetTorrentFiles(ctx context.Context, hash string) ([]File
 	}
 
 	if len(resp.Params) == 0 {
-		return nil, fmt.Errorf("empty response")
+		return nil, fmt.Errorf("%w: empty response", ErrProtocol)
 	}
 
 	rows := resp.Params[0].Value.GetArray()
 	files := make([]File, 0, len(rows))
 
-	for _, rowValue := range rows {
+	for i, rowValue := range rows {
 		row := rowValue.GetArray()
 		if len(row) < 4 {
 			continue
 		}
 
 		files = append(files, File{
+			Index:     i,
 			Name:      row[0].GetString(),
-			Size:      row[2].GetLong(),
-			Completed: row[3].GetLong(),
-			Priority:  int(row[4].GetLong()),
+			Size:      row[1].GetLong(),
+			Completed: row[2].GetLong(),
+			Priority:  int(row[3].GetLong()),
 		})
 	}
 
 	return files, nil
 }
 
-func (c *xmlrpcClient) DeleteTorrent(ctx context.Context, hash string) error {
-	_, err := c.call(ctx, "d.erase", Value{String: stringPtr(hash)})
-	return err
+func (c *xmlrpcClient) SetFilePriority(ctx context.Context, hash string, indices []int, priority int) error {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	// Set every file and then let rTorrent recompute chunk priorities,
+	// all in a single round trip.
+	items := make([]multicallItem, 0, len(indices)+1)
+	for _, index := range indices {
+		items = append(items, multicallItem{Method: "f.priority.set", Args: []Value{
+			{String: stringPtr(fmt.Sprintf("%s:f%d", hash, index))},
+			{Int: intPtr(int64(priority))},
+		}})
+	}
+	items = append(items, multicallItem{Method: "d.update_priorities", Args: []Value{{String: stringPtr(hash)}}})
+
+	results, err := c.multicall(ctx, items)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
 }
 
-func (c *xmlrpcClient) AddTorrentByUrl(ctx context.Context, url string, autoStart bool, downloadPath string) error {
-	method := "load.normal"
-	if autoStart {
-		method = "load.start"
+func (c *xmlrpcClient) GetPeers(ctx context.Context, hash string) ([]Peer, error) {
+	resp, err := c.call(ctx, "p.multicall",
+		Value{String: stringPtr(hash)},
+		Value{String: stringPtr("")},
+		Value{String: stringPtr("p.id=")},
+		Value{String: stringPtr("p.address=")},
+		Value{String: stringPtr("p.port=")},
+		Value{String: stringPtr("p.client_version=")},
+		Value{String: stringPtr("p.down_rate=")},
+		Value{String: stringPtr("p.up_rate=")},
+		Value{String: stringPtr("p.completed_percent=")},
+		Value{String: stringPtr("p.is_encrypted=")},
+		Value{String: stringPtr("p.is_incoming=")},
+		Value{String: stringPtr("p.is_snubbed=")},
+	)
+
+	if err != nil {
+		return nil, err
 	}
 
-	// Build args
-	args := []Value{
-		{String: stringPtr("")}, // Target (empty for default)
-		{String: stringPtr(url)},
+	if len(resp.Params) == 0 {
+		return nil, fmt.Errorf("%w: empty response", ErrProtocol)
 	}
 
-	// Add directory command if specified
-	if downloadPath != "" {
-		args = append(args, Value{String: stringPtr(fmt.Sprintf("d.directory_base.set=\"%s\"", downloadPath))})
+	rows := resp.Params[0].Value.GetArray()
+	peers := make([]Peer, 0, len(rows))
+
+	for _, rowValue := range rows {
+		row := rowValue.GetArray()
+		if len(row) < 10 {
+			continue
+		}
+
+		peers = append(peers, Peer{
+			ID:           row[0].GetString(),
+			Address:      row[1].GetString(),
+			Port:         int(row[2].GetLong()),
+			Client:       row[3].GetString(),
+			DownloadRate: int(row[4].GetLong()),
+			UploadRate:   int(row[5].GetLong()),
+			Progress:     float64(row[6].GetLong()),
+			Encrypted:    row[7].GetLong() != 0,
+			Incoming:     row[8].GetLong() != 0,
+			Snubbed:      row[9].GetLong() != 0,
+		})
 	}
 
-	_, err := c.call(ctx, method, args...)
+	return peers, nil
+}
+
+func (c *xmlrpcClient) GetTrackers(ctx context.Context, hash string) ([]Tracker, error) {
+	// rTorrent keeps no per-tracker error text, only the torrent's latest
+	// d.message, so fetch it alongside the tracker rows in one batch.
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "t.multicall", Args: []Value{
+			{String: stringPtr(hash)},
+			{String: stringPtr("")},
+			{String: stringPtr("t.url=")},
+			{String: stringPtr("t.type=")},
+			{String: stringPtr("t.is_enabled=")},
+			{String: stringPtr("t.scrape_complete=")},
+			{String: stringPtr("t.scrape_incomplete=")},
+			{String: stringPtr("t.activity_time_last=")},
+			{String: stringPtr("t.activity_time_next=")},
+			{String: stringPtr("t.success_time_last=")},
+			{String: stringPtr("t.failed_time_last=")},
+		}},
+		{Method: "d.message", Args: []Value{{String: stringPtr(hash)}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+
+	message := results[1].Value.GetString()
+	rows := results[0].Value.GetArray()
+	trackers := make([]Tracker, 0, len(rows))
+
+	for i, rowValue := range rows {
+		row := rowValue.GetArray()
+		if len(row) < 9 {
+			continue
+		}
+
+		t := Tracker{
+			Index:        i,
+			URL:          row[0].GetString(),
+			Type:         trackerType(row[1].GetLong()),
+			Enabled:      row[2].GetLong() != 0,
+			Seeders:      int(row[3].GetLong()),
+			Leechers:     int(row[4].GetLong()),
+			LastAnnounce: row[5].GetLong(),
+			NextAnnounce: row[6].GetLong(),
+		}
+
+		// Attribute the message to trackers whose latest attempt failed
+		successLast, failedLast := row[7].GetLong(), row[8].GetLong()
+		if failedLast > 0 && failedLast >= successLast {
+			t.LastError = message
+		}
+
+		trackers = append(trackers, t)
+	}
+
+	return trackers, nil
+}
+
+func (c *xmlrpcClient) SetTrackerEnabled(ctx context.Context, hash string, index int, enabled bool) error {
+	method := "t.disable"
+	if enabled {
+		method = "t.enable"
+	}
+	_, err := c.call(ctx, method, Value{String: stringPtr(fmt.Sprintf("%s:t%d", hash, index))})
 	return err
 }
 
-func (c *xmlrpcClient) AddTorrentByData(ctx context.Context, data []byte, autoStart bool, downloadPath string) error {
-	method := "load.raw"
-	if autoStart {
-		method = "load.raw_start"
+func (c *xmlrpcClient) AddTracker(ctx context.Context, hash string, url string) error {
+	// Put the new tracker in its own tier after the existing ones
+	resp, err := c.call(ctx, "d.tracker_size", Value{String: stringPtr(hash)})
+	if err != nil {
+		return err
 	}
+	if len(resp.Params) == 0 {
+		return fmt.Errorf("%w: empty response", ErrProtocol)
+	}
+	group := resp.Params[0].Value.GetLong()
 
-	// Build args
-	args := []Value{
-		{String: stringPtr("")}, // Target (empty for default)
-		{Base64: data},          // Torrent file data as base64
+	_, err = c.call(ctx, "d.tracker.insert",
+		Value{String: stringPtr(hash)},
+		Value{Int: intPtr(group)},
+		Value{String: stringPtr(url)},
+	)
+	return err
+}
+
+func (c *xmlrpcClient) ReannounceTorrent(ctx context.Context, hash string) error {
+	_, err := c.call(ctx, "d.tracker_announce", Value{String: stringPtr(hash)})
+	return err
+}
+
+func (c *xmlrpcClient) DeleteTorrent(ctx context.Context, hash string) error {
+	_, err := c.call(ctx, "d.erase", Value{String: stringPtr(hash)})
+	return err
+}
+
+func (c *xmlrpcClient) AddTorrentByUrl(ctx context.Context, url string, opts AddOptions) error {
+	if magnet.IsMagnet(url) {
+		return c.addMagnet(ctx, url, opts)
+	}
+	// rTorrent downloads the file itself, so neither the files nor the
+	// hash are known here
+	if opts.SkipHashCheck {
+		return fmt.Errorf("%w: torrents added by URL are always checked", ErrSkipHashCheck)
 	}
 
-	// Add directory command if specified
-	if downloadPath != "" {
-		args = append(args, Value{String: stringPtr(fmt.Sprintf("d.directory_base.set=\"%s\"", downloadPath))})
+	method := "load.normal"
+	if opts.AutoStart {
+		method = "load.start"
 	}
 
+	args := append([]Value{
+		{String: stringPtr("")}, // Target (empty for default)
+		{String: stringPtr(url)},
+	}, opts.loadCommands()...)
+
 	_, err := c.call(ctx, method, args...)
 	return err
 }
 
+func (c *xmlrpcClient) AddTorrentByData(ctx context.Context, data []byte, opts AddOptions) error {
+	return c.addData(ctx, data, opts)
+}
+
 func (c *xmlrpcClient) StartTorrent(ctx context.Context, hash string) error {
 	_, err := c.call(ctx, "d.start", Value{String: stringPtr(hash)})
 	return err
@@ -582,15 +986,31 @@ func (c *xmlrpcClient) SetLabel(ctx context.Context, hash string, label string)
 	return err
 }
 
-func (c *xmlrpcClient) mapState(isActive, size, completed int64) string {
+func (c *xmlrpcClient) mapState(isActive, isMeta, size, completed int64) string {
 	if isActive == 0 {
 		return "paused"
 	}
+	if isMeta != 0 {
+		return "metadata"
+	}
 	if completed < size {
 		return "downloading"
 	}
 	return "seeding"
 }
 
+func trackerType(t int64) string {
+	switch t {
+	case 1:
+		return "http"
+	case 2:
+		return "udp"
+	case 3:
+		return "dht"
+	default:
+		return "unknown"
+	}
+}
+
 func stringPtr(s string) *string { return &s }
 func intPtr(i int64) *int64      { return &i }
@@ -0,0 +1,315 @@
+package rtorrent
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Decode stores v into the value pointed to by out.
+//
+// Structs are filled from XML-RPC <struct> members. A field is matched by
+// its `xmlrpc:"name"` tag, or by its Go name when no tag is present; a tag
+// of "-" skips the field. Fields of embedded structs are promoted as in
+// encoding/json unless the embedded field is tagged. Arrays decode into
+// slices, and integers, doubles, booleans and strings convert between
+// each other where it makes sense, since rTorrent is not always
+// consistent about which type it returns.
+// Decoding into an interface{} yields plain Go values (string, int64,
+// float64, bool, []byte, time.Time, []interface{}, map[string]interface{}).
+func (v Value) Decode(out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("decode: non-nil pointer required, got %T", out)
+	}
+	return decodeValue(v, rv.Elem())
+}
+
+func decodeValue(v Value, rv reflect.Value) error {
+	if v.Nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(v, rv.Elem())
+	}
+
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		rv.Set(reflect.ValueOf(v.Interface()))
+		return nil
+	}
+
+	if rv.Type() == timeType {
+		if v.DateTime == nil {
+			return typeError(v, rv)
+		}
+		rv.Set(reflect.ValueOf(*v.DateTime))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.String:
+		switch {
+		case v.String != nil:
+			rv.SetString(*v.String)
+		case v.isInteger():
+			rv.SetString(strconv.FormatInt(v.GetLong(), 10))
+		default:
+			return typeError(v, rv)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.integer()
+		if !ok {
+			return typeError(v, rv)
+		}
+		if rv.OverflowInt(n) {
+			return fmt.Errorf("decode: %d overflows %s", n, rv.Type())
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.integer()
+		if !ok || n < 0 {
+			return typeError(v, rv)
+		}
+		if rv.OverflowUint(uint64(n)) {
+			return fmt.Errorf("decode: %d overflows %s", n, rv.Type())
+		}
+		rv.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case v.Double != nil:
+			rv.SetFloat(*v.Double)
+		case v.isInteger():
+			rv.SetFloat(float64(v.GetLong()))
+		default:
+			return typeError(v, rv)
+		}
+	case reflect.Bool:
+		switch {
+		case v.Boolean != nil:
+			rv.SetBool(*v.Boolean)
+		case v.isInteger():
+			rv.SetBool(v.GetLong() != 0)
+		default:
+			return typeError(v, rv)
+		}
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && v.Array == nil {
+			switch {
+			case v.Base64 != nil:
+				rv.SetBytes(append([]byte(nil), v.Base64...))
+			case v.String != nil:
+				rv.SetBytes([]byte(*v.String))
+			default:
+				return typeError(v, rv)
+			}
+			return nil
+		}
+		if v.Array == nil {
+			return typeError(v, rv)
+		}
+		items := v.Array.Data
+		slice := reflect.MakeSlice(rv.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i)); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		rv.Set(slice)
+	case reflect.Map:
+		if v.Struct == nil || rv.Type().Key().Kind() != reflect.String {
+			return typeError(v, rv)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), len(v.Struct.Members))
+		for _, member := range v.Struct.Members {
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			if err := decodeValue(member.Value, elem); err != nil {
+				return fmt.Errorf("%s: %w", member.Name, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(member.Name).Convert(rv.Type().Key()), elem)
+		}
+		rv.Set(m)
+	case reflect.Struct:
+		if v.Struct == nil {
+			return typeError(v, rv)
+		}
+		return decodeStruct(v.Struct, rv)
+	default:
+		return fmt.Errorf("decode: unsupported target type %s", rv.Type())
+	}
+	return nil
+}
+
+func decodeStruct(s *ValStruct, rv reflect.Value) error {
+	fields := make(map[string]*structField)
+	addStructFields(rv.Type(), nil, fields, make(map[reflect.Type]bool))
+
+	for _, member := range s.Members {
+		f, ok := fields[member.Name]
+		if !ok || f.ambiguous {
+			continue
+		}
+		if err := decodeValue(member.Value, fieldByIndex(rv, f.index)); err != nil {
+			return fmt.Errorf("%s: %w", member.Name, err)
+		}
+	}
+	return nil
+}
+
+// structField is where a struct member is stored, possibly inside an
+// embedded struct
+type structField struct {
+	index []int
+	// ambiguous is set when embedded structs at the same depth both have
+	// a field of this name; like encoding/json, neither is filled
+	ambiguous bool
+}
+
+// addStructFields maps member names to the fields of t. Fields of
+// embedded structs without a tag are promoted, with shallower fields
+// taking precedence over deeper ones. seen guards against types that
+// embed themselves through a pointer.
+func addStructFields(t reflect.Type, prefix []int, fields map[string]*structField, seen map[reflect.Type]bool) {
+	seen[t] = true
+	defer delete(seen, t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("xmlrpc"), ",")
+		if name == "-" {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				// A nil pointer to an unexported type can't be allocated
+				if !seen[ft] && (f.PkgPath == "" || f.Type.Kind() != reflect.Ptr) {
+					addStructFields(ft, index, fields, seen)
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		switch existing := fields[name]; {
+		case existing == nil || len(index) < len(existing.index):
+			fields[name] = &structField{index: index}
+		case len(index) == len(existing.index):
+			existing.ambiguous = true
+		}
+	}
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex, allocating nil embedded
+// pointers on the way
+func fieldByIndex(rv reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv
+}
+
+// Interface converts v into plain Go values
+func (v Value) Interface() interface{} {
+	switch {
+	case v.String != nil:
+		return *v.String
+	case v.isInteger():
+		return v.GetLong()
+	case v.Double != nil:
+		return *v.Double
+	case v.Boolean != nil:
+		return *v.Boolean
+	case v.Base64 != nil:
+		return v.Base64
+	case v.DateTime != nil:
+		return *v.DateTime
+	case v.Array != nil:
+		out := make([]interface{}, len(v.Array.Data))
+		for i, item := range v.Array.Data {
+			out[i] = item.Interface()
+		}
+		return out
+	case v.Struct != nil:
+		out := make(map[string]interface{}, len(v.Struct.Members))
+		for _, member := range v.Struct.Members {
+			out[member.Name] = member.Value.Interface()
+		}
+		return out
+	}
+	if v.Nil {
+		return nil
+	}
+	return ""
+}
+
+func (v Value) isInteger() bool {
+	return v.Int != nil || v.I4 != nil || v.I8 != nil
+}
+
+// integer returns v as an int64, accepting numeric strings as well
+func (v Value) integer() (int64, bool) {
+	if v.isInteger() {
+		return v.GetLong(), true
+	}
+	if v.String != nil {
+		n, err := strconv.ParseInt(strings.TrimSpace(*v.String), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func typeError(v Value, rv reflect.Value) error {
+	return fmt.Errorf("decode: cannot store %s in %s", v.typeName(), rv.Type())
+}
+
+// typeName returns the XML-RPC element name for v's type
+func (v Value) typeName() string {
+	switch {
+	case v.String != nil:
+		return "string"
+	case v.Int != nil:
+		return "int"
+	case v.I4 != nil:
+		return "i4"
+	case v.I8 != nil:
+		return "i8"
+	case v.Double != nil:
+		return "double"
+	case v.Boolean != nil:
+		return "boolean"
+	case v.Base64 != nil:
+		return "base64"
+	case v.DateTime != nil:
+		return "dateTime.iso8601"
+	case v.Array != nil:
+		return "array"
+	case v.Struct != nil:
+		return "struct"
+	case v.Nil:
+		return "nil"
+	}
+	return "empty value"
+}
@@ -0,0 +1,173 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"rtorrent-go/internal/pathutil"
+)
+
+// DataRemovalError is returned by DeleteTorrentWithData when the torrent was
+// erased from rTorrent but some of its data could not be removed.
+type DataRemovalError struct {
+	Failed []FailedPath
+}
+
+// FailedPath is a file or directory that could not be removed
+type FailedPath struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e *DataRemovalError) Error() string {
+	paths := make([]string, len(e.Failed))
+	for i, f := range e.Failed {
+		paths[i] = f.Path
+	}
+	return fmt.Sprintf("torrent removed but %d path(s) could not be deleted: %s", len(e.Failed), strings.Join(paths, ", "))
+}
+
+// torrentData describes where a torrent keeps its files on disk
+type torrentData struct {
+	// Directory is the torrent's own folder for multi-file torrents, or
+	// the folder containing the file for single-file torrents.
+	Directory string
+	MultiFile bool
+	Files     []string
+}
+
+// resolveTorrentData fetches the directory and absolute file paths of a
+// torrent in a single round trip.
+func (c *xmlrpcClient) resolveTorrentData(ctx context.Context, hash string) (*torrentData, error) {
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "d.directory", Args: []Value{{String: stringPtr(hash)}}},
+		{Method: "d.is_multi_file", Args: []Value{{String: stringPtr(hash)}}},
+		{Method: "f.multicall", Args: []Value{
+			{String: stringPtr(hash)},
+			{String: stringPtr("")},
+			{String: stringPtr("f.path=")},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+
+	data := &torrentData{
+		Directory: results[0].Value.GetString(),
+		MultiFile: results[1].Value.GetLong() != 0,
+	}
+	if data.Directory == "" {
+		return nil, fmt.Errorf("%w: torrent has no directory", ErrProtocol)
+	}
+	data.Directory = filepath.Clean(data.Directory)
+
+	for _, row := range results[2].Value.GetArray() {
+		fields := row.GetArray()
+		if len(fields) == 0 {
+			continue
+		}
+		data.Files = append(data.Files, filepath.Join(data.Directory, fields[0].GetString()))
+	}
+
+	return data, nil
+}
+
+// checkRoots makes sure every path the torrent owns is inside a download
+// root. A multi-file torrent's own directory is checked too since it will
+// be removed once empty.
+func (d *torrentData) checkRoots(roots []string) error {
+	if len(roots) == 0 {
+		return fmt.Errorf("%w: no download roots configured", ErrPathNotAllowed)
+	}
+	if d.MultiFile && !pathutil.Inside(d.Directory, roots) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, d.Directory)
+	}
+	for _, path := range d.Files {
+		if !pathutil.Inside(path, roots) {
+			return fmt.Errorf("%w: %s", ErrPathNotAllowed, path)
+		}
+	}
+	return nil
+}
+
+func (c *xmlrpcClient) DeleteTorrentWithData(ctx context.Context, hash string) error {
+	// Paths have to be resolved before d.erase, afterwards rTorrent has
+	// forgotten everything about the torrent.
+	data, err := c.resolveTorrentData(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if err := data.checkRoots(c.roots); err != nil {
+		return err
+	}
+
+	if _, err := c.call(ctx, "d.erase", Value{String: stringPtr(hash)}); err != nil {
+		return err
+	}
+
+	return removeTorrentData(data)
+}
+
+// removeTorrentData deletes the files and then prunes directories left
+// empty, deepest first, without going above the torrent's directory.
+func removeTorrentData(data *torrentData) error {
+	var failed []FailedPath
+	dirs := make(map[string]bool)
+
+	for _, path := range data.Files {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			failed = append(failed, FailedPath{Path: path, Reason: err.Error()})
+		}
+		if !data.MultiFile {
+			continue
+		}
+		for dir := filepath.Dir(path); dir != data.Directory && strings.HasPrefix(dir, data.Directory); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	if data.MultiFile {
+		dirs[data.Directory] = true
+
+		ordered := make([]string, 0, len(dirs))
+		for dir := range dirs {
+			ordered = append(ordered, dir)
+		}
+		sort.Slice(ordered, func(i, j int) bool {
+			return len(ordered[i]) > len(ordered[j])
+		})
+
+		for _, dir := range ordered {
+			entries, err := os.ReadDir(dir)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				failed = append(failed, FailedPath{Path: dir, Reason: err.Error()})
+				continue
+			}
+			if len(entries) > 0 {
+				// Left-over files we don't own, leave the folder alone
+				continue
+			}
+			if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+				failed = append(failed, FailedPath{Path: dir, Reason: err.Error()})
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return &DataRemovalError{Failed: failed}
+	}
+	return nil
+}
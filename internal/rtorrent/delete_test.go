@@ -0,0 +1,132 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// deleteFixture fakes a torrent with files in dir and returns a client
+// allowed to modify root
+func deleteFixture(t *testing.T, root, dir string, multiFile bool, files ...string) (*fakeRTorrent, *xmlrpcClient) {
+	t.Helper()
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		switch method {
+		case "d.directory":
+			return Value{String: stringPtr(dir)}, nil
+		case "d.is_multi_file":
+			if multiFile {
+				return Value{Int: intPtr(1)}, nil
+			}
+		case "f.multicall":
+			var rows []Value
+			for _, path := range files {
+				rows = append(rows, Value{Array: &ValArray{Data: []Value{{String: stringPtr(path)}}}})
+			}
+			return Value{Array: &ValArray{Data: rows}}, nil
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+	if root != "" {
+		c.roots = []string{root}
+	}
+	return fake, c
+}
+
+func TestDeleteTorrentWithData(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Album")
+	writeTestFile(t, filepath.Join(dir, "a.bin"), 10)
+	writeTestFile(t, filepath.Join(dir, "disc 2", "b.bin"), 20)
+	writeTestFile(t, filepath.Join(root, "other.bin"), 5)
+
+	fake, c := deleteFixture(t, root, dir, true, "a.bin", "disc 2/b.bin")
+	if err := c.DeleteTorrentWithData(context.Background(), "ABC"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(fake.Methods(), "d.erase") {
+		t.Errorf("torrent not erased, calls: %v", fake.Methods())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("torrent directory still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "other.bin")); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestDeleteTorrentWithDataOutsideRoots(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "data")
+	outside := filepath.Join(base, "data2")
+	writeTestFile(t, filepath.Join(outside, "a.bin"), 10)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		root      string
+		dir       string
+		multiFile bool
+		files     []string
+	}{
+		{name: "multi-file directory outside", root: root, dir: outside, multiFile: true, files: []string{"a.bin"}},
+		{name: "single file outside", root: root, dir: outside, files: []string{"a.bin"}},
+		{name: "file escaping with dot dot", root: root, dir: root, multiFile: true, files: []string{"../data2/a.bin"}},
+		{name: "symlinked directory", root: root, dir: filepath.Join(root, "link"), multiFile: true, files: []string{"a.bin"}},
+		{name: "download root itself", root: root, dir: root, multiFile: true, files: []string{"x.bin"}},
+		{name: "no roots configured", dir: outside, multiFile: true, files: []string{"a.bin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, c := deleteFixture(t, tt.root, tt.dir, tt.multiFile, tt.files...)
+			err := c.DeleteTorrentWithData(context.Background(), "ABC")
+			if !errors.Is(err, ErrPathNotAllowed) {
+				t.Fatalf("err = %v, want ErrPathNotAllowed", err)
+			}
+			if slices.Contains(fake.Methods(), "d.erase") {
+				t.Error("torrent erased although its data is outside the roots")
+			}
+			if _, err := os.Stat(filepath.Join(outside, "a.bin")); err != nil {
+				t.Errorf("file outside the roots removed: %v", err)
+			}
+		})
+	}
+}
+
+func TestDeleteTorrentWithDataPartialFailure(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Album")
+	writeTestFile(t, filepath.Join(dir, "a.bin"), 10)
+	// A non-empty directory where the torrent expects a file can't be
+	// removed, even as root
+	stuck := filepath.Join(dir, "b.bin")
+	writeTestFile(t, filepath.Join(stuck, "keep"), 1)
+
+	fake, c := deleteFixture(t, root, dir, true, "a.bin", "b.bin", "missing.bin")
+	err := c.DeleteTorrentWithData(context.Background(), "ABC")
+	var removal *DataRemovalError
+	if !errors.As(err, &removal) {
+		t.Fatalf("err = %v, want a DataRemovalError", err)
+	}
+	if len(removal.Failed) != 1 || removal.Failed[0].Path != stuck || removal.Failed[0].Reason == "" {
+		t.Errorf("failed paths = %+v, want only %s", removal.Failed, stuck)
+	}
+	if !slices.Contains(fake.Methods(), "d.erase") {
+		t.Error("torrent not erased")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.bin")); !os.IsNotExist(err) {
+		t.Errorf("a.bin still there: %v", err)
+	}
+	// The directory still holds b.bin so it is left alone
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("torrent directory removed: %v", err)
+	}
+}
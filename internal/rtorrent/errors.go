@@ -0,0 +1,73 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Sentinel errors for transport-level failures. Errors returned by the
+// client wrap one of these so callers can use errors.Is.
+var (
+	// ErrDial means the rTorrent socket could not be reached
+	ErrDial = errors.New("rtorrent unreachable")
+	// ErrTimeout means the request did not complete in time
+	ErrTimeout = errors.New("rtorrent request timed out")
+	// ErrProtocol means rTorrent answered with something we couldn't parse
+	ErrProtocol = errors.New("invalid rtorrent response")
+	// ErrNotFound matches faults rTorrent raises for unknown info-hashes
+	ErrNotFound = errors.New("torrent not found")
+)
+
+// faultCodeNotFound is what rTorrent returns for "Could not find info-hash."
+const faultCodeNotFound = -501
+
+// FaultError is an XML-RPC fault returned by rTorrent
+type FaultError struct {
+	Code    int
+	Message string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("rpc fault %d: %s", e.Code, e.Message)
+}
+
+// Is reports unknown-hash faults as ErrNotFound
+func (e *FaultError) Is(target error) bool {
+	if target != ErrNotFound {
+		return false
+	}
+	return e.Code == faultCodeNotFound || strings.Contains(e.Message, "find info-hash")
+}
+
+// faultError decodes an XML-RPC fault struct into a *FaultError
+func faultError(v Value) error {
+	var fault struct {
+		Code    int    `xmlrpc:"faultCode"`
+		Message string `xmlrpc:"faultString"`
+	}
+	if err := v.Decode(&fault); err != nil {
+		return fmt.Errorf("%w: malformed fault: %w", ErrProtocol, err)
+	}
+	return &FaultError{Code: fault.Code, Message: fault.Message}
+}
+
+// transportError wraps err with kind, upgrading it to ErrTimeout when the
+// underlying failure was a deadline.
+func transportError(kind error, err error) error {
+	if isTimeout(err) {
+		kind = ErrTimeout
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
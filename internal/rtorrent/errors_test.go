@@ -0,0 +1,49 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCallFaultNotFound(t *testing.T) {
+	tests := []struct {
+		name         string
+		fault        FaultError
+		wantNotFound bool
+	}{
+		{name: "code", fault: FaultError{Code: -501, Message: "Unsupported target type found."}, wantNotFound: true},
+		{name: "message", fault: FaultError{Code: -1, Message: "Could not find info-hash."}, wantNotFound: true},
+		{name: "other fault", fault: FaultError{Code: -506, Message: "Method 'x' not defined"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c := newFakeRTorrent(t, func(string, []Value) (Value, *FaultError) {
+				fault := tt.fault
+				return Value{}, &fault
+			})
+			_, err := c.call(context.Background(), "d.name", Value{String: stringPtr("ABC")})
+			var fault *FaultError
+			if !errors.As(err, &fault) || *fault != tt.fault {
+				t.Fatalf("err = %v, want fault %v", err, tt.fault)
+			}
+			if got := errors.Is(err, ErrNotFound); got != tt.wantNotFound {
+				t.Errorf("errors.Is(err, ErrNotFound) = %v, want %v", got, tt.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestCallUnreachable(t *testing.T) {
+	c := &xmlrpcClient{addr: "127.0.0.1:1"}
+	if _, err := c.call(context.Background(), "system.pid"); !errors.Is(err, ErrDial) {
+		t.Errorf("err = %v, want ErrDial", err)
+	}
+}
+
+func TestCallMalformedResponse(t *testing.T) {
+	c := newFixedRTorrent(t, `<methodResponse><params><param><value><int>x</int></value></param></params></methodResponse>`)
+	if _, err := c.call(context.Background(), "system.pid"); !errors.Is(err, ErrProtocol) {
+		t.Errorf("err = %v, want ErrProtocol", err)
+	}
+}
@@ -0,0 +1,54 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSetFilePriority(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	if err := c.SetFilePriority(context.Background(), "ABC", []int{0, 4, 7}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"f.priority.set", "f.priority.set", "f.priority.set", "d.update_priorities"}
+	if got := fake.Methods(); !slices.Equal(got, want) {
+		t.Fatalf("calls = %v, want %v", got, want)
+	}
+	calls := fake.Calls()
+	for i, target := range []string{"ABC:f0", "ABC:f4", "ABC:f7"} {
+		args := calls[i].Args
+		if len(args) != 2 || args[0].GetString() != target || args[1].GetLong() != 0 {
+			t.Errorf("call %d args = %+v, want %s, 0", i, args, target)
+		}
+	}
+	if got := calls[3].Args[0].GetString(); got != "ABC" {
+		t.Errorf("d.update_priorities target = %q, want ABC", got)
+	}
+}
+
+func TestSetFilePriorityNoFiles(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	if err := c.SetFilePriority(context.Background(), "ABC", nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("calls = %v, want none", calls)
+	}
+}
+
+func TestSetFilePriorityFault(t *testing.T) {
+	_, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		if method == "f.priority.set" && args[0].GetString() == "ABC:f9" {
+			return Value{}, &FaultError{Code: -501, Message: "Could not find file."}
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+	err := c.SetFilePriority(context.Background(), "ABC", []int{1, 9}, 2)
+	var fault *FaultError
+	if !errors.As(err, &fault) || fault.Code != -501 {
+		t.Errorf("err = %v, want the fault for the missing file", err)
+	}
+}
@@ -0,0 +1,64 @@
+package rtorrent
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// ipFilterBlocked is the value rTorrent's IP filter uses to refuse
+	// peers
+	ipFilterBlocked = "unwanted"
+	// ipFilterBatch is how many addresses go into one multicall
+	ipFilterBatch = 1000
+)
+
+// LoadIPFilter makes rTorrent read a file of blocked CIDR blocks, one per
+// line. The path is opened by rTorrent, so it must exist on its machine.
+func (c *xmlrpcClient) LoadIPFilter(ctx context.Context, path string) error {
+	_, err := c.call(ctx, "ipv4_filter.load",
+		Value{String: stringPtr("")},
+		Value{String: stringPtr(path)},
+		Value{String: stringPtr(ipFilterBlocked)},
+	)
+	return err
+}
+
+// AddIPFilterBlocks blocks CIDR blocks one by one, in batches, for when
+// rTorrent can't read a file we wrote
+func (c *xmlrpcClient) AddIPFilterBlocks(ctx context.Context, blocks []string) error {
+	for len(blocks) > 0 {
+		n := min(len(blocks), ipFilterBatch)
+		items := make([]multicallItem, n)
+		for i, block := range blocks[:n] {
+			items[i] = multicallItem{Method: "ipv4_filter.add_address", Args: []Value{
+				{String: stringPtr("")},
+				{String: stringPtr(block)},
+				{String: stringPtr(ipFilterBlocked)},
+			}}
+		}
+		results, err := c.multicall(ctx, items)
+		if err != nil {
+			return err
+		}
+		for i, r := range results {
+			if r.Err != nil {
+				return fmt.Errorf("block %s: %w", blocks[i], r.Err)
+			}
+		}
+		blocks = blocks[n:]
+	}
+	return nil
+}
+
+// IPFilterSize returns how many entries rTorrent's IP filter holds
+func (c *xmlrpcClient) IPFilterSize(ctx context.Context) (int64, error) {
+	resp, err := c.call(ctx, "ipv4_filter.size_data", Value{String: stringPtr("")})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Params) == 0 {
+		return 0, fmt.Errorf("%w: empty ipv4_filter.size_data response", ErrProtocol)
+	}
+	return resp.Params[0].Value.GetLong(), nil
+}
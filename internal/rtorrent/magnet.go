@@ -0,0 +1,86 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"rtorrent-go/internal/magnet"
+)
+
+// Custom keys holding what the magnet link told us, so the list has a name
+// and size to show while rTorrent is still fetching the metadata.
+const (
+	customMagnetName = "vt_name"
+	customMagnetSize = "vt_size"
+)
+
+// addMagnet validates a magnet link and loads it. Magnets are always
+// started because rTorrent only fetches metadata for active downloads;
+// callers that asked for a stopped torrent stop it once it has resolved,
+// which is also when file priorities can be applied.
+func (c *xmlrpcClient) addMagnet(ctx context.Context, uri string, opts AddOptions) error {
+	link, err := magnet.Parse(uri)
+	if err != nil {
+		return err
+	}
+	if opts.SkipHashCheck {
+		return fmt.Errorf("%w: magnets have no data to check yet", ErrSkipHashCheck)
+	}
+
+	args := append([]Value{
+		{String: stringPtr("")},
+		{String: stringPtr(uri)},
+	}, opts.loadCommands()...)
+	if link.Name != "" {
+		args = append(args, Value{String: stringPtr(fmt.Sprintf("d.custom.set=%s,%s", customMagnetName, commandArg(link.Name)))})
+	}
+	if link.Length > 0 {
+		args = append(args, Value{String: stringPtr(fmt.Sprintf("d.custom.set=%s,%d", customMagnetSize, link.Length))})
+	}
+
+	log.Printf("Adding magnet %s (%q, %d trackers)", link.InfoHash, link.Name, len(link.Trackers))
+	_, err = c.call(ctx, "load.start", args...)
+	return err
+}
+
+// SetDirectory points a torrent at dir without touching any data, e.g. to
+// re-apply the download path chosen for a magnet once its metadata arrives.
+func (c *xmlrpcClient) SetDirectory(ctx context.Context, hash, dir string) error {
+	state, err := c.torrentRunState(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if err := c.callEach(ctx, hash, "d.stop", "d.close"); err != nil {
+		return err
+	}
+	if _, err := c.call(ctx, "d.directory_base.set", Value{String: stringPtr(hash)}, Value{String: stringPtr(dir)}); err != nil {
+		return errors.Join(err, c.restoreRunState(ctx, hash, state))
+	}
+	return c.restoreRunState(ctx, hash, state)
+}
+
+// applyMagnetInfo fills in the magnet's name and size for torrents that are
+// still fetching metadata, whose own name is just the info-hash.
+func (t *Torrent) applyMagnetInfo(isMeta int64, name, size string) {
+	if isMeta == 0 {
+		return
+	}
+	t.FetchingMetadata = true
+	if name != "" {
+		t.Name = name
+	}
+	if n, err := strconv.ParseInt(size, 10, 64); err == nil && t.Size == 0 {
+		t.Size = n
+	}
+}
+
+// commandArg quotes s for use inside an rTorrent command string
+func commandArg(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
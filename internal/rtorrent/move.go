@@ -0,0 +1,381 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"rtorrent-go/internal/pathutil"
+)
+
+// ErrDestinationExists is returned by MoveStorage when moving the files
+// would overwrite something already at the destination.
+var ErrDestinationExists = errors.New("destination already exists")
+
+// MoveProgressFunc receives the number of bytes copied so far. Moves that
+// are a plain rename report done == total right away.
+type MoveProgressFunc func(done, total int64)
+
+type moveProgressKey struct{}
+
+// WithMoveProgress attaches a progress callback for MoveStorage to ctx
+func WithMoveProgress(ctx context.Context, fn MoveProgressFunc) context.Context {
+	return context.WithValue(ctx, moveProgressKey{}, fn)
+}
+
+func moveProgress(ctx context.Context) MoveProgressFunc {
+	if fn, ok := ctx.Value(moveProgressKey{}).(MoveProgressFunc); ok && fn != nil {
+		return fn
+	}
+	return func(done, total int64) {}
+}
+
+// MoveStorage points a torrent at newPath. With moveFiles the data is moved
+// along, otherwise only rTorrent's directory is changed, which is what you
+// want when the files were already moved by hand. The torrent is stopped
+// for the duration and restarted afterwards if it was running.
+func (c *xmlrpcClient) MoveStorage(ctx context.Context, hash, newPath string, moveFiles bool) error {
+	if !filepath.IsAbs(newPath) {
+		return fmt.Errorf("%w: %s is not an absolute path", ErrPathNotAllowed, newPath)
+	}
+	newPath = filepath.Clean(newPath)
+	if len(c.roots) == 0 {
+		return fmt.Errorf("%w: no download roots configured", ErrPathNotAllowed)
+	}
+	if !pathutil.Under(newPath, c.roots) {
+		return fmt.Errorf("%w: %s", ErrPathNotAllowed, newPath)
+	}
+
+	data, err := c.resolveTorrentData(ctx, hash)
+	if err != nil {
+		return err
+	}
+	state, err := c.torrentRunState(ctx, hash)
+	if err != nil {
+		return err
+	}
+
+	// For multi-file torrents d.directory is the torrent's own folder,
+	// which moves into newPath under the same name.
+	target := newPath
+	if data.MultiFile {
+		target = filepath.Join(newPath, filepath.Base(data.Directory))
+	}
+	if target == data.Directory {
+		return nil
+	}
+
+	var dest *torrentData
+	var sizes []int64
+	if moveFiles {
+		if err := data.checkRoots(c.roots); err != nil {
+			return err
+		}
+		dest = data.relocated(target)
+		if err := dest.checkFree(); err != nil {
+			return err
+		}
+		if sizes, err = data.sizes(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("Moving torrent %s from %s to %s (move files: %v)", hash, data.Directory, target, moveFiles)
+
+	// rTorrent only accepts a new directory while the torrent is closed
+	if err := c.callEach(ctx, hash, "d.stop", "d.close"); err != nil {
+		return err
+	}
+
+	if moveFiles {
+		if err := moveTorrentData(ctx, data, dest, moveProgress(ctx)); err != nil {
+			return errors.Join(err, c.restoreRunState(ctx, hash, state))
+		}
+	}
+
+	if _, err := c.call(ctx, "d.directory_base.set", Value{String: stringPtr(hash)}, Value{String: stringPtr(target)}); err != nil {
+		if moveFiles {
+			err = errors.Join(err, moveBack(ctx, dest, data))
+		}
+		return errors.Join(err, c.restoreRunState(ctx, hash, state))
+	}
+	if err := c.restoreRunState(ctx, hash, state); err != nil {
+		return err
+	}
+
+	return c.verifyMove(ctx, hash, target, dest, sizes)
+}
+
+// moveBack returns moved data to where it came from once rTorrent refused
+// the new directory, so the torrent still finds its files
+func moveBack(ctx context.Context, moved, original *torrentData) error {
+	// Finish even if the request was cancelled, half-moved data is worse
+	ctx = context.WithoutCancel(ctx)
+	if err := moveTorrentData(ctx, moved, original, func(done, total int64) {}); err != nil {
+		return fmt.Errorf("could not move the files back, they are now in %s: %w", moved.Directory, err)
+	}
+	return nil
+}
+
+// runState remembers whether a torrent was started and/or paused
+type runState struct {
+	started bool
+	active  bool
+}
+
+func (c *xmlrpcClient) torrentRunState(ctx context.Context, hash string) (runState, error) {
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "d.state", Args: []Value{{String: stringPtr(hash)}}},
+		{Method: "d.is_active", Args: []Value{{String: stringPtr(hash)}}},
+	})
+	if err != nil {
+		return runState{}, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return runState{}, r.Err
+		}
+	}
+	return runState{
+		started: results[0].Value.GetLong() != 0,
+		active:  results[1].Value.GetLong() != 0,
+	}, nil
+}
+
+// restoreRunState starts the torrent again if it was started before the
+// move, pausing it right away if it had been paused.
+func (c *xmlrpcClient) restoreRunState(ctx context.Context, hash string, state runState) error {
+	if !state.started {
+		return nil
+	}
+	if err := c.callEach(ctx, hash, "d.start"); err != nil {
+		return fmt.Errorf("restart after move: %w", err)
+	}
+	if !state.active {
+		if err := c.callEach(ctx, hash, "d.pause"); err != nil {
+			return fmt.Errorf("pause after move: %w", err)
+		}
+	}
+	return nil
+}
+
+// callEach runs the given single-argument commands against hash in order
+func (c *xmlrpcClient) callEach(ctx context.Context, hash string, methods ...string) error {
+	for _, method := range methods {
+		if _, err := c.call(ctx, method, Value{String: stringPtr(hash)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyMove checks rTorrent took the new directory and, when files were
+// moved, that every file present before the move is where rTorrent now
+// expects it, with the size it had. sizes holds those sizes by file index,
+// -1 for files that didn't exist yet.
+func (c *xmlrpcClient) verifyMove(ctx context.Context, hash, target string, dest *torrentData, sizes []int64) error {
+	resp, err := c.call(ctx, "d.directory", Value{String: stringPtr(hash)})
+	if err != nil {
+		return err
+	}
+	if len(resp.Params) == 0 {
+		return fmt.Errorf("%w: empty d.directory response", ErrProtocol)
+	}
+	if got := filepath.Clean(resp.Params[0].Value.GetString()); got != target {
+		return fmt.Errorf("move verification failed: rtorrent reports %s, expected %s", got, target)
+	}
+	if dest == nil {
+		return nil
+	}
+	for i, path := range dest.Files {
+		if sizes[i] < 0 {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("move verification failed: %w", err)
+		}
+		if info.Size() != sizes[i] {
+			return fmt.Errorf("move verification failed: %s is %d bytes, expected %d", path, info.Size(), sizes[i])
+		}
+	}
+	return nil
+}
+
+// sizes returns the size of every file on disk, -1 for files that don't
+// exist, e.g. because they haven't been downloaded
+func (d *torrentData) sizes() ([]int64, error) {
+	sizes := make([]int64, len(d.Files))
+	for i, path := range d.Files {
+		info, err := os.Stat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			sizes[i] = -1
+		case err != nil:
+			return nil, err
+		default:
+			sizes[i] = info.Size()
+		}
+	}
+	return sizes, nil
+}
+
+// relocated returns the same torrent data rooted at a new directory
+func (d *torrentData) relocated(dir string) *torrentData {
+	moved := &torrentData{Directory: dir, MultiFile: d.MultiFile}
+	for _, path := range d.Files {
+		rel, err := filepath.Rel(d.Directory, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		moved.Files = append(moved.Files, filepath.Join(dir, rel))
+	}
+	return moved
+}
+
+// checkFree refuses destinations that would clobber existing data
+func (d *torrentData) checkFree() error {
+	paths := d.Files
+	if d.MultiFile {
+		paths = []string{d.Directory}
+	}
+	for _, path := range paths {
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("%w: %s", ErrDestinationExists, path)
+		}
+	}
+	return nil
+}
+
+// moveTorrentData renames the torrent's data into place, falling back to
+// copy-and-delete when source and destination are on different devices.
+func moveTorrentData(ctx context.Context, src, dst *torrentData, progress MoveProgressFunc) error {
+	if err := os.MkdirAll(filepath.Dir(dst.Directory), 0o755); err != nil {
+		return err
+	}
+	if !src.MultiFile {
+		if err := os.MkdirAll(dst.Directory, 0o755); err != nil {
+			return err
+		}
+	}
+
+	err := renameTorrentData(src, dst)
+	if err == nil {
+		progress(1, 1)
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	log.Printf("Moving %s across devices, copying data", src.Directory)
+	if err := copyTorrentData(ctx, src, dst, progress); err != nil {
+		// Leave the original intact and drop whatever was copied so far
+		if cleanupErr := removeTorrentData(dst); cleanupErr != nil {
+			log.Printf("Failed to clean up partial copy at %s: %v", dst.Directory, cleanupErr)
+		}
+		return err
+	}
+	if err := removeTorrentData(src); err != nil {
+		// The torrent is complete at its new home, so the move succeeded
+		log.Printf("Moved %s but could not remove the original: %v", src.Directory, err)
+	}
+	return nil
+}
+
+func renameTorrentData(src, dst *torrentData) error {
+	if src.MultiFile {
+		err := os.Rename(src.Directory, dst.Directory)
+		if errors.Is(err, os.ErrNotExist) {
+			// Nothing downloaded yet
+			return nil
+		}
+		return err
+	}
+	for i, path := range src.Files {
+		err := os.Rename(path, dst.Files[i])
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyTorrentData(ctx context.Context, src, dst *torrentData, progress MoveProgressFunc) error {
+	var total int64
+	for _, path := range src.Files {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+
+	var done int64
+	progress(done, total)
+	buf := make([]byte, 1<<20)
+	for i, path := range src.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := copyFile(path, dst.Files[i], buf, func(written int64) {
+			progress(done+written, total)
+		})
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", path, err)
+		}
+		done += n
+		progress(done, total)
+	}
+	return nil
+}
+
+// copyFile copies src to dst keeping its mode and modification time
+func copyFile(src, dst string, buf []byte, written func(int64)) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return 0, err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.CopyBuffer(out, &progressReader{r: in, fn: written}, buf)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, os.Chtimes(dst, time.Now(), info.ModTime())
+}
+
+type progressReader struct {
+	r  io.Reader
+	n  int64
+	fn func(int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	p.fn(p.n)
+	return n, err
+}
@@ -0,0 +1,97 @@
+package rtorrent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// moveFixture fakes a stopped multi-file torrent in root/src/Album, two
+// of whose three files have been downloaded
+func moveFixture(t *testing.T, setDirectory func(dir string) *FaultError) (root string, c *xmlrpcClient) {
+	t.Helper()
+	root = t.TempDir()
+	dir := filepath.Join(root, "src", "Album")
+	writeTestFile(t, filepath.Join(dir, "a.bin"), 10)
+	writeTestFile(t, filepath.Join(dir, "disc 2", "b.bin"), 20)
+
+	var mu sync.Mutex
+	_, c = newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch method {
+		case "d.directory":
+			return Value{String: stringPtr(dir)}, nil
+		case "d.is_multi_file":
+			return Value{Int: intPtr(1)}, nil
+		case "f.multicall":
+			var rows []Value
+			for _, path := range []string{"a.bin", "disc 2/b.bin", "c.bin"} {
+				rows = append(rows, Value{Array: &ValArray{Data: []Value{{String: stringPtr(path)}}}})
+			}
+			return Value{Array: &ValArray{Data: rows}}, nil
+		case "d.directory_base.set":
+			if fault := setDirectory(args[1].GetString()); fault != nil {
+				return Value{}, fault
+			}
+			dir = args[1].GetString()
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+	c.roots = []string{root}
+	return root, c
+}
+
+func assertFiles(t *testing.T, dir string) {
+	t.Helper()
+	for path, size := range map[string]int64{"a.bin": 10, "disc 2/b.bin": 20} {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		if info.Size() != size {
+			t.Errorf("%s is %d bytes, want %d", path, info.Size(), size)
+		}
+	}
+}
+
+func TestMoveStorage(t *testing.T) {
+	root, c := moveFixture(t, func(string) *FaultError { return nil })
+	if err := c.MoveStorage(context.Background(), "ABC", filepath.Join(root, "dst"), true); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, filepath.Join(root, "dst", "Album"))
+	if _, err := os.Stat(filepath.Join(root, "src", "Album")); !os.IsNotExist(err) {
+		t.Errorf("source directory still there: %v", err)
+	}
+}
+
+func TestMoveStorageRollsBack(t *testing.T) {
+	root, c := moveFixture(t, func(string) *FaultError {
+		return &FaultError{Code: -503, Message: "Could not set directory"}
+	})
+	err := c.MoveStorage(context.Background(), "ABC", filepath.Join(root, "dst"), true)
+	if err == nil || !strings.Contains(err.Error(), "Could not set directory") {
+		t.Fatalf("err = %v, want the fault", err)
+	}
+	assertFiles(t, filepath.Join(root, "src", "Album"))
+	if _, err := os.Stat(filepath.Join(root, "dst", "Album")); !os.IsNotExist(err) {
+		t.Errorf("destination left behind: %v", err)
+	}
+}
+
+func TestMoveStorageVerifiesFiles(t *testing.T) {
+	// Something removes a file right after the move
+	root, c := moveFixture(t, func(dir string) *FaultError {
+		os.Remove(filepath.Join(dir, "a.bin"))
+		return nil
+	})
+	err := c.MoveStorage(context.Background(), "ABC", filepath.Join(root, "dst"), true)
+	if err == nil || !strings.Contains(err.Error(), "move verification failed") {
+		t.Fatalf("err = %v, want a verification failure", err)
+	}
+}
@@ -0,0 +1,81 @@
+package rtorrent
+
+import (
+	"context"
+	"fmt"
+)
+
+// multicallItem is a single method invocation inside a system.multicall batch
+type multicallItem struct {
+	Method string
+	Args   []Value
+}
+
+// multicallResult holds the outcome of one batched call. Err is set when
+// rTorrent answered that particular call with a fault; the rest of the batch
+// is unaffected.
+type multicallResult struct {
+	Value Value
+	Err   error
+}
+
+// multicall sends every item in a single system.multicall round trip and
+// returns one result per item, in order. The returned error only covers
+// transport or protocol failures of the batch as a whole.
+func (c *xmlrpcClient) multicall(ctx context.Context, items []multicallItem) ([]multicallResult, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	calls := make([]Value, 0, len(items))
+	for _, item := range items {
+		params := item.Args
+		if params == nil {
+			params = []Value{}
+		}
+		calls = append(calls, Value{Struct: &ValStruct{Members: []Member{
+			{Name: "methodName", Value: Value{String: stringPtr(item.Method)}},
+			{Name: "params", Value: Value{Array: &ValArray{Data: params}}},
+		}}})
+	}
+
+	resp, err := c.call(ctx, "system.multicall", Value{Array: &ValArray{Data: calls}})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Params) == 0 {
+		return nil, fmt.Errorf("%w: empty response", ErrProtocol)
+	}
+
+	entries := resp.Params[0].Value.GetArray()
+	if len(entries) != len(items) {
+		return nil, fmt.Errorf("%w: multicall returned %d results for %d calls", ErrProtocol, len(entries), len(items))
+	}
+
+	// Each entry is either a one-element array wrapping the return value,
+	// or a fault struct with faultCode/faultString members.
+	results := make([]multicallResult, len(entries))
+	for i, entry := range entries {
+		if entry.Struct != nil {
+			results[i].Err = faultError(entry)
+			continue
+		}
+		values := entry.GetArray()
+		if len(values) == 0 {
+			results[i].Err = fmt.Errorf("%w: %s returned no value", ErrProtocol, items[i].Method)
+			continue
+		}
+		results[i].Value = values[0]
+	}
+
+	return results, nil
+}
+
+// torrentGetters builds a batch of single-argument d.* getters for one hash
+func torrentGetters(hash string, methods ...string) []multicallItem {
+	items := make([]multicallItem, len(methods))
+	for i, method := range methods {
+		items[i] = multicallItem{Method: method, Args: []Value{{String: stringPtr(hash)}}}
+	}
+	return items
+}
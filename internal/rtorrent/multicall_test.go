@@ -0,0 +1,65 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMulticall(t *testing.T) {
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		switch method {
+		case "d.name":
+			return Value{String: stringPtr("name of " + args[0].GetString())}, nil
+		case "d.size_bytes":
+			return Value{I8: intPtr(1 << 33)}, nil
+		}
+		return Value{}, &FaultError{Code: -501, Message: "Could not find info-hash."}
+	})
+
+	results, err := c.multicall(context.Background(), append(torrentGetters("ABC", "d.name", "d.size_bytes"),
+		multicallItem{Method: "d.name.missing", Args: []Value{{String: stringPtr("ABC")}}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if got := results[0].Value.GetString(); results[0].Err != nil || got != "name of ABC" {
+		t.Errorf("result 0 = %q, %v", got, results[0].Err)
+	}
+	if got := results[1].Value.GetLong(); results[1].Err != nil || got != 1<<33 {
+		t.Errorf("result 1 = %d, %v", got, results[1].Err)
+	}
+	if !errors.Is(results[2].Err, ErrNotFound) {
+		t.Errorf("result 2 err = %v, want ErrNotFound", results[2].Err)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 3 || calls[0].Method != "d.name" || calls[0].Args[0].GetString() != "ABC" {
+		t.Errorf("server saw %+v", calls)
+	}
+}
+
+func TestMulticallMismatchedResults(t *testing.T) {
+	c := newFixedRTorrent(t, `<methodResponse><params><param><value><array><data>
+		<value><array><data><value><i8>1</i8></value></data></array></value>
+	</data></array></value></param></params></methodResponse>`)
+	_, err := c.multicall(context.Background(), torrentGetters("ABC", "d.name", "d.size_bytes"))
+	if !errors.Is(err, ErrProtocol) {
+		t.Errorf("err = %v, want ErrProtocol", err)
+	}
+}
+
+func TestMulticallEmptyResult(t *testing.T) {
+	c := newFixedRTorrent(t, `<methodResponse><params><param><value><array><data>
+		<value><array><data></data></array></value>
+	</data></array></value></param></params></methodResponse>`)
+	results, err := c.multicall(context.Background(), torrentGetters("ABC", "d.name"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(results[0].Err, ErrProtocol) {
+		t.Errorf("err = %v, want ErrProtocol", results[0].Err)
+	}
+}
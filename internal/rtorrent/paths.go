@@ -0,0 +1,19 @@
+package rtorrent
+
+import (
+	"errors"
+
+	"rtorrent-go/internal/pathutil"
+)
+
+// ErrPathNotAllowed is returned when an operation would touch a path
+// outside the configured download roots.
+var ErrPathNotAllowed = errors.New("path is outside the download roots")
+
+// WithDownloadRoots sets the directories VibeTorrent may modify on disk.
+// Operations that delete or move data refuse to work without at least one.
+func WithDownloadRoots(roots []string) Option {
+	return func(c *xmlrpcClient) {
+		c.roots = pathutil.CleanRoots(roots)
+	}
+}
@@ -0,0 +1,73 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ErrInvalidPeer is returned for peer addresses that aren't host:port
+var ErrInvalidPeer = errors.New("invalid peer address")
+
+// peerTarget addresses one peer of a torrent by its p.id
+func peerTarget(hash, peerID string) Value {
+	return Value{String: stringPtr(hash + ":p" + peerID)}
+}
+
+// DisconnectPeer drops the connection to a peer. It may connect again.
+func (c *xmlrpcClient) DisconnectPeer(ctx context.Context, hash, peerID string) error {
+	_, err := c.call(ctx, "p.disconnect", peerTarget(hash, peerID))
+	return err
+}
+
+// BanPeer disconnects a peer and refuses it for the rest of the session
+func (c *xmlrpcClient) BanPeer(ctx context.Context, hash, peerID string) error {
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "p.banned.set", Args: []Value{peerTarget(hash, peerID), {Int: intPtr(1)}}},
+		{Method: "p.disconnect", Args: []Value{peerTarget(hash, peerID)}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// SetPeerSnubbed stops or resumes uploading to a peer
+func (c *xmlrpcClient) SetPeerSnubbed(ctx context.Context, hash, peerID string, snubbed bool) error {
+	var value int64
+	if snubbed {
+		value = 1
+	}
+	_, err := c.call(ctx, "p.snubbed.set", peerTarget(hash, peerID), Value{Int: intPtr(value)})
+	return err
+}
+
+// AddPeer connects the torrent to a peer at host:port
+func (c *xmlrpcClient) AddPeer(ctx context.Context, hash, address string) error {
+	if err := validatePeerAddress(address); err != nil {
+		return err
+	}
+	_, err := c.call(ctx, "d.add_peer", Value{String: stringPtr(hash)}, Value{String: stringPtr(address)})
+	return err
+}
+
+func validatePeerAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPeer, err)
+	}
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrInvalidPeer)
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%w: bad port %q", ErrInvalidPeer, port)
+	}
+	return nil
+}
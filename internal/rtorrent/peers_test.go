@@ -0,0 +1,139 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// resultRow builds a multicall result row from strings and integers
+func resultRow(fields ...interface{}) Value {
+	var data []Value
+	for _, f := range fields {
+		switch f := f.(type) {
+		case string:
+			data = append(data, Value{String: stringPtr(f)})
+		case int:
+			data = append(data, Value{I8: intPtr(int64(f))})
+		}
+	}
+	return Value{Array: &ValArray{Data: data}}
+}
+
+func TestGetPeers(t *testing.T) {
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		return Value{Array: &ValArray{Data: []Value{
+			resultRow("A1", "10.0.0.1", 51413, "Transmission 4.0", 2048, 512, 37, 1, 0, 0),
+			resultRow("B2", "2001:db8::2", 6881, "qBittorrent 4.6", 0, 4096, 100, 0, 1, 1),
+			// Rows missing fields are skipped rather than misread
+			resultRow("C3", "10.0.0.3"),
+		}}}, nil
+	})
+
+	peers, err := c.GetPeers(context.Background(), "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Peer{
+		{ID: "A1", Address: "10.0.0.1", Port: 51413, Client: "Transmission 4.0", DownloadRate: 2048, UploadRate: 512, Progress: 37, Encrypted: true},
+		{ID: "B2", Address: "2001:db8::2", Port: 6881, Client: "qBittorrent 4.6", UploadRate: 4096, Progress: 100, Incoming: true, Snubbed: true},
+	}
+	if !reflect.DeepEqual(peers, want) {
+		t.Errorf("peers = %+v, want %+v", peers, want)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Method != "p.multicall" {
+		t.Fatalf("calls = %v, want a single p.multicall", fake.Methods())
+	}
+	args := calls[0].Args
+	if len(args) != 12 || args[0].GetString() != "ABC" || args[1].GetString() != "" || args[2].GetString() != "p.id=" {
+		t.Errorf("p.multicall args = %+v", args)
+	}
+}
+
+func TestGetPeersNone(t *testing.T) {
+	_, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		return Value{Array: &ValArray{}}, nil
+	})
+	peers, err := c.GetPeers(context.Background(), "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peers == nil || len(peers) != 0 {
+		t.Errorf("peers = %#v, want an empty list", peers)
+	}
+}
+
+func TestPeerActions(t *testing.T) {
+	ctx := context.Background()
+	fake, c := newFakeRTorrent(t, nil)
+
+	if err := c.DisconnectPeer(ctx, "ABC", "A1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BanPeer(ctx, "ABC", "B2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerSnubbed(ctx, "ABC", "C3", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetPeerSnubbed(ctx, "ABC", "C3", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddPeer(ctx, "ABC", "[2001:db8::1]:6881"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, call := range fake.Calls() {
+		line := call.Method
+		for _, arg := range call.Args {
+			if arg.String != nil {
+				line += " " + arg.GetString()
+			} else {
+				line += " " + strconv.FormatInt(arg.GetLong(), 10)
+			}
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"p.disconnect ABC:pA1",
+		// Banning alone leaves the peer connected until it goes
+		"p.banned.set ABC:pB2 1",
+		"p.disconnect ABC:pB2",
+		"p.snubbed.set ABC:pC3 1",
+		"p.snubbed.set ABC:pC3 0",
+		"d.add_peer ABC [2001:db8::1]:6881",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestAddPeerInvalid(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	for _, address := range []string{"10.0.0.1", ":6881", "10.0.0.1:0", "10.0.0.1:70000", "10.0.0.1:http", "2001:db8::1:6881"} {
+		if err := c.AddPeer(context.Background(), "ABC", address); !errors.Is(err, ErrInvalidPeer) {
+			t.Errorf("AddPeer(%q) err = %v, want ErrInvalidPeer", address, err)
+		}
+	}
+	if calls := fake.Methods(); len(calls) != 0 {
+		t.Errorf("invalid addresses reached rTorrent: %v", calls)
+	}
+}
+
+func TestBanPeerFault(t *testing.T) {
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		return Value{}, &FaultError{Code: -501, Message: "Could not find peer."}
+	})
+	if err := c.BanPeer(context.Background(), "ABC", "B2"); err == nil {
+		t.Error("no error for an unknown peer")
+	}
+	if got := fake.Methods(); len(got) != 2 {
+		t.Errorf("calls = %v", got)
+	}
+}
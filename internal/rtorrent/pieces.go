@@ -0,0 +1,173 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Commands behind the piece-priority modes. First/last piece priority is a
+// per-file flag in stock rTorrent; sequential download only exists in some
+// forks, hence the capability check.
+const (
+	cmdPrioritizeFirst = "f.prioritize_first"
+	cmdPrioritizeLast  = "f.prioritize_last"
+	cmdSequential      = "d.down.sequential"
+)
+
+// ErrUnsupported is returned for features the connected rTorrent lacks
+var ErrUnsupported = errors.New("not supported by this rTorrent build")
+
+// Capabilities lists optional commands the connected rTorrent provides
+type Capabilities struct {
+	FirstLastPiece bool `json:"first_last_piece"`
+	Sequential     bool `json:"sequential"`
+}
+
+// PieceModes are a torrent's piece-priority toggles, used to start playing
+// media before the download completes
+type PieceModes struct {
+	// FirstLastPiece fetches the first and last pieces of every file
+	// early; players need both to open most containers
+	FirstLastPiece bool `json:"first_last_piece"`
+	// Sequential downloads pieces in order
+	Sequential   bool         `json:"sequential"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// Capabilities asks rTorrent which commands it knows. The answer is cached
+// for the lifetime of the client.
+func (c *xmlrpcClient) Capabilities(ctx context.Context) (Capabilities, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	if c.caps != nil {
+		return *c.caps, nil
+	}
+
+	resp, err := c.call(ctx, "system.listMethods")
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if len(resp.Params) == 0 {
+		return Capabilities{}, fmt.Errorf("%w: empty system.listMethods response", ErrProtocol)
+	}
+	methods := make(map[string]bool)
+	for _, v := range resp.Params[0].Value.GetArray() {
+		methods[v.GetString()] = true
+	}
+	c.caps = &Capabilities{
+		FirstLastPiece: methods[cmdPrioritizeFirst+".enable"] && methods[cmdPrioritizeLast+".enable"],
+		Sequential:     methods[cmdSequential+".set"],
+	}
+	return *c.caps, nil
+}
+
+// GetPieceModes returns the torrent's piece-priority modes. First/last
+// piece priority counts as on when every file has both flags set.
+func (c *xmlrpcClient) GetPieceModes(ctx context.Context, hash string) (*PieceModes, error) {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	modes := &PieceModes{Capabilities: caps}
+
+	if caps.FirstLastPiece {
+		resp, err := c.call(ctx, "f.multicall",
+			Value{String: stringPtr(hash)},
+			Value{String: stringPtr("")},
+			Value{String: stringPtr(cmdPrioritizeFirst + "=")},
+			Value{String: stringPtr(cmdPrioritizeLast + "=")},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Params) == 0 {
+			return nil, fmt.Errorf("%w: empty response", ErrProtocol)
+		}
+		rows := resp.Params[0].Value.GetArray()
+		modes.FirstLastPiece = len(rows) > 0
+		for _, rowValue := range rows {
+			row := rowValue.GetArray()
+			if len(row) < 2 || row[0].GetLong() == 0 || row[1].GetLong() == 0 {
+				modes.FirstLastPiece = false
+				break
+			}
+		}
+	}
+
+	if caps.Sequential {
+		resp, err := c.call(ctx, cmdSequential, Value{String: stringPtr(hash)})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Params) == 0 {
+			return nil, fmt.Errorf("%w: empty %s response", ErrProtocol, cmdSequential)
+		}
+		modes.Sequential = resp.Params[0].Value.GetLong() != 0
+	}
+	return modes, nil
+}
+
+// SetFirstLastPiece flags the first and last piece of every file for early
+// download, then lets rTorrent recompute chunk priorities
+func (c *xmlrpcClient) SetFirstLastPiece(ctx context.Context, hash string, enabled bool) error {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if !caps.FirstLastPiece {
+		return fmt.Errorf("first/last piece priority: %w", ErrUnsupported)
+	}
+
+	resp, err := c.call(ctx, "d.size_files", Value{String: stringPtr(hash)})
+	if err != nil {
+		return err
+	}
+	if len(resp.Params) == 0 {
+		return fmt.Errorf("%w: empty d.size_files response", ErrProtocol)
+	}
+	count := int(resp.Params[0].Value.GetLong())
+
+	suffix := ".disable"
+	if enabled {
+		suffix = ".enable"
+	}
+	items := make([]multicallItem, 0, 2*count+1)
+	for i := 0; i < count; i++ {
+		target := Value{String: stringPtr(fmt.Sprintf("%s:f%d", hash, i))}
+		items = append(items,
+			multicallItem{Method: cmdPrioritizeFirst + suffix, Args: []Value{target}},
+			multicallItem{Method: cmdPrioritizeLast + suffix, Args: []Value{target}},
+		)
+	}
+	items = append(items, multicallItem{Method: "d.update_priorities", Args: []Value{{String: stringPtr(hash)}}})
+
+	results, err := c.multicall(ctx, items)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// SetSequential switches in-order downloading on or off, on builds that
+// support it
+func (c *xmlrpcClient) SetSequential(ctx context.Context, hash string, enabled bool) error {
+	caps, err := c.Capabilities(ctx)
+	if err != nil {
+		return err
+	}
+	if !caps.Sequential {
+		return fmt.Errorf("sequential download: %w", ErrUnsupported)
+	}
+	var value int64
+	if enabled {
+		value = 1
+	}
+	_, err = c.call(ctx, cmdSequential+".set", Value{String: stringPtr(hash)}, Value{Int: intPtr(value)})
+	return err
+}
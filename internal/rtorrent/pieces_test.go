@@ -0,0 +1,136 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// piecesRTorrent fakes an rTorrent whose system.listMethods lists methods
+func piecesRTorrent(t *testing.T, methods ...string) (*fakeRTorrent, *xmlrpcClient) {
+	t.Helper()
+	return newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		if method == "system.listMethods" {
+			var names []Value
+			for _, m := range methods {
+				names = append(names, Value{String: stringPtr(m)})
+			}
+			return Value{Array: &ValArray{Data: names}}, nil
+		}
+		if !slices.Contains(methods, method) {
+			return Value{}, &FaultError{Code: -506, Message: "Method '" + method + "' not defined"}
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+}
+
+func TestPieceModesUnsupported(t *testing.T) {
+	ctx := context.Background()
+	// A stock build without either optional command
+	fake, c := piecesRTorrent(t, "d.name", "f.multicall", "d.size_files")
+
+	modes, err := c.GetPieceModes(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *modes != (PieceModes{}) {
+		t.Errorf("modes = %+v, want everything off and unsupported", modes)
+	}
+	if err := c.SetSequential(ctx, "ABC", true); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SetSequential err = %v, want ErrUnsupported", err)
+	}
+	if err := c.SetFirstLastPiece(ctx, "ABC", true); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("SetFirstLastPiece err = %v, want ErrUnsupported", err)
+	}
+
+	// Only the capability check reached rTorrent, and only once
+	if got := fake.Methods(); !slices.Equal(got, []string{"system.listMethods"}) {
+		t.Errorf("calls = %v, want a single system.listMethods", got)
+	}
+}
+
+func TestPieceModesPartialSupport(t *testing.T) {
+	// f.prioritize_first without f.prioritize_last isn't enough
+	fake, c := piecesRTorrent(t, cmdPrioritizeFirst+".enable", cmdSequential, cmdSequential+".set")
+
+	modes, err := c.GetPieceModes(context.Background(), "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Capabilities{Sequential: true}
+	if modes.Capabilities != want || modes.FirstLastPiece {
+		t.Errorf("modes = %+v, want only sequential support", modes)
+	}
+	if slices.Contains(fake.Methods(), "f.multicall") {
+		t.Error("file flags read although first/last piece priority is unsupported")
+	}
+}
+
+func TestPieceModesSupported(t *testing.T) {
+	ctx := context.Background()
+	supported := []string{
+		cmdPrioritizeFirst + ".enable", cmdPrioritizeLast + ".enable",
+		cmdSequential, cmdSequential + ".set",
+	}
+	// The second file lacks the last piece flag until the toggle is used
+	firstLast := []Value{resultRow(1, 1), resultRow(1, 0)}
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		switch method {
+		case "system.listMethods":
+			var names []Value
+			for _, m := range supported {
+				names = append(names, Value{String: stringPtr(m)})
+			}
+			return Value{Array: &ValArray{Data: names}}, nil
+		case "f.multicall":
+			return Value{Array: &ValArray{Data: firstLast}}, nil
+		case cmdSequential:
+			return Value{I8: intPtr(1)}, nil
+		case "d.size_files":
+			return Value{I8: intPtr(2)}, nil
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+
+	modes, err := c.GetPieceModes(ctx, "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PieceModes{Sequential: true, Capabilities: Capabilities{FirstLastPiece: true, Sequential: true}}
+	if *modes != want {
+		t.Errorf("modes = %+v, want %+v", modes, want)
+	}
+
+	if err := c.SetFirstLastPiece(ctx, "ABC", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetSequential(ctx, "ABC", false); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, call := range fake.Calls() {
+		switch call.Method {
+		case "system.listMethods", "f.multicall", cmdSequential, "d.size_files":
+			continue
+		}
+		got = append(got, call.Method+" "+call.Args[0].GetString())
+		if call.Method == cmdSequential+".set" && call.Args[1].GetLong() != 0 {
+			t.Errorf("%s value = %d, want 0", call.Method, call.Args[1].GetLong())
+		}
+	}
+	wantCalls := []string{
+		cmdPrioritizeFirst + ".enable ABC:f0", cmdPrioritizeLast + ".enable ABC:f0",
+		cmdPrioritizeFirst + ".enable ABC:f1", cmdPrioritizeLast + ".enable ABC:f1",
+		"d.update_priorities ABC",
+		cmdSequential + ".set ABC",
+	}
+	if !slices.Equal(got, wantCalls) {
+		t.Errorf("calls = %v, want %v", got, wantCalls)
+	}
+	// The capability check ran once for all four calls
+	if n := slices.Index(fake.Methods()[1:], "system.listMethods"); n >= 0 {
+		t.Error("capabilities were checked again")
+	}
+}
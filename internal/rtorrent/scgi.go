@@ -0,0 +1,126 @@
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxResponseSize caps how much of a single XML-RPC response we are
+// willing to read. A d.multicall2 over a few thousand torrents is a handful
+// of megabytes, so this leaves plenty of headroom.
+const DefaultMaxResponseSize int64 = 32 << 20
+
+// ErrResponseTooLarge is returned when rTorrent sends more than the
+// configured maximum response size.
+var ErrResponseTooLarge = errors.New("response exceeds maximum size")
+
+// encodeSCGIRequest frames an XML-RPC body as an SCGI request:
+// a netstring of NUL-separated headers followed by the raw body.
+func encodeSCGIRequest(body []byte) []byte {
+	headers := fmt.Sprintf("CONTENT_LENGTH\x00%d\x00SCGI\x001\x00", len(body))
+
+	var buf bytes.Buffer
+	buf.Grow(len(headers) + len(body) + 16)
+	fmt.Fprintf(&buf, "%d:%s,", len(headers), headers)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// readSCGIResponse consumes the CGI-style response headers rTorrent writes
+// before the XML body ("Status: 200 OK", "Content-Length: N", ...) and
+// returns a reader limited to the body. An HTTP status line is accepted in
+// place of the Status header, and a response without any headers is treated
+// as a bare body that runs until EOF.
+func readSCGIResponse(br *bufio.Reader, maxSize int64) (io.Reader, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxResponseSize
+	}
+
+	// Some SCGI bridges skip the headers entirely
+	if peek, _ := br.Peek(5); string(peek) == "<?xml" {
+		return &maxBytesReader{r: br, remaining: maxSize}, nil
+	}
+
+	tp := textproto.NewReader(br)
+	status := 200
+
+	if peek, _ := br.Peek(5); string(peek) == "HTTP/" {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return nil, fmt.Errorf("read status line: %w", err)
+		}
+		_, rest, _ := strings.Cut(line, " ")
+		code, err := parseStatusCode(rest)
+		if err != nil {
+			return nil, fmt.Errorf("malformed status line %q", line)
+		}
+		status = code
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, fmt.Errorf("read response headers: %w", err)
+	}
+
+	if s := header.Get("Status"); s != "" {
+		code, err := parseStatusCode(s)
+		if err != nil {
+			return nil, fmt.Errorf("malformed status header %q", s)
+		}
+		status = code
+	}
+	if status < 200 || status > 299 {
+		return nil, fmt.Errorf("unexpected response status %d", status)
+	}
+
+	cl := header.Get("Content-Length")
+	if cl == "" {
+		return &maxBytesReader{r: br, remaining: maxSize}, nil
+	}
+
+	length, err := strconv.ParseInt(strings.TrimSpace(cl), 10, 64)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("malformed content length %q", cl)
+	}
+	if length > maxSize {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrResponseTooLarge, length, maxSize)
+	}
+
+	return io.LimitReader(br, length), nil
+}
+
+// parseStatusCode extracts the numeric code from "200 OK" style strings.
+func parseStatusCode(s string) (int, error) {
+	code, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+	return strconv.Atoi(code)
+}
+
+// maxBytesReader reads until EOF but fails once more than remaining bytes
+// have been seen. Used when the response carries no Content-Length.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// Distinguish a clean EOF at the limit from an oversized body
+		var one [1]byte
+		if n, _ := m.r.Read(one[:]); n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,112 @@
+package rtorrent
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSCGIRequest(t *testing.T) {
+	body := []byte("<?xml version=\"1.0\"?><methodCall/>")
+	req := encodeSCGIRequest(body)
+
+	wantPrefix := "25:CONTENT_LENGTH\x0034\x00SCGI\x001\x00,"
+	if !strings.HasPrefix(string(req), wantPrefix) {
+		t.Fatalf("request starts with %q, want %q", req, wantPrefix)
+	}
+
+	headers, got, err := readSCGIRequest(bufio.NewReader(strings.NewReader(string(req))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["SCGI"] != "1" {
+		t.Errorf("SCGI header = %q, want 1", headers["SCGI"])
+	}
+	if string(got) != string(body) {
+		t.Errorf("body = %q, want %q", got, body)
+	}
+}
+
+func TestReadSCGIResponse(t *testing.T) {
+	const body = "<?xml version=\"1.0\"?><methodResponse/>"
+	tests := []struct {
+		name    string
+		in      string
+		maxSize int64
+		want    string
+		wantErr error
+	}{
+		{
+			name: "cgi headers",
+			in:   "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: 38\r\n\r\n" + body + "trailing",
+			want: body,
+		},
+		{
+			name: "http status line",
+			in:   "HTTP/1.1 200 OK\r\nContent-Length: 38\r\n\r\n" + body,
+			want: body,
+		},
+		{
+			name: "bare body",
+			in:   body,
+			want: body,
+		},
+		{
+			name: "no content length",
+			in:   "Content-Type: text/xml\r\n\r\n" + body,
+			want: body,
+		},
+		{
+			name:    "error status",
+			in:      "Status: 500 Internal Server Error\r\n\r\n",
+			wantErr: errAny,
+		},
+		{
+			name:    "bad content length",
+			in:      "Content-Length: -1\r\n\r\n" + body,
+			wantErr: errAny,
+		},
+		{
+			name:    "declared length too large",
+			in:      "Content-Length: 38\r\n\r\n" + body,
+			maxSize: 10,
+			wantErr: ErrResponseTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := readSCGIResponse(bufio.NewReader(strings.NewReader(tt.in)), tt.maxSize)
+			if tt.wantErr != nil {
+				if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSCGIResponseUnknownLengthTooLarge(t *testing.T) {
+	r, err := readSCGIResponse(bufio.NewReader(strings.NewReader("\r\n0123456789abc")), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("err = %v, want ErrResponseTooLarge", err)
+	}
+}
+
+// errAny marks test cases that expect some error without caring which
+var errAny = errors.New("any error")
@@ -0,0 +1,209 @@
+package rtorrent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readSCGIRequest parses a request framed by encodeSCGIRequest: a netstring
+// of NUL-separated header pairs followed by CONTENT_LENGTH bytes of body.
+func readSCGIRequest(br *bufio.Reader) (map[string]string, []byte, error) {
+	prefix, err := br.ReadString(':')
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(prefix, ":"))
+	if err != nil || n < 0 {
+		return nil, nil, fmt.Errorf("bad netstring length %q", prefix)
+	}
+	raw := make([]byte, n+1)
+	if _, err := io.ReadFull(br, raw); err != nil {
+		return nil, nil, err
+	}
+	if raw[n] != ',' {
+		return nil, nil, fmt.Errorf("netstring not terminated by a comma")
+	}
+	fields := strings.Split(string(raw[:n]), "\x00")
+	if len(fields)%2 != 1 || fields[len(fields)-1] != "" {
+		return nil, nil, fmt.Errorf("headers are not NUL-terminated pairs")
+	}
+	headers := make(map[string]string)
+	for i := 0; i+1 < len(fields); i += 2 {
+		headers[fields[i]] = fields[i+1]
+	}
+	length, err := strconv.Atoi(headers["CONTENT_LENGTH"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad CONTENT_LENGTH %q", headers["CONTENT_LENGTH"])
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(br, body); err != nil {
+		return nil, nil, err
+	}
+	return headers, body, nil
+}
+
+// rpcCall is one method invocation seen by fakeRTorrent, with the items of
+// a system.multicall flattened into separate calls
+type rpcCall struct {
+	Method string
+	Args   []Value
+}
+
+// rpcHandler answers a single call with a value or a fault
+type rpcHandler func(method string, args []Value) (Value, *FaultError)
+
+// fakeRTorrent is an SCGI server speaking just enough XML-RPC for the
+// client: it expands system.multicall, records every call and answers
+// through handle.
+type fakeRTorrent struct {
+	t      *testing.T
+	ln     net.Listener
+	handle rpcHandler
+
+	mu    sync.Mutex
+	calls []rpcCall
+}
+
+// newFakeRTorrent starts a server and returns a client connected to it.
+// A nil handle answers every call with 0.
+func newFakeRTorrent(t *testing.T, handle rpcHandler) (*fakeRTorrent, *xmlrpcClient) {
+	t.Helper()
+	if handle == nil {
+		handle = func(string, []Value) (Value, *FaultError) { return Value{Int: intPtr(0)}, nil }
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRTorrent{t: t, ln: ln, handle: handle}
+	t.Cleanup(func() { ln.Close() })
+	go f.serve()
+	return f, &xmlrpcClient{addr: ln.Addr().String(), maxResponseSize: DefaultMaxResponseSize}
+}
+
+// Calls returns every call received so far
+func (f *fakeRTorrent) Calls() []rpcCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]rpcCall(nil), f.calls...)
+}
+
+// Methods returns the method names of every call received so far
+func (f *fakeRTorrent) Methods() []string {
+	var methods []string
+	for _, c := range f.Calls() {
+		methods = append(methods, c.Method)
+	}
+	return methods
+}
+
+func (f *fakeRTorrent) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			if err := f.serveConn(conn); err != nil {
+				f.t.Errorf("fake rtorrent: %v", err)
+			}
+		}()
+	}
+}
+
+func (f *fakeRTorrent) serveConn(conn net.Conn) error {
+	_, body, err := readSCGIRequest(bufio.NewReader(conn))
+	if err != nil {
+		return err
+	}
+	var call MethodCall
+	if err := xml.Unmarshal(body, &call); err != nil {
+		return err
+	}
+	var args []Value
+	for _, p := range call.Params {
+		args = append(args, p.Value)
+	}
+
+	var resp MethodResponse
+	if call.MethodName == "system.multicall" && len(args) == 1 {
+		resp.Params = []Param{{Value: f.multicall(args[0])}}
+	} else {
+		v, fault := f.dispatch(call.MethodName, args)
+		if fault != nil {
+			resp.Fault = &Fault{Value: faultValue(fault)}
+		} else {
+			resp.Params = []Param{{Value: v}}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := xml.NewEncoder(&buf).EncodeElement(resp, xml.StartElement{Name: xml.Name{Local: "methodResponse"}}); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Type: text/xml\r\nContent-Length: %d\r\n\r\n%s", buf.Len(), buf.Bytes())
+	return err
+}
+
+func (f *fakeRTorrent) multicall(batch Value) Value {
+	var results []Value
+	for _, item := range batch.GetArray() {
+		members := item.GetStruct()
+		v, fault := f.dispatch(members["methodName"].GetString(), members["params"].GetArray())
+		if fault != nil {
+			results = append(results, faultValue(fault))
+			continue
+		}
+		results = append(results, Value{Array: &ValArray{Data: []Value{v}}})
+	}
+	return Value{Array: &ValArray{Data: results}}
+}
+
+func (f *fakeRTorrent) dispatch(method string, args []Value) (Value, *FaultError) {
+	f.mu.Lock()
+	f.calls = append(f.calls, rpcCall{Method: method, Args: args})
+	f.mu.Unlock()
+	return f.handle(method, args)
+}
+
+func faultValue(fault *FaultError) Value {
+	return Value{Struct: &ValStruct{Members: []Member{
+		{Name: "faultCode", Value: Value{Int: intPtr(int64(fault.Code))}},
+		{Name: "faultString", Value: Value{String: stringPtr(fault.Message)}},
+	}}}
+}
+
+// newFixedRTorrent returns a client whose every call is answered with body,
+// for responses the fake server wouldn't produce
+func newFixedRTorrent(t *testing.T, body string) *xmlrpcClient {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, _, err := readSCGIRequest(bufio.NewReader(conn)); err == nil {
+					fmt.Fprintf(conn, "Status: 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+				}
+			}()
+		}
+	}()
+	return &xmlrpcClient{addr: ln.Addr().String(), maxResponseSize: DefaultMaxResponseSize}
+}
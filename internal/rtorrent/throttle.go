@@ -0,0 +1,120 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Throttle holds bandwidth caps in bytes per second. Zero means unlimited.
+type Throttle struct {
+	DownloadRate int64 `json:"download_rate"`
+	UploadRate   int64 `json:"upload_rate"`
+}
+
+func (c *xmlrpcClient) GetGlobalThrottle(ctx context.Context) (*Throttle, error) {
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "throttle.global_down.max_rate"},
+		{Method: "throttle.global_up.max_rate"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+	}
+	return &Throttle{
+		DownloadRate: results[0].Value.GetLong(),
+		UploadRate:   results[1].Value.GetLong(),
+	}, nil
+}
+
+func (c *xmlrpcClient) SetGlobalThrottle(ctx context.Context, t Throttle) error {
+	if t.DownloadRate < 0 || t.UploadRate < 0 {
+		return fmt.Errorf("invalid throttle: rates must not be negative")
+	}
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "throttle.global_down.max_rate.set", Args: []Value{{String: stringPtr("")}, {Int: intPtr(t.DownloadRate)}}},
+		{Method: "throttle.global_up.max_rate.set", Args: []Value{{String: stringPtr("")}, {Int: intPtr(t.UploadRate)}}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// GetMaxPeers returns rTorrent's per-torrent peer limit for downloads
+func (c *xmlrpcClient) GetMaxPeers(ctx context.Context) (int64, error) {
+	resp, err := c.call(ctx, "throttle.max_peers.normal")
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Params) == 0 {
+		return 0, fmt.Errorf("%w: empty throttle.max_peers.normal response", ErrProtocol)
+	}
+	return resp.Params[0].Value.GetLong(), nil
+}
+
+func (c *xmlrpcClient) SetMaxPeers(ctx context.Context, n int64) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid peer limit %d", n)
+	}
+	_, err := c.call(ctx, "throttle.max_peers.normal.set", Value{String: stringPtr("")}, Value{Int: intPtr(n)})
+	return err
+}
+
+// SetThrottleGroup creates or updates a named throttle. Rates are in bytes
+// per second, 0 means unlimited. rTorrent has no way to list or delete
+// named throttles, so callers keep track of them.
+func (c *xmlrpcClient) SetThrottleGroup(ctx context.Context, name string, downloadRate, uploadRate int64) error {
+	if name == "" {
+		return fmt.Errorf("invalid throttle group: empty name")
+	}
+	if downloadRate < 0 || uploadRate < 0 {
+		return fmt.Errorf("invalid throttle group: rates must not be negative")
+	}
+	// throttle.down/up take the rate in KiB/s as a string. Rounding up
+	// keeps rates under 1 KiB/s from becoming 0, which is unlimited.
+	kib := func(rate int64) *string { return stringPtr(strconv.FormatInt((rate+1023)/1024, 10)) }
+	results, err := c.multicall(ctx, []multicallItem{
+		{Method: "throttle.down", Args: []Value{{String: stringPtr("")}, {String: stringPtr(name)}, {String: kib(downloadRate)}}},
+		{Method: "throttle.up", Args: []Value{{String: stringPtr("")}, {String: stringPtr(name)}, {String: kib(uploadRate)}}},
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// SetTorrentThrottle puts a torrent into a named throttle group, or back
+// under the global throttle when name is empty. rTorrent only accepts the
+// change while the torrent is stopped, so a running torrent is stopped and
+// started again around it.
+func (c *xmlrpcClient) SetTorrentThrottle(ctx context.Context, hash, name string) error {
+	state, err := c.torrentRunState(ctx, hash)
+	if err != nil {
+		return err
+	}
+	if state.started {
+		if err := c.callEach(ctx, hash, "d.stop"); err != nil {
+			return err
+		}
+	}
+	if _, err := c.call(ctx, "d.throttle_name.set", Value{String: stringPtr(hash)}, Value{String: stringPtr(name)}); err != nil {
+		return errors.Join(err, c.restoreRunState(ctx, hash, state))
+	}
+	return c.restoreRunState(ctx, hash, state)
+}
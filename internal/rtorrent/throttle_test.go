@@ -0,0 +1,108 @@
+package rtorrent
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestGlobalThrottle(t *testing.T) {
+	ctx := context.Background()
+	rates := map[string]int64{
+		"throttle.global_down.max_rate": 0,
+		"throttle.global_up.max_rate":   0,
+	}
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		if rate, ok := rates[method]; ok {
+			return Value{I8: intPtr(rate)}, nil
+		}
+		if getter, ok := map[string]string{
+			"throttle.global_down.max_rate.set": "throttle.global_down.max_rate",
+			"throttle.global_up.max_rate.set":   "throttle.global_up.max_rate",
+		}[method]; ok {
+			// The first argument is the empty target
+			rates[getter] = args[1].GetLong()
+			return Value{Int: intPtr(0)}, nil
+		}
+		return Value{}, &FaultError{Code: -506, Message: "Method '" + method + "' not defined"}
+	})
+
+	if err := c.SetGlobalThrottle(ctx, Throttle{DownloadRate: 500 << 10, UploadRate: 64 << 10}); err != nil {
+		t.Fatal(err)
+	}
+	throttle, err := c.GetGlobalThrottle(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *throttle != (Throttle{DownloadRate: 500 << 10, UploadRate: 64 << 10}) {
+		t.Errorf("throttle = %+v", throttle)
+	}
+
+	before := len(fake.Calls())
+	if err := c.SetGlobalThrottle(ctx, Throttle{DownloadRate: -1}); err == nil {
+		t.Error("negative rate accepted")
+	}
+	if len(fake.Calls()) != before {
+		t.Error("negative rate sent to rTorrent")
+	}
+}
+
+func TestSetThrottleGroup(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	if err := c.SetThrottleGroup(context.Background(), "slow", 100<<10, 512); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.Calls()
+	if got := fake.Methods(); !slices.Equal(got, []string{"throttle.down", "throttle.up"}) {
+		t.Fatalf("calls = %v", got)
+	}
+	// Named throttles take KiB/s as a string, rounded up since 0 would be
+	// unlimited
+	for i, want := range []string{"100", "1"} {
+		args := calls[i].Args
+		if len(args) != 3 || args[1].GetString() != "slow" || args[2].GetString() != want {
+			t.Errorf("%s args = %+v, want slow, %s", calls[i].Method, args, want)
+		}
+	}
+
+	if err := c.SetThrottleGroup(context.Background(), "", 0, 0); err == nil {
+		t.Error("empty group name accepted")
+	}
+}
+
+func TestSetTorrentThrottle(t *testing.T) {
+	tests := []struct {
+		name          string
+		state, active int64
+		want          []string
+	}{
+		{"stopped", 0, 0, []string{"d.state", "d.is_active", "d.throttle_name.set"}},
+		{"running", 1, 1, []string{"d.state", "d.is_active", "d.stop", "d.throttle_name.set", "d.start"}},
+		{"paused", 1, 0, []string{"d.state", "d.is_active", "d.stop", "d.throttle_name.set", "d.start", "d.pause"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+				switch method {
+				case "d.state":
+					return Value{I8: intPtr(tt.state)}, nil
+				case "d.is_active":
+					return Value{I8: intPtr(tt.active)}, nil
+				}
+				return Value{Int: intPtr(0)}, nil
+			})
+			if err := c.SetTorrentThrottle(context.Background(), "ABC", "slow"); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.Methods(); !slices.Equal(got, tt.want) {
+				t.Errorf("calls = %v, want %v", got, tt.want)
+			}
+			for _, call := range fake.Calls() {
+				if call.Method == "d.throttle_name.set" && call.Args[1].GetString() != "slow" {
+					t.Errorf("throttle name = %q, want slow", call.Args[1].GetString())
+				}
+			}
+		})
+	}
+}
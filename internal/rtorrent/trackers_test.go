@@ -0,0 +1,105 @@
+package rtorrent
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestGetTrackers(t *testing.T) {
+	_, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		switch method {
+		case "t.multicall":
+			return Value{Array: &ValArray{Data: []Value{
+				// Announced fine after an earlier failure
+				resultRow("http://a.example/announce", 1, 1, 12, 3, 1000, 2800, 900, 500),
+				// Failing since its last success
+				resultRow("udp://b.example:80", 2, 0, 0, 0, 1000, 1060, 100, 1000),
+				resultRow("dht://", 3, 1, 0, 0, 0, 0, 0, 0),
+				resultRow("short"),
+			}}}, nil
+		case "d.message":
+			return Value{String: stringPtr("Tracker: [Timeout was reached]")}, nil
+		}
+		return Value{}, &FaultError{Code: -506, Message: "Method '" + method + "' not defined"}
+	})
+
+	trackers, err := c.GetTrackers(context.Background(), "ABC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tracker{
+		{Index: 0, URL: "http://a.example/announce", Type: "http", Enabled: true, Seeders: 12, Leechers: 3, LastAnnounce: 1000, NextAnnounce: 2800},
+		{Index: 1, URL: "udp://b.example:80", Type: "udp", LastAnnounce: 1000, NextAnnounce: 1060, LastError: "Tracker: [Timeout was reached]"},
+		{Index: 2, URL: "dht://", Type: "dht", Enabled: true},
+	}
+	if !reflect.DeepEqual(trackers, want) {
+		t.Errorf("trackers = %+v, want %+v", trackers, want)
+	}
+}
+
+func TestGetTrackersFault(t *testing.T) {
+	_, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		return Value{}, &FaultError{Code: -501, Message: "Could not find info-hash."}
+	})
+	if _, err := c.GetTrackers(context.Background(), "ABC"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSetTrackerEnabled(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	ctx := context.Background()
+	if err := c.SetTrackerEnabled(ctx, "ABC", 2, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetTrackerEnabled(ctx, "ABC", 0, true); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.Calls()
+	if got := fake.Methods(); !slices.Equal(got, []string{"t.disable", "t.enable"}) {
+		t.Fatalf("calls = %v", got)
+	}
+	if got := calls[0].Args[0].GetString(); got != "ABC:t2" {
+		t.Errorf("t.disable target = %q, want ABC:t2", got)
+	}
+	if got := calls[1].Args[0].GetString(); got != "ABC:t0" {
+		t.Errorf("t.enable target = %q, want ABC:t0", got)
+	}
+}
+
+func TestAddTracker(t *testing.T) {
+	fake, c := newFakeRTorrent(t, func(method string, args []Value) (Value, *FaultError) {
+		if method == "d.tracker_size" {
+			return Value{I8: intPtr(3)}, nil
+		}
+		return Value{Int: intPtr(0)}, nil
+	})
+	if err := c.AddTracker(context.Background(), "ABC", "http://new.example/announce"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := fake.Calls()
+	if got := fake.Methods(); !slices.Equal(got, []string{"d.tracker_size", "d.tracker.insert"}) {
+		t.Fatalf("calls = %v", got)
+	}
+	// The new tracker goes into a tier of its own after the existing ones
+	args := calls[1].Args
+	if len(args) != 3 || args[0].GetString() != "ABC" || args[1].GetLong() != 3 || args[2].GetString() != "http://new.example/announce" {
+		t.Errorf("d.tracker.insert args = %+v", args)
+	}
+}
+
+func TestReannounceTorrent(t *testing.T) {
+	fake, c := newFakeRTorrent(t, nil)
+	if err := c.ReannounceTorrent(context.Background(), "ABC"); err != nil {
+		t.Fatal(err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Method != "d.tracker_announce" || calls[0].Args[0].GetString() != "ABC" {
+		t.Errorf("calls = %+v, want d.tracker_announce ABC", calls)
+	}
+}
@@ -0,0 +1,282 @@
+package rtorrent
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// XML-RPC Structures
+type MethodCall struct {
+	XMLName    xml.Name `xml:"methodCall"`
+	MethodName string   `xml:"methodName"`
+	Params     []Param  `xml:"params>param"`
+}
+
+type Param struct {
+	Value Value `xml:"value"`
+}
+
+type MethodResponse struct {
+	Params []Param `xml:"params>param"`
+	Fault  *Fault  `xml:"fault,omitempty"`
+}
+
+type Fault struct {
+	Value Value `xml:"value"`
+}
+
+// Value is a single XML-RPC value. Exactly one field is set; an empty Value
+// encodes as an empty string.
+type Value struct {
+	String   *string    `xml:"string,omitempty"`
+	Int      *int64     `xml:"int,omitempty"`
+	I4       *int64     `xml:"i4,omitempty"`
+	I8       *int64     `xml:"i8,omitempty"`
+	Double   *float64   `xml:"double,omitempty"`
+	Boolean  *bool      `xml:"boolean,omitempty"`
+	Base64   []byte     `xml:"base64,omitempty"`
+	DateTime *time.Time `xml:"dateTime.iso8601,omitempty"`
+	Array    *ValArray  `xml:"array,omitempty"`
+	Struct   *ValStruct `xml:"struct,omitempty"`
+	Nil      bool       `xml:"nil,omitempty"`
+}
+
+type ValArray struct {
+	Data []Value `xml:"data>value"`
+}
+
+// MarshalXML always emits the <data> element, even for empty arrays,
+// as required by the XML-RPC spec.
+func (a ValArray) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type data struct {
+		Values []Value `xml:"value"`
+	}
+	return e.EncodeElement(struct {
+		Data data `xml:"data"`
+	}{data{a.Data}}, start)
+}
+
+type ValStruct struct {
+	Members []Member `xml:"member"`
+}
+
+type Member struct {
+	Name  string `xml:"name"`
+	Value Value  `xml:"value"`
+}
+
+// dateTimeLayouts lists the dateTime.iso8601 spellings seen in the wild.
+// The first entry is the one the spec uses and the one we emit.
+var dateTimeLayouts = []string{
+	"20060102T15:04:05",
+	"20060102T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"20060102T150405",
+	"20060102T150405Z07:00",
+}
+
+func (v Value) GetLong() int64 {
+	if v.Int != nil {
+		return *v.Int
+	}
+	if v.I4 != nil {
+		return *v.I4
+	}
+	if v.I8 != nil {
+		return *v.I8
+	}
+	return 0
+}
+
+func (v Value) GetString() string {
+	if v.String != nil {
+		return *v.String
+	}
+	return ""
+}
+
+func (v Value) GetArray() []Value {
+	if v.Array != nil {
+		return v.Array.Data
+	}
+	return nil
+}
+
+func (v Value) GetStruct() map[string]Value {
+	if v.Struct == nil {
+		return nil
+	}
+	m := make(map[string]Value, len(v.Struct.Members))
+	for _, member := range v.Struct.Members {
+		m[member.Name] = member.Value
+	}
+	return m
+}
+
+func (v Value) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "value"}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case v.String != nil:
+		err = encodeScalar(e, "string", *v.String)
+	case v.Int != nil:
+		err = encodeScalar(e, "int", strconv.FormatInt(*v.Int, 10))
+	case v.I4 != nil:
+		err = encodeScalar(e, "i4", strconv.FormatInt(*v.I4, 10))
+	case v.I8 != nil:
+		err = encodeScalar(e, "i8", strconv.FormatInt(*v.I8, 10))
+	case v.Double != nil:
+		// XML-RPC doesn't allow exponent notation
+		err = encodeScalar(e, "double", strconv.FormatFloat(*v.Double, 'f', -1, 64))
+	case v.Boolean != nil:
+		b := "0"
+		if *v.Boolean {
+			b = "1"
+		}
+		err = encodeScalar(e, "boolean", b)
+	case v.Base64 != nil:
+		err = encodeScalar(e, "base64", base64.StdEncoding.EncodeToString(v.Base64))
+	case v.DateTime != nil:
+		err = encodeScalar(e, "dateTime.iso8601", v.DateTime.Format(dateTimeLayouts[0]))
+	case v.Array != nil:
+		err = e.EncodeElement(v.Array, xml.StartElement{Name: xml.Name{Local: "array"}})
+	case v.Struct != nil:
+		err = e.EncodeElement(v.Struct, xml.StartElement{Name: xml.Name{Local: "struct"}})
+	case v.Nil:
+		nilStart := xml.StartElement{Name: xml.Name{Local: "nil"}}
+		if err = e.EncodeToken(nilStart); err == nil {
+			err = e.EncodeToken(nilStart.End())
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func encodeScalar(e *xml.Encoder, name, text string) error {
+	return e.EncodeElement(text, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+// UnmarshalXML decodes any XML-RPC value. Text directly inside <value>
+// without a type element is a string, as the spec requires.
+func (v *Value) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*v = Value{}
+
+	var text strings.Builder
+	typed := false
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			if !typed {
+				text.Write(t)
+			}
+		case xml.StartElement:
+			if typed {
+				return fmt.Errorf("unexpected <%s> after value type", t.Name.Local)
+			}
+			typed = true
+			if err := v.decodeTyped(d, t); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if !typed {
+				s := text.String()
+				v.String = &s
+			}
+			return nil
+		}
+	}
+}
+
+func (v *Value) decodeTyped(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "array":
+		v.Array = &ValArray{}
+		return d.DecodeElement(v.Array, &start)
+	case "struct":
+		v.Struct = &ValStruct{}
+		return d.DecodeElement(v.Struct, &start)
+	case "nil":
+		v.Nil = true
+		return d.Skip()
+	}
+
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+
+	switch start.Name.Local {
+	case "string":
+		v.String = &text
+	case "int", "i4", "i8":
+		n, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid <%s> %q", start.Name.Local, text)
+		}
+		switch start.Name.Local {
+		case "int":
+			v.Int = &n
+		case "i4":
+			v.I4 = &n
+		default:
+			v.I8 = &n
+		}
+	case "double":
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return fmt.Errorf("invalid <double> %q", text)
+		}
+		v.Double = &f
+	case "boolean":
+		var b bool
+		switch strings.TrimSpace(text) {
+		case "1", "true":
+			b = true
+		case "0", "false":
+		default:
+			return fmt.Errorf("invalid <boolean> %q", text)
+		}
+		v.Boolean = &b
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(text), ""))
+		if err != nil {
+			return fmt.Errorf("invalid <base64>: %w", err)
+		}
+		v.Base64 = data
+	case "dateTime.iso8601":
+		t, err := parseDateTime(strings.TrimSpace(text))
+		if err != nil {
+			return err
+		}
+		v.DateTime = &t
+	default:
+		return fmt.Errorf("unknown value type <%s>", start.Name.Local)
+	}
+	return nil
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid <dateTime.iso8601> %q", s)
+}
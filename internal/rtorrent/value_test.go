@@ -0,0 +1,229 @@
+package rtorrent
+
+import (
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueRoundTrip(t *testing.T) {
+	when := time.Date(2024, 3, 9, 12, 30, 5, 0, time.UTC)
+	double := 1.5e-7
+	yes := true
+	values := map[string]Value{
+		"string":   {String: stringPtr("a <b> & c")},
+		"empty":    {String: stringPtr("")},
+		"int":      {Int: intPtr(-42)},
+		"i4":       {I4: intPtr(7)},
+		"i8":       {I8: intPtr(1 << 40)},
+		"double":   {Double: &double},
+		"boolean":  {Boolean: &yes},
+		"base64":   {Base64: []byte{0, 1, 2, 0xff}},
+		"datetime": {DateTime: &when},
+		"nil":      {Nil: true},
+		"array": {Array: &ValArray{Data: []Value{
+			{String: stringPtr("x")},
+			{Int: intPtr(1)},
+		}}},
+		"empty array": {Array: &ValArray{}},
+		"struct": {Struct: &ValStruct{Members: []Member{
+			{Name: "name", Value: Value{String: stringPtr("x")}},
+			{Name: "list", Value: Value{Array: &ValArray{Data: []Value{{Int: intPtr(3)}}}}},
+		}}},
+	}
+	for name, v := range values {
+		t.Run(name, func(t *testing.T) {
+			data, err := xml.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got Value
+			if err := xml.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal %s: %v", data, err)
+			}
+			if !reflect.DeepEqual(got.Interface(), v.Interface()) || got.typeName() != v.typeName() {
+				t.Errorf("round trip of %s = %#v, want %#v", data, got.Interface(), v.Interface())
+			}
+		})
+	}
+}
+
+func TestEmptyArrayKeepsDataElement(t *testing.T) {
+	data, err := xml.Marshal(Value{Array: &ValArray{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<data></data>") {
+		t.Errorf("empty array encoded as %s, want a <data> element", data)
+	}
+}
+
+func TestValueUnmarshal(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    interface{}
+		wantErr bool
+	}{
+		{in: "<value>untyped</value>", want: "untyped"},
+		{in: "<value></value>", want: ""},
+		{in: "<value><i8> 12 </i8></value>", want: int64(12)},
+		{in: "<value><boolean>true</boolean></value>", want: true},
+		{in: "<value><base64>AAEC\n/w==</base64></value>", want: []byte{0, 1, 2, 0xff}},
+		{in: "<value><dateTime.iso8601>2024-03-09T12:30:05Z</dateTime.iso8601></value>", want: time.Date(2024, 3, 9, 12, 30, 5, 0, time.UTC)},
+		{in: "<value><int>nope</int></value>", wantErr: true},
+		{in: "<value><boolean>2</boolean></value>", wantErr: true},
+		{in: "<value><bogus>1</bogus></value>", wantErr: true},
+		{in: "<value><int>1</int><int>2</int></value>", wantErr: true},
+	}
+	for _, tt := range tests {
+		var v Value
+		err := xml.Unmarshal([]byte(tt.in), &v)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %#v", tt.in, v.Interface())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if got := v.Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValueDecode(t *testing.T) {
+	type inner struct {
+		N int64
+	}
+	type target struct {
+		Name    string            `xmlrpc:"name"`
+		Size    uint32            `xmlrpc:"size"`
+		Ratio   float64           `xmlrpc:"ratio"`
+		Active  bool              `xmlrpc:"active"`
+		Tags    []string          `xmlrpc:"tags"`
+		Inner   *inner            `xmlrpc:"inner"`
+		Extra   map[string]string `xmlrpc:"extra"`
+		Skipped string            `xmlrpc:"-"`
+		Number  string
+	}
+	v := Value{Struct: &ValStruct{Members: []Member{
+		{Name: "name", Value: Value{String: stringPtr("ubuntu")}},
+		{Name: "size", Value: Value{String: stringPtr(" 4096 ")}},
+		{Name: "ratio", Value: Value{I8: intPtr(2)}},
+		{Name: "active", Value: Value{Int: intPtr(1)}},
+		{Name: "tags", Value: Value{Array: &ValArray{Data: []Value{{String: stringPtr("a")}, {String: stringPtr("b")}}}}},
+		{Name: "inner", Value: Value{Struct: &ValStruct{Members: []Member{{Name: "N", Value: Value{I4: intPtr(9)}}}}}},
+		{Name: "extra", Value: Value{Struct: &ValStruct{Members: []Member{{Name: "k", Value: Value{String: stringPtr("v")}}}}}},
+		{Name: "-", Value: Value{String: stringPtr("ignored")}},
+		{Name: "Number", Value: Value{Int: intPtr(77)}},
+		{Name: "unknown", Value: Value{String: stringPtr("ignored")}},
+	}}}
+
+	var got target
+	if err := v.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := target{
+		Name:   "ubuntu",
+		Size:   4096,
+		Ratio:  2,
+		Active: true,
+		Tags:   []string{"a", "b"},
+		Inner:  &inner{N: 9},
+		Extra:  map[string]string{"k": "v"},
+		Number: "77",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+}
+
+// Types for TestValueDecodeEmbedded; embedded pointers to exported and
+// unexported types behave differently
+type EmbeddedCommon struct {
+	Name string `xmlrpc:"name"`
+	Size int64  `xmlrpc:"size"`
+}
+
+type embeddedExtra struct {
+	Label string `xmlrpc:"label"`
+}
+
+func TestValueDecodeEmbedded(t *testing.T) {
+	type left struct {
+		Both string `xmlrpc:"both"`
+	}
+	type right struct {
+		Both string `xmlrpc:"both"`
+	}
+	type target struct {
+		EmbeddedCommon
+		*embeddedExtra
+		left
+		right
+		// Shadows EmbeddedCommon.Size
+		Size   string         `xmlrpc:"size"`
+		Tagged EmbeddedCommon `xmlrpc:"tagged"`
+	}
+	v := Value{Struct: &ValStruct{Members: []Member{
+		{Name: "name", Value: Value{String: stringPtr("ubuntu")}},
+		{Name: "size", Value: Value{I8: intPtr(4096)}},
+		{Name: "label", Value: Value{String: stringPtr("OS")}},
+		{Name: "both", Value: Value{String: stringPtr("ignored")}},
+		{Name: "tagged", Value: Value{Struct: &ValStruct{Members: []Member{{Name: "name", Value: Value{String: stringPtr("inner")}}}}}},
+	}}}
+
+	var got target
+	if err := v.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// Fields tied at the same depth are left alone, and a nil pointer to
+	// an unexported type can't be allocated
+	want := target{
+		EmbeddedCommon: EmbeddedCommon{Name: "ubuntu"},
+		Size:           "4096",
+		Tagged:         EmbeddedCommon{Name: "inner"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode = %+v, want %+v", got, want)
+	}
+
+	type exported struct {
+		*EmbeddedCommon
+	}
+	var ptr exported
+	if err := v.Decode(&ptr); err != nil {
+		t.Fatal(err)
+	}
+	if ptr.EmbeddedCommon == nil || *ptr.EmbeddedCommon != (EmbeddedCommon{Name: "ubuntu", Size: 4096}) {
+		t.Errorf("Decode into embedded pointer = %+v", ptr.EmbeddedCommon)
+	}
+}
+
+func TestValueDecodeErrors(t *testing.T) {
+	var i8 int8
+	if err := (Value{Int: intPtr(300)}).Decode(&i8); err == nil {
+		t.Error("expected overflow error for int8")
+	}
+	var u uint
+	if err := (Value{Int: intPtr(-1)}).Decode(&u); err == nil {
+		t.Error("expected error for negative uint")
+	}
+	var n int
+	if err := (Value{Array: &ValArray{}}).Decode(&n); err == nil {
+		t.Error("expected type error for array into int")
+	}
+	var list []int
+	err := (Value{Array: &ValArray{Data: []Value{{Int: intPtr(1)}, {String: stringPtr("x")}}}}).Decode(&list)
+	if err == nil || !strings.Contains(err.Error(), "[1]") {
+		t.Errorf("err = %v, want one naming element [1]", err)
+	}
+	if err := (Value{Int: intPtr(1)}).Decode(n); err == nil {
+		t.Error("expected error for non-pointer target")
+	}
+}
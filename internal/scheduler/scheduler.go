@@ -0,0 +1,163 @@
+// Package scheduler switches rTorrent's speed profile according to the
+// weekly schedule in the config, and keeps throttle groups in place.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"rtorrent-go/internal/bandwidth"
+)
+
+// pollInterval is how often rTorrent is checked for restarts between
+// slot boundaries.
+const pollInterval = 30 * time.Second
+
+// Scheduler applies the slot for the current hour whenever a boundary
+// passes, the schedule changes or rTorrent comes back after a restart. On
+// every tick it also moves new and relabelled torrents into the throttle
+// group of their label.
+type Scheduler struct {
+	ctl    *bandwidth.Controller
+	reload chan struct{}
+
+	mu sync.Mutex
+	// applied is the slot last sent to rTorrent, 0 when nothing was
+	applied byte
+	// pid identifies the rTorrent process the slot was applied to
+	pid int64
+}
+
+func New(ctl *bandwidth.Controller) *Scheduler {
+	return &Scheduler{
+		ctl:    ctl,
+		reload: make(chan struct{}, 1),
+	}
+}
+
+// Run loops until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	s.tick(ctx, time.Now())
+	for {
+		timer := time.NewTimer(nextWake(time.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.reload:
+			timer.Stop()
+			// Forgetting the process re-applies everything. applied is
+			// kept so switching the schedule off still lifts its limits.
+			s.mu.Lock()
+			s.pid = 0
+			s.mu.Unlock()
+		case <-timer.C:
+		}
+		s.tick(ctx, time.Now())
+	}
+}
+
+// Reload makes the scheduler re-apply everything as if rTorrent had just
+// restarted, e.g. after the schedule was edited or setup connected.
+func (s *Scheduler) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Active returns the slot currently applied, 0 if none
+func (s *Scheduler) Active() byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applied
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	client := s.ctl.Client()
+	if client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pollInterval)
+	defer cancel()
+
+	pid, err := client.GetPID(ctx)
+	if err != nil {
+		// Forget the process so everything is re-applied once it's back
+		s.mu.Lock()
+		s.pid = 0
+		s.mu.Unlock()
+		return
+	}
+
+	// The lock only guards pid and applied for Active. Nothing else writes
+	// them outside the Run goroutine, so it isn't held across the RPCs.
+	s.mu.Lock()
+	restarted := pid != s.pid
+	s.pid = pid
+	applied := s.applied
+	s.mu.Unlock()
+
+	// Named throttles are runtime state in rTorrent as well
+	if restarted {
+		if err := s.ctl.ApplyGroups(ctx); err != nil {
+			log.Printf("Failed to apply throttle groups: %v", err)
+		}
+	}
+	if err := s.ctl.AssignLabelGroups(ctx); err != nil {
+		log.Printf("Failed to assign label throttle groups: %v", err)
+	}
+
+	schedule := s.ctl.Schedule()
+	if !schedule.Enabled {
+		if applied != 0 {
+			// The schedule was just switched off: drop the limits of
+			// its last slot
+			if err := s.ctl.EndSchedule(ctx); err != nil {
+				log.Printf("Failed to lift scheduled speed profile %q: %v", applied, err)
+				return
+			}
+			log.Printf("Schedule disabled, lifted speed profile %q", applied)
+			s.setApplied(0)
+			return
+		}
+		if restarted {
+			if err := s.ctl.Restore(ctx); err != nil {
+				log.Printf("Failed to restore turtle mode: %v", err)
+			}
+		}
+		return
+	}
+
+	slot := schedule.SlotAt(now)
+	if slot == applied && !restarted {
+		return
+	}
+	if err := s.ctl.ApplySlot(ctx, slot); err != nil {
+		log.Printf("Failed to apply scheduled speed profile %q: %v", slot, err)
+		// Try again on the next tick
+		s.setApplied(0)
+		return
+	}
+	log.Printf("Applied scheduled speed profile %q", slot)
+	s.setApplied(slot)
+}
+
+func (s *Scheduler) setApplied(slot byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applied = slot
+}
+
+// nextWake returns how long to sleep until the next hour starts or the
+// next restart check is due, whichever comes first.
+func nextWake(now time.Time) time.Duration {
+	untilHour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour()+1, 0, 0, 0, now.Location()).Sub(now)
+	if untilHour < pollInterval {
+		return untilHour
+	}
+	return pollInterval
+}
@@ -0,0 +1,261 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rtorrent-go/internal/bandwidth"
+	"rtorrent-go/internal/config"
+	"rtorrent-go/internal/rtorrent"
+)
+
+// restartingClient is the mock client with a PID tests can change to
+// fake an rTorrent restart
+type restartingClient struct {
+	rtorrent.Client
+
+	mu  sync.Mutex
+	pid int64
+}
+
+func (c *restartingClient) GetPID(ctx context.Context) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pid, nil
+}
+
+// restart bumps the PID and drops the global limits like a fresh rTorrent
+func (c *restartingClient) restart(t *testing.T) {
+	t.Helper()
+	c.mu.Lock()
+	c.pid++
+	c.mu.Unlock()
+	if err := c.SetGlobalThrottle(context.Background(), rtorrent.Throttle{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func globalDownload(t *testing.T, client rtorrent.Client) int64 {
+	t.Helper()
+	throttle, err := client.GetGlobalThrottle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return throttle.DownloadRate
+}
+
+func TestTick(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Bandwidth.Turtle.DownloadLimit = 50
+
+	client := &restartingClient{Client: rtorrent.NewClient("mock"), pid: 1}
+	ctl := bandwidth.NewController(client, cfg)
+	s := New(ctl)
+
+	// Monday: custom from 09:00, turtle from 10:00, unlimited from 11:00
+	grid := make([]string, 7)
+	for i := range grid {
+		grid[i] = strings.Repeat("u", 24)
+	}
+	grid[0] = strings.Repeat("u", 9) + "ct" + strings.Repeat("u", 13)
+	schedule := config.ScheduleConfig{
+		Enabled: true,
+		Grid:    grid,
+		Custom:  config.LimitsConfig{DownloadLimit: 100},
+	}
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour, min, sec int) time.Time {
+		return time.Date(2026, time.October, 19, hour, min, sec, 0, time.Local)
+	}
+	if at(0, 0, 0).Weekday() != time.Monday {
+		t.Fatal("test date is not a Monday")
+	}
+
+	s.tick(ctx, at(9, 0, 0))
+	if s.Active() != config.SlotCustom || globalDownload(t, client) != 100*1024 {
+		t.Fatalf("09:00: active %q, download %d", s.Active(), globalDownload(t, client))
+	}
+
+	// Within the same slot nothing is re-sent, so a limit changed by hand
+	// stays until the next boundary
+	if err := client.SetGlobalThrottle(ctx, rtorrent.Throttle{DownloadRate: 7}); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(ctx, at(9, 59, 59))
+	if got := globalDownload(t, client); got != 7 {
+		t.Errorf("09:59:59: download %d, want the manual 7", got)
+	}
+
+	s.tick(ctx, at(10, 0, 0))
+	if s.Active() != config.SlotTurtle || !ctl.Turtle().Enabled || globalDownload(t, client) != 50*1024 {
+		t.Fatalf("10:00: active %q, turtle %v, download %d", s.Active(), ctl.Turtle().Enabled, globalDownload(t, client))
+	}
+
+	// A restart wipes the limits, the next tick puts them back
+	client.restart(t)
+	s.tick(ctx, at(10, 0, 30))
+	if got := globalDownload(t, client); got != 50*1024 {
+		t.Errorf("after restart: download %d, want %d", got, 50*1024)
+	}
+
+	s.tick(ctx, at(11, 0, 0))
+	if s.Active() != config.SlotUnlimited || ctl.Turtle().Enabled || globalDownload(t, client) != 0 {
+		t.Fatalf("11:00: active %q, turtle %v, download %d", s.Active(), ctl.Turtle().Enabled, globalDownload(t, client))
+	}
+
+	// Disabling during a limited slot lifts its limits
+	s.tick(ctx, at(9, 0, 0).AddDate(0, 0, 7))
+	if globalDownload(t, client) != 100*1024 {
+		t.Fatalf("next Monday 09:00: download %d", globalDownload(t, client))
+	}
+	schedule.Enabled = false
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(ctx, at(9, 30, 0).AddDate(0, 0, 7))
+	if s.Active() != 0 || globalDownload(t, client) != 0 {
+		t.Errorf("disabled: active %q, download %d, want no limit", s.Active(), globalDownload(t, client))
+	}
+
+	// Once lifted, the scheduler keeps its hands off
+	if err := client.SetGlobalThrottle(ctx, rtorrent.Throttle{DownloadRate: 7}); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(ctx, at(10, 0, 0).AddDate(0, 0, 7))
+	if got := globalDownload(t, client); got != 7 {
+		t.Errorf("disabled: download %d, want the manual 7", got)
+	}
+}
+
+func TestTickDisabledDuringTurtleSlot(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &restartingClient{Client: rtorrent.NewClient("mock"), pid: 1}
+	ctl := bandwidth.NewController(client, cfg)
+	s := New(ctl)
+
+	grid := make([]string, 7)
+	for i := range grid {
+		grid[i] = strings.Repeat("t", 24)
+	}
+	schedule := config.ScheduleConfig{Enabled: true, Grid: grid}
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(ctx, time.Now())
+	if !ctl.Turtle().Enabled {
+		t.Fatal("turtle slot did not enable turtle mode")
+	}
+
+	schedule.Enabled = false
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	// Reload forgets the process; the transition must still be seen
+	s.pid = 0
+	s.tick(ctx, time.Now())
+	if ctl.Turtle().Enabled || s.Active() != 0 {
+		t.Errorf("turtle %v, active %q after disabling", ctl.Turtle().Enabled, s.Active())
+	}
+}
+
+func TestTickRestoresManualLimits(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("VIBETORRENT_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &restartingClient{Client: rtorrent.NewClient("mock"), pid: 1}
+	manual := rtorrent.Throttle{DownloadRate: 300 << 10, UploadRate: 40 << 10}
+	if err := client.SetGlobalThrottle(ctx, manual); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetMaxPeers(ctx, 77); err != nil {
+		t.Fatal(err)
+	}
+	ctl := bandwidth.NewController(client, cfg)
+	s := New(ctl)
+
+	// Monday: custom from 09:00 to 10:00, unlimited otherwise
+	grid := make([]string, 7)
+	for i := range grid {
+		grid[i] = strings.Repeat("u", 24)
+	}
+	grid[0] = strings.Repeat("u", 9) + "c" + strings.Repeat("u", 14)
+	schedule := config.ScheduleConfig{
+		Enabled: true,
+		Grid:    grid,
+		Custom:  config.LimitsConfig{DownloadLimit: 100, MaxPeers: 10},
+	}
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	at := func(hour int) time.Time {
+		return time.Date(2026, time.October, 19, hour, 0, 0, 0, time.Local)
+	}
+	check := func(when string, throttle rtorrent.Throttle, peers int64) {
+		t.Helper()
+		gotThrottle, err := client.GetGlobalThrottle(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotPeers, err := client.GetMaxPeers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *gotThrottle != throttle || gotPeers != peers {
+			t.Errorf("%s: throttle %+v, max peers %d, want %+v, %d", when, *gotThrottle, gotPeers, throttle, peers)
+		}
+	}
+
+	s.tick(ctx, at(9))
+	check("custom", rtorrent.Throttle{DownloadRate: 100 << 10}, 10)
+
+	s.tick(ctx, at(10))
+	check("unlimited", manual, 77)
+
+	s.tick(ctx, at(9).AddDate(0, 0, 7))
+	check("custom again", rtorrent.Throttle{DownloadRate: 100 << 10}, 10)
+	schedule.Enabled = false
+	if err := ctl.SetSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+	s.tick(ctx, at(9).AddDate(0, 0, 7).Add(time.Minute))
+	check("disabled", manual, 77)
+	if cfg.Bandwidth.Unscheduled != nil {
+		t.Errorf("saved limits %+v kept after the schedule ended", *cfg.Bandwidth.Unscheduled)
+	}
+}
+
+func TestNextWake(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC), pollInterval},
+		{time.Date(2026, 10, 19, 10, 59, 50, 0, time.UTC), 10 * time.Second},
+		{time.Date(2026, 10, 19, 23, 59, 59, 500, time.UTC), time.Second - 500},
+		{time.Date(2026, 10, 19, 10, 59, 30, 0, time.UTC), pollInterval},
+	}
+	for _, tt := range tests {
+		if got := nextWake(tt.now); got != tt.want {
+			t.Errorf("nextWake(%s) = %v, want %v", tt.now.Format(time.TimeOnly), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package components
+
+import (
+	"rtorrent-go/internal/metainfo"
+	"rtorrent-go/internal/rtorrent"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// priorityMixed marks a folder whose files don't share one priority
+const priorityMixed = -1
+
+// fileNode is a file or folder in the drawer's file tree. Folders aggregate
+// size, progress and priority over every file below them.
+type fileNode struct {
+	Name      string
+	Path      string
+	IsDir     bool
+	Size      int64
+	Completed int64
+	Priority  int
+	Indices   []int
+	Children  []*fileNode
+}
+
+// buildFileTree turns rTorrent's flat, slash-separated file paths into a
+// directory tree. Folders sort before files, both alphabetically.
+func buildFileTree(files []rtorrent.File) *fileNode {
+	root := &fileNode{IsDir: true}
+	dirs := map[string]*fileNode{"": root}
+
+	for _, f := range files {
+		parts := strings.Split(f.Name, "/")
+		parent := root
+		for i, part := range parts[:len(parts)-1] {
+			path := strings.Join(parts[:i+1], "/")
+			dir, ok := dirs[path]
+			if !ok {
+				dir = &fileNode{Name: part, Path: path, IsDir: true}
+				dirs[path] = dir
+				parent.Children = append(parent.Children, dir)
+			}
+			parent = dir
+		}
+		parent.Children = append(parent.Children, &fileNode{
+			Name:      parts[len(parts)-1],
+			Path:      f.Name,
+			Size:      f.Size,
+			Completed: f.Completed,
+			Priority:  f.Priority,
+			Indices:   []int{f.Index},
+		})
+	}
+
+	root.aggregate()
+	return root
+}
+
+// aggregate fills in folder totals and sorts children
+func (n *fileNode) aggregate() {
+	if !n.IsDir {
+		return
+	}
+
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		a, b := n.Children[i], n.Children[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	n.Size, n.Completed, n.Indices = 0, 0, nil
+	for i, child := range n.Children {
+		child.aggregate()
+		n.Size += child.Size
+		n.Completed += child.Completed
+		n.Indices = append(n.Indices, child.Indices...)
+		if i == 0 {
+			n.Priority = child.Priority
+		} else if n.Priority != child.Priority {
+			n.Priority = priorityMixed
+		}
+	}
+}
+
+func (n *fileNode) progress() float64 {
+	if n.Size == 0 {
+		return 0
+	}
+	return float64(n.Completed) / float64(n.Size) * 100
+}
+
+// indicesParam encodes the node's file indices for the priority endpoint
+func (n *fileNode) indicesParam() string {
+	parts := make([]string, len(n.Indices))
+	for i, index := range n.Indices {
+		parts[i] = strconv.Itoa(index)
+	}
+	return strings.Join(parts, ",")
+}
+
+// previewFiles adapts a .torrent's file list for buildFileTree
+func previewFiles(m *metainfo.MetaInfo) []rtorrent.File {
+	files := make([]rtorrent.File, len(m.Files))
+	for i, f := range m.Files {
+		files[i] = rtorrent.File{Index: f.Index, Name: f.Path, Size: f.Length, Priority: 1}
+	}
+	return files
+}
@@ -3,6 +3,8 @@ package components
 import (
 	"encoding/json"
 	"fmt"
+	"rtorrent-go/internal/rtorrent"
+	"sort"
 	"strings"
 	"time"
 )
@@ -91,6 +93,7 @@ func GetProgressColorStyle(state string) string {
 func GetStatusBadge(state string) string {
 	color := "text-slate-500 bg-slate-500/10"
 	icon := "help"
+	label := strings.Title(state)
 
 	switch state {
 	case "downloading":
@@ -105,9 +108,13 @@ func GetStatusBadge(state string) string {
 	case "checking":
 		color = "text-blue-500 bg-blue-500/10"
 		icon = "sync"
+	case "metadata":
+		color = "text-violet-400 bg-violet-500/10"
+		icon = "hourglass_top"
+		label = "Fetching metadata"
 	}
 
-	return fmt.Sprintf(`<span class="inline-flex items-center gap-1.5 px-2.5 py-1 rounded-full text-xs font-medium %s border border-transparent"><span class="material-symbols-outlined text-[14px]">%s</span>%s</span>`, color, icon, strings.Title(state))
+	return fmt.Sprintf(`<span class="inline-flex items-center gap-1.5 px-2.5 py-1 rounded-full text-xs font-medium %s border border-transparent"><span class="material-symbols-outlined text-[14px]">%s</span>%s</span>`, color, icon, label)
 }
 
 func GetStatusText(state string) string {
@@ -122,6 +129,8 @@ func GetStatusText(state string) string {
 		return `<span class="text-slate-500 font-medium">Durduruldu</span>`
 	case "checking":
 		return `<span class="text-blue-400 font-medium">Kontrol ediliyor</span>`
+	case "metadata":
+		return `<span class="text-violet-400 font-medium">Meta veri alınıyor</span>`
 	default:
 		return `<span class="text-slate-500 font-medium">` + strings.Title(state) + `</span>`
 	}
@@ -137,6 +146,8 @@ func getStatusIcon(state string) string {
 		return "pause_circle"
 	case "checking":
 		return "sync"
+	case "metadata":
+		return "hourglass_top"
 	default:
 		return "help"
 	}
@@ -152,6 +163,8 @@ func getStatusIconBg(state string) string {
 		return "bg-orange-500/20"
 	case "checking":
 		return "bg-blue-500/20"
+	case "metadata":
+		return "bg-violet-500/20"
 	default:
 		return "bg-slate-700/50"
 	}
@@ -167,6 +180,8 @@ func getStatusIconBgStyle(state string) string {
 		return "background-color: rgba(249, 115, 22, 0.2);"
 	case "checking":
 		return "background-color: rgba(59, 130, 246, 0.2);"
+	case "metadata":
+		return "background-color: rgba(139, 92, 246, 0.2);"
 	default:
 		return "background-color: rgba(51, 65, 85, 0.5);"
 	}
@@ -182,6 +197,8 @@ func getStatusIconColor(state string) string {
 		return "text-orange-500"
 	case "checking":
 		return "text-blue-500"
+	case "metadata":
+		return "text-violet-400"
 	default:
 		return "text-slate-400"
 	}
@@ -197,6 +214,8 @@ func getStatusIconColorStyle(state string) string {
 		return "color: #f97316;"
 	case "checking":
 		return "color: #3b82f6;"
+	case "metadata":
+		return "color: #a78bfa;"
 	default:
 		return "color: #94a3b8;"
 	}
@@ -217,3 +236,117 @@ func labelCountsToJSON(labelCounts map[string]int) string {
 	b, _ := json.Marshal(labelCounts)
 	return string(b)
 }
+
+// FormatRelativeTime renders a unix timestamp relative to now, e.g. "5m ago" or "in 12m"
+func FormatRelativeTime(timestamp int64) string {
+	if timestamp <= 0 {
+		return "-"
+	}
+	d := time.Until(time.Unix(timestamp, 0)).Round(time.Second)
+	future := d > 0
+	if !future {
+		d = -d
+	}
+
+	var s string
+	switch {
+	case d < time.Minute:
+		s = fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		s = fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		s = fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		s = fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// mergeTrackersVals encodes trackers for the merge endpoint's hx-vals
+func mergeTrackersVals(trackers []string) string {
+	b, _ := json.Marshal(map[string]string{"trackers": strings.Join(trackers, "\n")})
+	return string(b)
+}
+
+// Outcomes of one item of an add submission
+const (
+	AddStatusAdded     = "added"
+	AddStatusDuplicate = "duplicate"
+	AddStatusFailed    = "failed"
+)
+
+// AddResult reports what happened to one file, URL or magnet of an add
+// submission
+type AddResult struct {
+	// Source is the file name or URL as submitted
+	Source string
+	Status string
+	Reason string
+	Name   string
+	Hash   string
+	// Trackers holds, for duplicates, the trackers the loaded torrent
+	// doesn't have yet
+	Trackers []string
+}
+
+// addResultCounts tallies the results by status for the summary line
+func addResultCounts(results []AddResult) (added, duplicates, failed int) {
+	for _, r := range results {
+		switch r.Status {
+		case AddStatusAdded:
+			added++
+		case AddStatusDuplicate:
+			duplicates++
+		default:
+			failed++
+		}
+	}
+	return added, duplicates, failed
+}
+
+// countryFlag turns an ISO 3166-1 code into its flag emoji, which needs no
+// image assets
+func countryFlag(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	var flag strings.Builder
+	for _, c := range strings.ToUpper(code) {
+		if c < 'A' || c > 'Z' {
+			return ""
+		}
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return flag.String()
+}
+
+type countryCount struct {
+	Code  string
+	Count int
+}
+
+// peerCountries counts the peers per country, most common first. Peers
+// without a known country are left out.
+func peerCountries(peers []rtorrent.Peer) []countryCount {
+	counts := make(map[string]int)
+	for _, p := range peers {
+		if p.Country != "" {
+			counts[p.Country]++
+		}
+	}
+	result := make([]countryCount, 0, len(counts))
+	for code, n := range counts {
+		result = append(result, countryCount{Code: code, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Code < result[j].Code
+	})
+	return result
+}